@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/hex"
 	"fmt"
+	"net/url"
 	"strings"
 
 	"github.com/qingchencloud/cftunnel/internal/authproxy"
@@ -26,11 +27,43 @@ func init() {
 func pushIngress(client *cfapi.Client, ctx context.Context, cfg *config.Config) error {
 	var rules []cfapi.IngressRule
 	for _, r := range cfg.Routes {
-		rules = append(rules, cfapi.IngressRule{Hostname: r.Hostname, Service: r.Service})
+		service, proxied := proxyService(r)
+		rule := cfapi.IngressRule{Hostname: r.Hostname, Service: service}
+		// 启用鉴权时上游选项由代理处理
+		if up := r.Upstream; up != nil && !proxied {
+			rule.NoTLSVerify = up.InsecureSkipVerify
+			rule.OriginServerName = up.ServerName
+			rule.CAPool = up.CAFile
+			rule.HTTPHostHeader = upstreamHostHeader(r.Service, up.HostHeader)
+		}
+		rules = append(rules, rule)
 	}
 	return client.PushIngressConfig(ctx, cfg.Tunnel.ID, rules)
 }
 
+// serviceURL 端口号补全为本地地址，完整地址（https://10.0.0.5:8443、unix:/run/app.sock）原样使用
+func serviceURL(arg string) string {
+	if strings.Contains(arg, "://") || strings.HasPrefix(arg, "unix:") || strings.HasPrefix(arg, "unix+tls:") {
+		return arg
+	}
+	return "http://localhost:" + arg
+}
+
+// upstreamHostHeader 将 host_header 配置转换为 cloudflared 的 httpHostHeader
+func upstreamHostHeader(service, mode string) string {
+	switch mode {
+	case "", "preserve":
+		return ""
+	case "rewrite":
+		if u, err := url.Parse(service); err == nil {
+			return u.Host
+		}
+		return ""
+	default:
+		return mode
+	}
+}
+
 // findZoneForDomain 通过遍历账户 Zone 列表匹配域名（支持多级 TLD）
 func findZoneForDomain(client *cfapi.Client, ctx context.Context, domain string) (*cfapi.ZoneInfo, error) {
 	zoneList, err := client.ListZones(ctx)
@@ -46,77 +79,91 @@ func findZoneForDomain(client *cfapi.Client, ctx context.Context, domain string)
 }
 
 var addCmd = &cobra.Command{
-	Use:   "add <名称> <端口>",
+	Use:   "add <名称> <端口|上游地址>",
 	Short: "添加路由（自动创建 CNAME + 更新 ingress）",
 	Args:  cobra.ExactArgs(2),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name, port := args[0], args[1]
-		service := "http://localhost:" + port
-
-		cfg, err := config.Load()
+		route, err := addRoute(args[0], args[1], addDomain, addAuth)
 		if err != nil {
 			return err
 		}
-		if cfg.Tunnel.ID == "" {
-			return fmt.Errorf("请先运行 cftunnel init && cftunnel create <名称>")
-		}
-		if cfg.FindRoute(name) != nil {
-			return fmt.Errorf("路由 %s 已存在", name)
-		}
+		fmt.Printf("路由已添加: %s → %s (%s)\n", route.Hostname, route.Service, route.Name)
+		return nil
+	},
+}
 
-		client := cfapi.New(cfg.Auth.APIToken, cfg.Auth.AccountID)
-		ctx := context.Background()
+// addRoute 创建 CNAME、保存路由并推送 ingress（CLI 与管理 API 共用）
+// auth 为 "用户名:密码"，为空时不启用密码保护
+func addRoute(name, target, domain, auth string) (*config.RouteConfig, error) {
+	service := serviceURL(target)
 
-		// 查找域名对应的 Zone（支持多级 TLD）
-		zone, err := findZoneForDomain(client, ctx, addDomain)
-		if err != nil {
-			return err
-		}
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Tunnel.ID == "" {
+		return nil, fmt.Errorf("请先运行 cftunnel init && cftunnel create <名称>")
+	}
+	if cfg.FindRoute(name) != nil {
+		return nil, fmt.Errorf("路由 %s 已存在", name)
+	}
 
-		// 创建 CNAME
-		target := cfg.Tunnel.ID + ".cfargotunnel.com"
-		fmt.Printf("正在创建 DNS 记录 %s → %s\n", addDomain, target)
-		recordID, err := client.CreateCNAME(ctx, zone.ID, addDomain, target)
-		if err != nil {
-			return err
-		}
+	client := cfapi.New(cfg.Auth.APIToken, cfg.Auth.AccountID)
+	ctx := context.Background()
 
-		// 构建路由配置
-		route := config.RouteConfig{
-			Name:        name,
-			Hostname:    addDomain,
-			Service:     service,
-			ZoneID:      zone.ID,
-			DNSRecordID: recordID,
-		}
+	// 查找域名对应的 Zone（支持多级 TLD）
+	zone, err := findZoneForDomain(client, ctx, domain)
+	if err != nil {
+		return nil, err
+	}
 
-		// 如果指定了 --auth，填充鉴权配置
-		if addAuth != "" {
-			user, pass, err := parseAuth(addAuth)
-			if err != nil {
-				return err
-			}
-			route.Auth = &config.AuthProxy{
-				Username:   user,
-				Password:   pass,
-				SigningKey:  hex.EncodeToString(authproxy.RandomKey()),
-			}
-			fmt.Printf("已启用密码保护: %s\n", addDomain)
-		}
+	// 创建 CNAME
+	cname := cfg.Tunnel.ID + ".cfargotunnel.com"
+	fmt.Printf("正在创建 DNS 记录 %s → %s\n", domain, cname)
+	recordID, err := client.CreateCNAME(ctx, zone.ID, domain, cname)
+	if err != nil {
+		return nil, err
+	}
 
-		// 保存路由
-		cfg.Routes = append(cfg.Routes, route)
-		if err := cfg.Save(); err != nil {
-			return err
-		}
+	// 构建路由配置
+	route := config.RouteConfig{
+		Name:        name,
+		Hostname:    domain,
+		Service:     service,
+		ZoneID:      zone.ID,
+		DNSRecordID: recordID,
+	}
 
-		// 推送 ingress 配置到远端
-		fmt.Println("正在同步 ingress 配置...")
-		if err := pushIngress(client, ctx, cfg); err != nil {
-			return fmt.Errorf("推送 ingress 失败: %w（DNS 记录已创建，请排查后重试 add 或手动删除 DNS 记录）", err)
+	// 如果指定了 --auth，填充鉴权配置
+	if auth != "" {
+		user, pass, err := parseAuth(auth)
+		if err != nil {
+			return nil, err
+		}
+		route.Auth = &config.AuthProxy{
+			Username:   user,
+			Password:   pass,
+			SigningKey: hex.EncodeToString(authproxy.RandomKey()),
+		}
+		// 固定代理端口，ingress 直接指向代理，避免未启动代理时路由裸奔
+		port, err := authproxy.AllocatePort(service, usedProxyPorts(cfg))
+		if err != nil {
+			return nil, err
 		}
+		route.ProxyPort = port
+		fmt.Printf("已启用密码保护: %s\n", domain)
+	}
 
-		fmt.Printf("路由已添加: %s → %s (%s)\n", addDomain, service, name)
-		return nil
-	},
+	// 保存路由
+	cfg.Routes = append(cfg.Routes, route)
+	if err := cfg.Save(); err != nil {
+		return nil, err
+	}
+
+	// 推送 ingress 配置到远端
+	fmt.Println("正在同步 ingress 配置...")
+	if err := pushIngress(client, ctx, cfg); err != nil {
+		return nil, fmt.Errorf("推送 ingress 失败: %w（DNS 记录已创建，请排查后重试 add 或手动删除 DNS 记录）", err)
+	}
+	return &route, nil
 }
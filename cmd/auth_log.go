@@ -0,0 +1,132 @@
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/authproxy"
+	"github.com/spf13/cobra"
+)
+
+var (
+	authLogFailed bool
+	authLogFollow bool
+	authLogLines  int
+	authLogJSON   bool
+)
+
+func init() {
+	authLogCmd.Flags().BoolVar(&authLogFailed, "failed", false, "仅显示失败访问（登录失败、拒绝、4xx/5xx）")
+	authLogCmd.Flags().BoolVarP(&authLogFollow, "follow", "f", false, "实时跟踪日志")
+	authLogCmd.Flags().IntVarP(&authLogLines, "lines", "n", 100, "显示最后 N 条")
+	authLogCmd.Flags().BoolVar(&authLogJSON, "json", false, "输出原始 JSON Lines")
+	authCmd.AddCommand(authLogCmd)
+}
+
+var authLogCmd = &cobra.Command{
+	Use:   "log <路由>",
+	Short: "查看鉴权代理访问日志（登录、拒绝、请求记录）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := findAuthRoute(args[0]); err != nil {
+			return err
+		}
+		logFile := authLogPath(args[0])
+		f, err := os.Open(logFile)
+		if err != nil {
+			return fmt.Errorf("访问日志不存在: %s（路由尚未有访问记录）", logFile)
+		}
+		defer f.Close()
+
+		// 过滤后取最后 N 条
+		var lines []string
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			if line := scanner.Text(); matchAuthLog(line) {
+				lines = append(lines, line)
+				if len(lines) > authLogLines {
+					lines = lines[1:]
+				}
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return err
+		}
+		for _, line := range lines {
+			printAuthLog(line)
+		}
+
+		if !authLogFollow {
+			return nil
+		}
+
+		// 实时跟踪：轮询文件变化，文件被轮转（变小）时从头读取
+		stat, _ := f.Stat()
+		offset := stat.Size()
+		for {
+			time.Sleep(500 * time.Millisecond)
+			f2, err := os.Open(logFile)
+			if err != nil {
+				continue
+			}
+			stat2, _ := f2.Stat()
+			if stat2.Size() < offset {
+				offset = 0
+			}
+			if stat2.Size() > offset {
+				f2.Seek(offset, 0)
+				scanner := bufio.NewScanner(f2)
+				for scanner.Scan() {
+					if line := scanner.Text(); matchAuthLog(line) {
+						printAuthLog(line)
+					}
+				}
+				offset = stat2.Size()
+			}
+			f2.Close()
+		}
+	},
+}
+
+// matchAuthLog 按 --failed 过滤日志行
+func matchAuthLog(line string) bool {
+	if !authLogFailed {
+		return true
+	}
+	var e authproxy.AccessEntry
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		return false
+	}
+	return e.Failed()
+}
+
+func printAuthLog(line string) {
+	if authLogJSON {
+		fmt.Println(line)
+		return
+	}
+	var e authproxy.AccessEntry
+	if err := json.Unmarshal([]byte(line), &e); err != nil {
+		fmt.Println(line)
+		return
+	}
+	fmt.Println(formatAccessEntry(e))
+}
+
+// formatAccessEntry 将访问日志条目格式化为一行文本
+func formatAccessEntry(e authproxy.AccessEntry) string {
+	ip := e.IP
+	if e.Country != "" {
+		ip += " (" + e.Country + ")"
+	}
+	user := e.User
+	if user == "" {
+		user = "-"
+	}
+	return fmt.Sprintf("%s  %-12s  %-22s  %-10s  %s %s  %d  %dB  %dms",
+		e.Time.Local().Format("2006-01-02 15:04:05"), e.Event, ip, user,
+		e.Method, e.Path, e.Status, e.Bytes, e.LatencyMS)
+}
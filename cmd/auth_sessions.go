@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/qingchencloud/cftunnel/internal/authproxy"
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/spf13/cobra"
+)
+
+var sessionsUser string
+
+func init() {
+	authSessionsListCmd.Flags().StringVar(&sessionsUser, "user", "", "仅显示指定用户的会话")
+	authSessionsRevokeCmd.Flags().StringVar(&sessionsUser, "user", "", "仅吊销指定用户的会话（默认吊销全部）")
+	authSessionsCmd.AddCommand(authSessionsListCmd)
+	authSessionsCmd.AddCommand(authSessionsRevokeCmd)
+	authCmd.AddCommand(authSessionsCmd)
+}
+
+var authSessionsCmd = &cobra.Command{
+	Use:   "sessions",
+	Short: "查看或吊销登录会话",
+}
+
+var authSessionsListCmd = &cobra.Command{
+	Use:   "list <路由>",
+	Short: "列出路由的登录会话",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := findAuthRoute(args[0]); err != nil {
+			return err
+		}
+		store := authproxy.OpenSessionStore(authproxy.SessionStorePath(args[0]))
+		var list []authproxy.Session
+		for _, s := range store.List() {
+			if sessionsUser == "" || s.Username == sessionsUser {
+				list = append(list, s)
+			}
+		}
+		if len(list) == 0 {
+			fmt.Println("暂无有效会话")
+			return nil
+		}
+
+		const layout = "2006-01-02 15:04"
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "会话\t用户\tIP\t登录时间\t最近活跃\t过期时间")
+		fmt.Fprintln(w, "----\t----\t--\t--------\t--------\t--------")
+		for _, s := range list {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\n", s.ID[:8], s.Username, s.IP,
+				s.CreatedAt.Local().Format(layout), s.LastSeen.Local().Format(layout), s.ExpiresAt.Local().Format(layout))
+		}
+		w.Flush()
+		return nil
+	},
+}
+
+var authSessionsRevokeCmd = &cobra.Command{
+	Use:   "revoke <路由>",
+	Short: "吊销路由的登录会话（立即生效，无需重启）",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if _, err := findAuthRoute(args[0]); err != nil {
+			return err
+		}
+		store := authproxy.OpenSessionStore(authproxy.SessionStorePath(args[0]))
+		n, err := store.Revoke(sessionsUser)
+		if err != nil {
+			return fmt.Errorf("吊销会话失败: %w", err)
+		}
+		fmt.Printf("已吊销 %d 个会话\n", n)
+		return nil
+	},
+}
+
+// findAuthRoute 查找启用了鉴权的路由
+func findAuthRoute(name string) (*config.RouteConfig, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, err
+	}
+	route := cfg.FindRoute(name)
+	if route == nil {
+		return nil, fmt.Errorf("路由 %s 不存在", name)
+	}
+	if route.Auth == nil {
+		return nil, fmt.Errorf("路由 %s 未启用鉴权", name)
+	}
+	return route, nil
+}
@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/authproxy"
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serveConfigDir   string
+	serveMetricsAddr string
+)
+
+func init() {
+	authproxyServeCmd.Flags().StringVar(&serveConfigDir, "config-dir", "", "配置目录（系统服务使用，默认 ~/.cftunnel）")
+	authproxyServeCmd.Flags().StringVar(&serveMetricsAddr, "metrics", "", "提供 Prometheus 指标的监听地址，如 127.0.0.1:9465")
+	authproxyCmd.AddCommand(authproxyServeCmd)
+	rootCmd.AddCommand(authproxyCmd)
+}
+
+var authproxyCmd = &cobra.Command{
+	Use:   "authproxy",
+	Short: "鉴权代理 sidecar（配合系统服务使用）",
+}
+
+var authproxyServeCmd = &cobra.Command{
+	Use:   "serve",
+	Short: "常驻运行所有启用鉴权或限流的路由代理（固定端口，配置变更自动重载）",
+	Long: `常驻运行所有启用鉴权或限流的路由代理，监听端口持久化在路由的 proxy_port 中，
+cloudflared 的 ingress 直接指向这些端口。cftunnel install 会将其注册为系统服务。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if serveConfigDir != "" {
+			config.SetDir(serveConfigDir)
+		}
+		if serveMetricsAddr != "" {
+			stopMetrics, err := serveMetrics(serveMetricsAddr)
+			if err != nil {
+				return err
+			}
+			defer stopMetrics()
+		}
+		return service.Serve("cftunnel-authproxy", serveAuthProxies)
+	},
+}
+
+// configReloadInterval 配置文件变更检测间隔
+const configReloadInterval = 5 * time.Second
+
+// serveAuthProxies 启动代理并在配置文件变更时重载，直到 stop 关闭
+func serveAuthProxies(stop <-chan struct{}) error {
+	var proxies []*authproxy.Proxy
+	var mtime time.Time
+	defer func() { stopAuthProxies(proxies) }()
+
+	reload := func() error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		if _, err := ensureProxyPorts(cfg); err != nil {
+			return err
+		}
+		stopAuthProxies(proxies)
+		proxies, err = startAuthProxies(cfg)
+		if len(proxies) == 0 && err == nil {
+			log.Println("[authproxy] 没有启用鉴权或限流的路由，等待配置变更")
+		}
+		return err
+	}
+
+	// 记录重载后的 mtime（分配端口时会写回配置，避免重复触发）
+	stat := func() {
+		if fi, err := os.Stat(config.Path()); err == nil {
+			mtime = fi.ModTime()
+		}
+	}
+	if err := reload(); err != nil {
+		return err
+	}
+	stat()
+
+	ticker := time.NewTicker(configReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			log.Println("[authproxy] 正在停止")
+			return nil
+		case <-ticker.C:
+			fi, err := os.Stat(config.Path())
+			if err != nil || fi.ModTime().Equal(mtime) {
+				continue
+			}
+			log.Println("[authproxy] 配置已变更，重新加载")
+			if err := reload(); err != nil {
+				// 保持运行，等待下一次配置修正
+				log.Printf("[authproxy] 重新加载失败: %v", err)
+			}
+			stat()
+		}
+	}
+}
+
+// usedProxyPorts 返回已分配给路由代理的端口
+func usedProxyPorts(cfg *config.Config) map[int]bool {
+	used := map[int]bool{}
+	for _, r := range cfg.Routes {
+		if r.ProxyPort > 0 {
+			used[r.ProxyPort] = true
+		}
+	}
+	return used
+}
+
+// ensureProxyPorts 为尚未分配端口的鉴权 / 限流路由分配固定端口并写回配置
+func ensureProxyPorts(cfg *config.Config) (bool, error) {
+	used := usedProxyPorts(cfg)
+	changed := false
+	for i := range cfg.Routes {
+		r := &cfg.Routes[i]
+		if !r.Proxied() || r.ProxyPort > 0 {
+			continue
+		}
+		port, err := authproxy.AllocatePort(r.Service, used)
+		if err != nil {
+			return false, fmt.Errorf("路由 %s 分配代理端口失败: %w", r.Name, err)
+		}
+		r.ProxyPort = port
+		used[port] = true
+		changed = true
+	}
+	if changed {
+		if err := cfg.Save(); err != nil {
+			return false, err
+		}
+	}
+	return changed, nil
+}
+
+// startAuthProxies 在固定端口上启动所有鉴权 / 限流路由的代理，任一失败时关闭已启动的代理
+func startAuthProxies(cfg *config.Config) ([]*authproxy.Proxy, error) {
+	var proxies []*authproxy.Proxy
+	for _, r := range cfg.Routes {
+		if !r.Proxied() {
+			continue
+		}
+		proxyCfg, err := routeProxyConfig(r)
+		if err != nil {
+			stopAuthProxies(proxies)
+			return nil, err
+		}
+		proxy, err := authproxy.New(proxyCfg)
+		if err != nil {
+			stopAuthProxies(proxies)
+			return nil, fmt.Errorf("路由 %s 启动鉴权代理失败: %w", r.Name, err)
+		}
+		proxy.Start()
+		proxies = append(proxies, proxy)
+		trackProxies([]*authproxy.Proxy{proxy}, nil)
+		log.Printf("[authproxy] 代理已启动: %s → 127.0.0.1:%d → %s", r.Hostname, proxy.ListenPort(), r.Service)
+	}
+	return proxies, nil
+}
+
+func stopAuthProxies(proxies []*authproxy.Proxy) {
+	trackProxies(nil, proxies)
+	for _, p := range proxies {
+		p.Stop()
+	}
+}
+
+// proxyService 返回路由在 ingress 中实际指向的地址：启用鉴权或限流时为代理的固定端口
+func proxyService(r config.RouteConfig) (string, bool) {
+	if !r.Proxied() || r.ProxyPort == 0 {
+		return r.Service, false
+	}
+	return "http://localhost:" + strconv.Itoa(r.ProxyPort), true
+}
@@ -0,0 +1,120 @@
+package cmd
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/daemon"
+	"github.com/qingchencloud/cftunnel/internal/relay"
+	"github.com/qingchencloud/cftunnel/internal/verify"
+	"github.com/spf13/cobra"
+)
+
+var binCmd = &cobra.Command{
+	Use:   "bin",
+	Short: "管理 cloudflared / frp 二进制",
+}
+
+var binImportCmd = &cobra.Command{
+	Use:   "import <文件或目录>",
+	Short: "从本地发布包离线安装 cloudflared / frpc / frps",
+	Long: `从本地文件或目录离线安装（适用于无法访问 GitHub 的机器），可识别：
+  cloudflared、cloudflared-<系统>-<架构>[.exe|.tgz]
+  frp_<版本>_<系统>_<架构>.tar.gz / .zip（同时安装 frpc 与 frps）
+  解压出的 frpc / frps
+目录会递归查找，其它平台的文件会被忽略。原二进制保留为上一版本，可用 rollback 恢复。`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		files, err := importCandidates(args[0])
+		if err != nil {
+			return err
+		}
+
+		imported := 0
+		var cloudflared, frpc bool
+		for _, f := range files {
+			name := filepath.Base(f)
+			var binaries []string
+			switch {
+			case daemon.IsCloudflaredFile(name):
+				binaries = []string{"cloudflared"}
+			case relay.IsFrpArchive(name):
+				binaries = []string{"frpc", "frps"}
+			case name == "frpc" || name == "frpc.exe":
+				binaries = []string{"frpc"}
+			case name == "frps" || name == "frps.exe":
+				binaries = []string{"frps"}
+			default:
+				continue
+			}
+
+			sum, _ := verify.FileSum(f)
+			fmt.Printf("%s (SHA-256: %s)\n", f, sum)
+			for _, b := range binaries {
+				var version string
+				if b == "cloudflared" {
+					version, err = daemon.ImportCloudflared(f)
+				} else {
+					version, err = relay.ImportFrp(f, b)
+				}
+				if err != nil {
+					return fmt.Errorf("导入 %s 失败: %w", b, err)
+				}
+				fmt.Printf("✓ %s %s 已安装\n", b, version)
+				imported++
+				cloudflared = cloudflared || b == "cloudflared"
+				frpc = frpc || b == "frpc"
+			}
+		}
+		if imported == 0 {
+			return fmt.Errorf("%s 中没有适用于 %s/%s 的 cloudflared / frp 文件", args[0], runtime.GOOS, runtime.GOARCH)
+		}
+		fmt.Println("提示: 离线导入无法比对官方校验值，请确认上面的 SHA-256 与官方发布页一致")
+
+		// 与 upgrade 一致：重启正在运行的进程使新版本生效
+		if cloudflared {
+			if err := restartCloudflared(cfg); err != nil {
+				return err
+			}
+		}
+		if frpc {
+			return restartFrpc(cfg)
+		}
+		return nil
+	},
+}
+
+func init() {
+	binCmd.AddCommand(binImportCmd)
+	rootCmd.AddCommand(binCmd)
+}
+
+// importCandidates 返回待导入的文件：单个文件或目录下的所有普通文件
+func importCandidates(src string) ([]string, error) {
+	info, err := os.Stat(src)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return []string{src}, nil
+	}
+	var files []string
+	err = filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			files = append(files, path)
+		}
+		return nil
+	})
+	return files, err
+}
@@ -0,0 +1,208 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/qingchencloud/cftunnel/internal/binver"
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// managedBinary 由 cftunnel 下载托管的外部二进制（cloudflared / frpc），
+// version / upgrade / pin / rollback 子命令与启动前的版本同步共用
+type managedBinary struct {
+	name       string
+	path       func() string                    // bin 目录中的托管路径
+	version    func(path string) string         // 探测二进制版本
+	latest     func() (string, error)           // 查询最新发布版本
+	pin        func(cfg *config.Config) *string // 配置中的锁定版本字段
+	autoUpdate func(cfg *config.Config) bool
+	install    func(version string) error // 下载校验并替换，旧版本保留供回滚
+	rollback   func() error
+	restart    func(cfg *config.Config) error // 升级/回滚后平滑重启正在运行的进程
+}
+
+// unknownVersion 已安装但无法识别版本（如 --version 输出格式变化）
+const unknownVersion = "未知"
+
+// installed 返回托管二进制的当前版本，未安装时返回空，无法识别时返回 unknownVersion
+func (m *managedBinary) installed() string {
+	if _, err := os.Stat(m.path()); err != nil {
+		return ""
+	}
+	if v := m.version(m.path()); v != "" {
+		return v
+	}
+	return unknownVersion
+}
+
+// switchTo 安装指定版本并重启正在运行的进程
+func (m *managedBinary) switchTo(cfg *config.Config, version string) error {
+	if err := m.install(version); err != nil {
+		return err
+	}
+	fmt.Printf("✓ %s 已切换到 %s（上一版本已保留，可执行 rollback 回退）\n", m.name, m.installed())
+	return m.restart(cfg)
+}
+
+// sync 启动前按配置同步版本：锁定版本与当前不一致时切换，开启自动更新时升级到最新版
+// 失败仅提示，不阻止启动；未安装时由首次下载处理，版本无法识别时不自动切换，避免每次启动都重新下载
+func (m *managedBinary) sync(cfg *config.Config) {
+	current := m.installed()
+	if current == "" {
+		return
+	}
+	if current == unknownVersion {
+		if *m.pin(cfg) != "" || m.autoUpdate(cfg) {
+			fmt.Printf("警告: 无法识别 %s 当前版本（%s），跳过版本同步，如需切换请执行 upgrade\n", m.name, m.path())
+		}
+		return
+	}
+	target := binver.Normalize(*m.pin(cfg))
+	if target == "" && m.autoUpdate(cfg) {
+		latest, err := m.latest()
+		if err != nil {
+			fmt.Printf("警告: 检查 %s 更新失败: %v\n", m.name, err)
+			return
+		}
+		target = latest
+	}
+	if target == "" || target == current {
+		return
+	}
+	fmt.Printf("%s %s → %s\n", m.name, current, target)
+	if err := m.install(target); err != nil {
+		fmt.Printf("警告: 切换 %s 版本失败: %v（继续使用 %s）\n", m.name, err, current)
+	}
+}
+
+// command 构建 version / upgrade / pin / rollback 子命令
+func (m *managedBinary) command(use, short string) *cobra.Command {
+	parent := &cobra.Command{Use: use, Short: short}
+
+	parent.AddCommand(&cobra.Command{
+		Use:   "version",
+		Short: "查看已安装、上一版本、锁定与最新版本",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if v := m.installed(); v != "" {
+				fmt.Printf("当前版本: %s (%s)\n", v, m.path())
+			} else {
+				fmt.Printf("当前版本: 未安装（首次启动时自动下载）\n")
+			}
+			if prev := binver.PrevPath(m.path()); fileExists(prev) {
+				fmt.Printf("上一版本: %s（可回滚）\n", m.version(prev))
+			}
+			if pin := *m.pin(cfg); pin != "" {
+				fmt.Printf("锁定版本: %s\n", pin)
+			} else {
+				fmt.Printf("锁定版本: 未锁定（自动更新: %v）\n", m.autoUpdate(cfg))
+			}
+			if latest, err := m.latest(); err != nil {
+				fmt.Printf("最新版本: 查询失败 (%v)\n", err)
+			} else {
+				fmt.Printf("最新版本: %s\n", latest)
+			}
+			return nil
+		},
+	})
+
+	var upgradeVersion string
+	upgrade := &cobra.Command{
+		Use:   "upgrade",
+		Short: "升级到最新版（已锁定时升级到锁定版本），并重启正在运行的进程",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			target := binver.Normalize(upgradeVersion)
+			if target == "" {
+				target = binver.Normalize(*m.pin(cfg))
+			}
+			if target == "" {
+				fmt.Println("正在检查更新...")
+				if target, err = m.latest(); err != nil {
+					return fmt.Errorf("检查更新失败: %w", err)
+				}
+			}
+			current := m.installed()
+			if current == target {
+				fmt.Printf("%s 已是 %s\n", m.name, target)
+				return nil
+			}
+			if current != "" {
+				fmt.Printf("%s %s → %s\n", m.name, current, target)
+			}
+			return m.switchTo(cfg, target)
+		},
+	}
+	upgrade.Flags().StringVar(&upgradeVersion, "version", "", "安装指定版本（不修改锁定设置）")
+	parent.AddCommand(upgrade)
+
+	var unpin bool
+	pin := &cobra.Command{
+		Use:   "pin [版本]",
+		Short: "锁定版本（不再自动更新），与当前版本不一致时立即切换",
+		Args:  cobra.MaximumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if unpin == (len(args) == 1) {
+				return fmt.Errorf("请指定要锁定的版本，或使用 --unset 取消锁定")
+			}
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			field := m.pin(cfg)
+			if unpin {
+				*field = ""
+				if err := cfg.Save(); err != nil {
+					return err
+				}
+				fmt.Printf("已取消 %s 版本锁定\n", m.name)
+				return nil
+			}
+			target := binver.Normalize(args[0])
+			*field = target
+			if err := cfg.Save(); err != nil {
+				return err
+			}
+			fmt.Printf("已锁定 %s 版本: %s\n", m.name, target)
+			if current := m.installed(); current == "" || current == target {
+				return nil
+			}
+			return m.switchTo(cfg, target)
+		},
+	}
+	pin.Flags().BoolVar(&unpin, "unset", false, "取消版本锁定")
+	parent.AddCommand(pin)
+
+	parent.AddCommand(&cobra.Command{
+		Use:   "rollback",
+		Short: "切换回上一版本（再次执行可撤销），并重启正在运行的进程",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, err := config.Load()
+			if err != nil {
+				return err
+			}
+			if err := m.rollback(); err != nil {
+				return err
+			}
+			fmt.Printf("✓ %s 已回滚到 %s\n", m.name, m.installed())
+			if pin := *m.pin(cfg); pin != "" && pin != m.installed() {
+				fmt.Printf("提示: 已锁定版本 %s，下次启动时会切换回锁定版本，可执行 pin --unset 取消\n", pin)
+			}
+			return m.restart(cfg)
+		},
+	})
+	return parent
+}
+
+func fileExists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
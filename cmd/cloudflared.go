@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"os/exec"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/daemon"
+	"github.com/qingchencloud/cftunnel/internal/service"
+)
+
+// cloudflaredBinary bin 目录中托管的 cloudflared
+var cloudflaredBinary = &managedBinary{
+	name:       "cloudflared",
+	path:       daemon.CloudflaredPath,
+	version:    daemon.CloudflaredVersion,
+	latest:     daemon.LatestCloudflaredVersion,
+	pin:        func(cfg *config.Config) *string { return &cfg.Cloudflared.Version },
+	autoUpdate: func(cfg *config.Config) bool { return cfg.Cloudflared.AutoUpdate },
+	install: func(version string) error {
+		if !fileExists(daemon.CloudflaredPath()) {
+			if p, err := exec.LookPath("cloudflared"); err == nil {
+				fmt.Printf("提示: 当前使用系统 PATH 中的 %s，安装托管版本后将优先使用托管版本\n", p)
+			}
+		}
+		return daemon.InstallCloudflared(version)
+	},
+	rollback: daemon.RollbackCloudflared,
+	restart:  restartCloudflared,
+}
+
+func init() {
+	rootCmd.AddCommand(cloudflaredBinary.command("cloudflared", "管理 cloudflared 版本（查看、升级、锁定、回滚）"))
+}
+
+// restartCloudflared 按运行方式平滑重启正在运行的隧道，使新版本生效
+func restartCloudflared(cfg *config.Config) error {
+	switch {
+	case service.New().Running():
+		fmt.Println("正在重启系统服务...")
+		if err := service.New().Restart(); err != nil {
+			return fmt.Errorf("重启服务失败: %w", err)
+		}
+	case daemon.SupervisorState() != nil:
+		// 守护进程检测到 cloudflared 退出后以新二进制重新拉起
+		fmt.Println("正在重启守护进程下的 cloudflared...")
+		return daemon.RestartChild()
+	case daemon.Running():
+		if err := daemon.Stop(); err != nil {
+			return err
+		}
+		return daemon.Start(tunnelArgs(cfg, ""), cfg.Tunnel.Token)
+	default:
+		return nil
+	}
+	fmt.Println("✓ 隧道已重启")
+	return nil
+}
@@ -0,0 +1,155 @@
+package cmd
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	daemonListen string
+	daemonToken  string
+)
+
+func init() {
+	daemonCmd.Flags().StringVar(&daemonListen, "listen", "", "监听地址：unix:/路径 或 127.0.0.1:端口（默认 unix 套接字，Windows 为 127.0.0.1:7800）")
+	daemonCmd.Flags().StringVar(&daemonToken, "token", "", "API Token（TCP 监听时必需，默认读取或生成 daemon.token）")
+	rootCmd.AddCommand(daemonCmd)
+}
+
+// defaultDaemonPort Windows 下管理 API 默认端口
+const defaultDaemonPort = "7800"
+
+var daemonCmd = &cobra.Command{
+	Use:   "daemon",
+	Short: "常驻运行本地管理 API（供 GUI / 脚本调用，JSON 格式）",
+	Long: `常驻运行本地管理 API，支持路由 / 中继规则增删、隧道与中继启停、状态、诊断和日志流。
+unix 套接字权限为 0600；监听 TCP 端口时仅允许本机地址，且请求需携带 Authorization: Bearer <token>。`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		ln, token, err := daemonListener()
+		if err != nil {
+			return err
+		}
+		srv := &http.Server{Handler: newAPIServer(token).handler()}
+		fmt.Printf("管理 API 已启动: %s\n", describeListener(ln))
+		if token != "" {
+			fmt.Printf("Token 文件: %s\n", daemonTokenPath())
+		}
+
+		return service.Serve("cftunnel-daemon", func(stop <-chan struct{}) error {
+			errCh := make(chan error, 1)
+			go func() { errCh <- srv.Serve(ln) }()
+			select {
+			case err := <-errCh:
+				return err
+			case <-stop:
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			log.Println("[daemon] 正在停止")
+			return srv.Shutdown(ctx)
+		})
+	},
+}
+
+// daemonSocketPath 默认 unix 套接字路径
+func daemonSocketPath() string {
+	return filepath.Join(config.Dir(), "cftunnel.sock")
+}
+
+// daemonTokenPath API Token 文件路径
+func daemonTokenPath() string {
+	return filepath.Join(config.Dir(), "daemon.token")
+}
+
+// daemonListener 按 --listen 创建监听器，TCP 模式返回需要校验的 Token
+func daemonListener() (net.Listener, string, error) {
+	listen := daemonListen
+	if listen == "" {
+		if runtime.GOOS == "windows" {
+			listen = "127.0.0.1:" + defaultDaemonPort
+		} else {
+			listen = "unix:" + daemonSocketPath()
+		}
+	}
+
+	if sock, ok := strings.CutPrefix(listen, "unix:"); ok {
+		os.MkdirAll(filepath.Dir(sock), 0700)
+		// 清理上次异常退出残留的套接字文件
+		if conn, err := net.Dial("unix", sock); err == nil {
+			conn.Close()
+			return nil, "", fmt.Errorf("管理 API 已在运行: %s", sock)
+		}
+		os.Remove(sock)
+		ln, err := net.Listen("unix", sock)
+		if err != nil {
+			return nil, "", fmt.Errorf("监听 %s 失败: %w", sock, err)
+		}
+		if err := os.Chmod(sock, 0600); err != nil {
+			ln.Close()
+			return nil, "", err
+		}
+		// 显式指定 --token 时套接字同样校验
+		return ln, daemonToken, nil
+	}
+
+	host, _, err := net.SplitHostPort(listen)
+	if err != nil {
+		return nil, "", fmt.Errorf("监听地址格式无效: %s", listen)
+	}
+	if ip := net.ParseIP(host); host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return nil, "", fmt.Errorf("管理 API 仅允许监听本机地址: %s", listen)
+	}
+	token, err := loadDaemonToken()
+	if err != nil {
+		return nil, "", err
+	}
+	ln, err := net.Listen("tcp", listen)
+	if err != nil {
+		return nil, "", fmt.Errorf("监听 %s 失败: %w", listen, err)
+	}
+	return ln, token, nil
+}
+
+// loadDaemonToken 返回 --token、CFTUNNEL_DAEMON_TOKEN 或 daemon.token 中的 Token，均不存在时生成
+func loadDaemonToken() (string, error) {
+	if daemonToken != "" {
+		return daemonToken, nil
+	}
+	if v := os.Getenv("CFTUNNEL_DAEMON_TOKEN"); v != "" {
+		return v, nil
+	}
+	if data, err := os.ReadFile(daemonTokenPath()); err == nil {
+		if t := strings.TrimSpace(string(data)); t != "" {
+			return t, nil
+		}
+	}
+	b := make([]byte, 24)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	os.MkdirAll(config.Dir(), 0700)
+	if err := os.WriteFile(daemonTokenPath(), []byte(token+"\n"), 0600); err != nil {
+		return "", fmt.Errorf("保存 Token 失败: %w", err)
+	}
+	return token, nil
+}
+
+func describeListener(ln net.Listener) string {
+	if ln.Addr().Network() == "unix" {
+		return "unix:" + ln.Addr().String()
+	}
+	return "http://" + ln.Addr().String()
+}
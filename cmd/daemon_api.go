@@ -0,0 +1,259 @@
+package cmd
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+	"github.com/qingchencloud/cftunnel/internal/relay"
+)
+
+// apiServer 本地管理 API，所有操作复用 CLI 命令的实现
+type apiServer struct {
+	token string
+	mu    sync.Mutex // 串行化修改配置 / 启停进程的操作
+}
+
+func newAPIServer(token string) *apiServer {
+	return &apiServer{token: token}
+}
+
+func (s *apiServer) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /v1/status", s.status)
+	mux.HandleFunc("GET /v1/diagnose", s.diagnose)
+	mux.HandleFunc("GET /v1/logs", s.logs)
+
+	mux.HandleFunc("GET /v1/routes", s.listRoutes)
+	mux.HandleFunc("POST /v1/routes", s.addRoute)
+	mux.HandleFunc("DELETE /v1/routes/{name}", s.removeRoute)
+	mux.HandleFunc("POST /v1/tunnel/start", s.tunnelStart)
+	mux.HandleFunc("POST /v1/tunnel/stop", s.tunnelStop)
+
+	mux.HandleFunc("GET /v1/relay/rules", s.listRelayRules)
+	mux.HandleFunc("POST /v1/relay/rules", s.addRelayRule)
+	mux.HandleFunc("DELETE /v1/relay/rules/{name}", s.removeRelayRule)
+	mux.HandleFunc("POST /v1/relay/start", s.relayStart)
+	mux.HandleFunc("POST /v1/relay/stop", s.relayStop)
+
+	// Prometheus 指标（同样需要 Token）
+	mux.Handle("GET /metrics", newMetricsRegistry())
+	return s.authenticate(mux)
+}
+
+// authenticate 校验 Authorization: Bearer <token>（未设置 Token 时放行）
+func (s *apiServer) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.token != "" {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) != 1 {
+				writeError(w, http.StatusUnauthorized, errors.New("Token 无效"))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, err error) {
+	writeJSON(w, status, map[string]string{"error": err.Error()})
+}
+
+// writeOK 返回操作结果，err 非空时返回 400
+func writeOK(w http.ResponseWriter, err error) {
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ok": true})
+}
+
+func (s *apiServer) status(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, buildStatus(cfg))
+}
+
+func (s *apiServer) diagnose(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, diagnoseRoutes(cfg))
+}
+
+// logs 以 JSON Lines 流式输出日志：?source=cloudflared|relay|auth|all&lines=100&follow=true&level=error&since=1h&grep=...
+func (s *apiServer) logs(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	sources, err := logSources(q.Get("source"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	n := 100
+	if v := q.Get("lines"); v != "" {
+		if n, _ = strconv.Atoi(v); n <= 0 {
+			writeError(w, http.StatusBadRequest, fmt.Errorf("lines 无效: %s", v))
+			return
+		}
+	}
+	follow, _ := strconv.ParseBool(q.Get("follow"))
+	filter, err := newLogFilter(q.Get("level"), q.Get("since"), q.Get("grep"))
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/x-ndjson; charset=utf-8")
+	enc := json.NewEncoder(w)
+	flusher, _ := w.(http.Flusher)
+	err = streamLogs(r.Context(), sources, n, follow, filter, func(e logfile.Entry) {
+		enc.Encode(e)
+		if flusher != nil {
+			flusher.Flush()
+		}
+	})
+	if err != nil {
+		enc.Encode(map[string]string{"error": err.Error()})
+	}
+}
+
+func (s *apiServer) listRoutes(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	// 复用 status 的路由视图，不暴露密码和签名密钥
+	routes := []RouteStatus{}
+	if cs := buildStatus(cfg).Cloud; cs != nil {
+		routes = append(routes, cs.Routes...)
+	}
+	writeJSON(w, http.StatusOK, routes)
+}
+
+// routeRequest 添加路由请求
+type routeRequest struct {
+	Name   string `json:"name"`
+	Target string `json:"target"` // 端口或上游地址，与 cftunnel add 的第二个参数一致
+	Domain string `json:"domain"`
+	Auth   string `json:"auth,omitempty"` // 用户名:密码
+}
+
+func (s *apiServer) addRoute(w http.ResponseWriter, r *http.Request) {
+	var req routeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("请求格式无效: %w", err))
+		return
+	}
+	if req.Name == "" || req.Target == "" || req.Domain == "" {
+		writeError(w, http.StatusBadRequest, errors.New("name、target、domain 不能为空"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	route, err := addRoute(req.Name, req.Target, req.Domain, req.Auth)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	rs := RouteStatus{Name: route.Name, Hostname: route.Hostname, Service: route.Service, Auth: route.Auth != nil, ProxyPort: route.ProxyPort}
+	writeJSON(w, http.StatusCreated, rs)
+}
+
+func (s *apiServer) removeRoute(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeOK(w, removeRoute(r.PathValue("name")))
+}
+
+func (s *apiServer) tunnelStart(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	pid, err := tunnelUpDetached()
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]any{"ok": true, "pid": pid})
+}
+
+func (s *apiServer) tunnelStop(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeOK(w, tunnelDown())
+}
+
+func (s *apiServer) listRelayRules(w http.ResponseWriter, r *http.Request) {
+	cfg, err := config.Load()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err)
+		return
+	}
+	rules := []RuleStatus{}
+	if rs := buildStatus(cfg).Relay; rs != nil {
+		rules = append(rules, rs.Rules...)
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func (s *apiServer) addRelayRule(w http.ResponseWriter, r *http.Request) {
+	var req RuleStatus
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, fmt.Errorf("请求格式无效: %w", err))
+		return
+	}
+	if req.Name == "" || req.LocalPort <= 0 {
+		writeError(w, http.StatusBadRequest, errors.New("name、local_port 不能为空"))
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := addRelayRule(config.RelayRule{
+		Name:       req.Name,
+		Proto:      req.Proto,
+		LocalPort:  req.LocalPort,
+		RemotePort: req.RemotePort,
+		Domain:     req.Domain,
+	})
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, req)
+}
+
+func (s *apiServer) removeRelayRule(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeOK(w, removeRelayRule(r.PathValue("name")))
+}
+
+func (s *apiServer) relayStart(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeOK(w, relay.Start())
+}
+
+func (s *apiServer) relayStop(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	writeOK(w, relay.Stop())
+}
@@ -28,16 +28,7 @@ var diagnoseCmd = &cobra.Command{
 			return err
 		}
 
-		var routes []daemon.RouteInput
-		for _, r := range cfg.Routes {
-			routes = append(routes, daemon.RouteInput{
-				Name:     r.Name,
-				Hostname: r.Hostname,
-				Service:  r.Service,
-			})
-		}
-
-		result := daemon.Diagnose(routes)
+		result := diagnoseRoutes(cfg)
 
 		if diagnoseJSON {
 			enc := json.NewEncoder(os.Stdout)
@@ -50,6 +41,19 @@ var diagnoseCmd = &cobra.Command{
 	},
 }
 
+// diagnoseRoutes 诊断所有路由（CLI 与管理 API 共用）
+func diagnoseRoutes(cfg *config.Config) daemon.DiagnoseResult {
+	var routes []daemon.RouteInput
+	for _, r := range cfg.Routes {
+		routes = append(routes, daemon.RouteInput{
+			Name:     r.Name,
+			Hostname: r.Hostname,
+			Service:  r.Service,
+		})
+	}
+	return daemon.Diagnose(routes)
+}
+
 func printDiagnose(r daemon.DiagnoseResult) {
 	fmt.Println("Cloud 链路诊断")
 	fmt.Println("==============")
@@ -75,6 +79,18 @@ func printDiagnose(r daemon.DiagnoseResult) {
 	} else {
 		fmt.Printf("Cloudflare API: ✗ %s\n", a.Err)
 	}
+
+	// 边缘连通性（决定 protocol: auto 的选择）
+	e := r.Edge
+	quic, http2 := "✓", "✓"
+	if !e.QUIC {
+		quic = "✗ " + e.QUICErr
+	}
+	if !e.HTTP2 {
+		http2 = "✗ " + e.HTTP2Err
+	}
+	fmt.Printf("Cloudflare 边缘: QUIC (UDP 7844) %s / http2 (TCP 7844) %s\n", quic, http2)
+	fmt.Printf("  protocol: auto 将使用 %s\n", e.Protocol)
 	fmt.Println()
 
 	if len(r.Routes) == 0 {
@@ -13,6 +13,15 @@ var downCmd = &cobra.Command{
 	Use:   "down",
 	Short: "停止隧道",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		return daemon.Stop()
+		return tunnelDown()
 	},
 }
+
+// tunnelDown 停止隧道（CLI 与管理 API 共用）
+func tunnelDown() error {
+	// 守护模式下由守护进程负责停止 cloudflared，避免被当作崩溃重启
+	if daemon.SupervisorState() != nil {
+		return daemon.StopSupervisor()
+	}
+	return daemon.Stop()
+}
@@ -1,15 +1,26 @@
 package cmd
 
 import (
+	"cmp"
+	"context"
 	"fmt"
+	"os"
 
+	"github.com/qingchencloud/cftunnel/internal/cfapi"
 	"github.com/qingchencloud/cftunnel/internal/config"
 	"github.com/qingchencloud/cftunnel/internal/daemon"
 	"github.com/qingchencloud/cftunnel/internal/service"
 	"github.com/spf13/cobra"
 )
 
+var (
+	installProtocol string
+	installUser     bool
+)
+
 func init() {
+	installCmd.Flags().StringVar(&installProtocol, "protocol", "", "cloudflared 传输协议 auto|quic|http2（覆盖 config.yml 中的 cloudflared.protocol）")
+	installCmd.Flags().BoolVar(&installUser, "user", false, "注册为当前用户的服务（Linux 下为 systemd 用户级单元，无需 root）")
 	rootCmd.AddCommand(installCmd)
 	rootCmd.AddCommand(uninstallCmd)
 }
@@ -18,27 +29,68 @@ var installCmd = &cobra.Command{
 	Use:   "install",
 	Short: "注册为系统服务（开机自启）",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		if config.Portable() {
-			return fmt.Errorf("便携模式下不支持注册系统服务（路径不固定），请使用 cftunnel up 手动启动")
-		}
-		cfg, err := config.Load()
-		if err != nil {
+		return registerService(installUser, installProtocol, false)
+	},
+}
+
+// registerService 注册隧道（及鉴权代理）系统服务，reinstall 时替换已注册的隧道服务（失败时恢复原服务）
+// （install 与 service reinstall 共用）
+func registerService(user bool, protocol string, reinstall bool) error {
+	if config.Portable() {
+		return fmt.Errorf("便携模式下不支持注册系统服务（路径不固定），请使用 cftunnel up 手动启动")
+	}
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Tunnel.Token == "" {
+		return fmt.Errorf("请先运行 cftunnel init && cftunnel create <名称>")
+	}
+	if err := checkProtocol(cmp.Or(protocol, cfg.Cloudflared.Protocol)); err != nil {
+		return err
+	}
+	binPath, err := daemon.EnsureCloudflared(os.Stdout)
+	if err != nil {
+		return err
+	}
+	svc := serviceFor(user)
+	proxied := hasProxiedRoutes(cfg)
+	if proxied {
+		if _, err := ensureProxyPorts(cfg); err != nil {
 			return err
 		}
-		if cfg.Tunnel.Token == "" {
-			return fmt.Errorf("请先运行 cftunnel init && cftunnel create <名称>")
-		}
-		binPath, err := daemon.EnsureCloudflared()
+	}
+
+	// 先注册隧道服务：失败时 sidecar 与远端 ingress 均未改动
+	install := svc.Install
+	if reinstall {
+		install = svc.Reinstall
+	} else if svc.Legacy() {
+		fmt.Println("检测到旧版服务定义（隧道令牌写在命令行参数中），正在迁移为环境变量注入...")
+	}
+	if err := install(binPath, tunnelArgs(cfg, protocol), cfg.Tunnel.Token); err != nil {
+		return fmt.Errorf("注册服务失败: %w", err)
+	}
+	fmt.Println("系统服务已注册，隧道将开机自启")
+
+	// 鉴权 / 限流路由：注册 sidecar，并让 ingress 指向代理；失败时代理路由返回 502，不会绕过鉴权
+	if proxied {
+		self, err := os.Executable()
 		if err != nil {
 			return err
 		}
-		svc := service.New()
-		if err := svc.Install(binPath, cfg.Tunnel.Token); err != nil {
-			return fmt.Errorf("注册服务失败: %w", err)
+		if err := svc.InstallAuthProxy(self, config.Dir()); err != nil {
+			return fmt.Errorf("隧道服务已注册，但注册鉴权代理服务失败: %w（修复后重新执行 install）", err)
 		}
-		fmt.Println("系统服务已注册，隧道将开机自启")
-		return nil
-	},
+		fmt.Println("鉴权代理服务已注册")
+		client := cfapi.New(cfg.Auth.APIToken, cfg.Auth.AccountID)
+		if err := pushIngress(client, context.Background(), cfg); err != nil {
+			return fmt.Errorf("同步 ingress 失败: %w（修复后重新执行 install）", err)
+		}
+		fmt.Println("ingress 配置已同步")
+	}
+	printUserHint(user)
+	return nil
 }
 
 var uninstallCmd = &cobra.Command{
@@ -46,6 +98,10 @@ var uninstallCmd = &cobra.Command{
 	Short: "卸载系统服务",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		svc := service.New()
+		// 鉴权代理服务可能未注册，忽略错误
+		if svc.UninstallAuthProxy() == nil {
+			fmt.Println("鉴权代理服务已卸载")
+		}
 		if err := svc.Uninstall(); err != nil {
 			return fmt.Errorf("卸载服务失败: %w", err)
 		}
@@ -53,3 +109,21 @@ var uninstallCmd = &cobra.Command{
 		return nil
 	},
 }
+
+// serviceFor 按 --user 返回用户级或系统级服务管理
+func serviceFor(user bool) service.Service {
+	if user {
+		return service.NewUser()
+	}
+	return service.New()
+}
+
+// printUserHint 用户级服务注册后输出需要注意的事项（如 Linux 的 linger）
+func printUserHint(user bool) {
+	if !user {
+		return
+	}
+	if hint := service.UserHint(); hint != "" {
+		fmt.Println("提示: " + hint)
+	}
+}
@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+	"github.com/spf13/cobra"
+)
+
+func init() {
+	rootCmd.AddCommand(logpipeCmd)
+}
+
+// logpipeCmd 将标准输入写入轮转日志文件，供后台运行的 frpc 等子进程使用（内部命令）
+// 带 -- <命令> 时运行该命令并记录其标准输出和错误，供 OpenRC 等只能分别重定向输出的 init 系统使用
+var logpipeCmd = &cobra.Command{
+	Use:    "logpipe <日志文件> [-- <命令> [参数...]]",
+	Short:  "将标准输入或指定命令的输出写入轮转日志文件（内部使用）",
+	Hidden: true,
+	Args:   cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) > 1 && cmd.ArgsLenAtDash() != 1 {
+			return fmt.Errorf("命令需写在 -- 之后: logpipe <日志文件> -- <命令> [参数...]")
+		}
+		cfg, err := config.Load()
+		if err != nil {
+			cfg = &config.Config{}
+		}
+		w, err := logfile.Open(args[0], logOptions(cfg))
+		if err != nil {
+			return err
+		}
+		defer w.Close()
+		if len(args) > 1 {
+			code, err := runLogged(w, args[1], args[2:])
+			if err != nil {
+				return err
+			}
+			w.Close()
+			os.Exit(code)
+		}
+		_, err = io.Copy(w, os.Stdin)
+		return err
+	},
+}
+
+// runLogged 运行命令并将其输出写入 w，转发终止信号，返回命令的退出码
+func runLogged(w io.Writer, name string, args []string) (int, error) {
+	child := exec.Command(name, args...)
+	child.Stdout, child.Stderr = w, w
+	if err := child.Start(); err != nil {
+		return 0, err
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(sig)
+	go func() {
+		for s := range sig {
+			child.Process.Signal(s)
+		}
+	}()
+
+	err := child.Wait()
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		// 被信号终止时 ExitCode 为 -1
+		return max(exitErr.ExitCode(), 1), nil
+	}
+	return 0, err
+}
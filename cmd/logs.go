@@ -2,23 +2,53 @@ package cmd
 
 import (
 	"bufio"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
+	"os/exec"
+	"os/signal"
 	"path/filepath"
+	"regexp"
 	"runtime"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/qingchencloud/cftunnel/internal/authproxy"
 	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+	"github.com/qingchencloud/cftunnel/internal/relay"
 	"github.com/spf13/cobra"
 )
 
-var follow bool
+var (
+	follow     bool
+	logsSource string
+	logsLines  int
+	logsLevel  string
+	logsSince  string
+	logsGrep   string
+	logsJSON   bool
+)
 
 func init() {
 	logsCmd.Flags().BoolVarP(&follow, "follow", "f", false, "实时跟踪日志")
+	logsCmd.Flags().StringVar(&logsSource, "source", "cloudflared", "日志来源：cloudflared / relay / auth / all")
+	addLogFilterFlags(logsCmd)
 	rootCmd.AddCommand(logsCmd)
 }
 
+// addLogFilterFlags 注册日志行数与过滤参数（logs 与 relay logs 共用）
+func addLogFilterFlags(cmd *cobra.Command) {
+	cmd.Flags().IntVarP(&logsLines, "lines", "n", 100, "显示最后 N 行")
+	cmd.Flags().StringVar(&logsLevel, "level", "", "仅显示该级别及以上的日志：debug / info / warn / error / fatal")
+	cmd.Flags().StringVar(&logsSince, "since", "", "仅显示该时间之后的日志，如 1h、30m 或 2006-01-02 15:04")
+	cmd.Flags().StringVar(&logsGrep, "grep", "", "仅显示匹配正则表达式的日志")
+	cmd.Flags().BoolVar(&logsJSON, "json", false, "以 JSON Lines 输出结构化日志")
+}
+
 // logFilePath 根据操作系统返回日志文件路径
 func logFilePath() string {
 	// 便携模式：日志放在程序同级目录
@@ -40,62 +70,343 @@ func logFilePath() string {
 	}
 }
 
+// authLogPath 返回路由鉴权代理的访问日志路径（与 cloudflared 日志同目录）
+func authLogPath(route string) string {
+	return filepath.Join(filepath.Dir(logFilePath()), "cftunnel-auth-"+route+".log")
+}
+
+// logOptions 返回 cloudflared / frpc 日志的轮转参数
+func logOptions(cfg *config.Config) logfile.Options {
+	opts := logfile.Options{MaxAge: 30 * 24 * time.Hour, Compress: true}
+	if l := cfg.Logs; l != nil {
+		opts.MaxSize = int64(l.MaxSizeMB) << 20
+		opts.MaxBackups = l.MaxBackups
+		if l.MaxAgeDays > 0 {
+			opts.MaxAge = time.Duration(l.MaxAgeDays) * 24 * time.Hour
+		}
+		if l.Compress != nil {
+			opts.Compress = *l.Compress
+		}
+	}
+	return opts
+}
+
+// isTerminal 判断文件是否为终端（前台运行）
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
 var logsCmd = &cobra.Command{
 	Use:   "logs",
-	Short: "查看隧道日志",
+	Short: "查看日志（cloudflared、中继客户端、鉴权代理）",
+	Long: `查看日志，--source all 时按时间合并所有来源并以彩色前缀区分。
+Linux 下隧道或中继以 systemd 服务（含 --user 用户级服务）运行时，日志写入 journal，自动改用 journalctl 读取。`,
 	RunE: func(cmd *cobra.Command, args []string) error {
-		logFile := logFilePath()
-		f, err := os.Open(logFile)
+		return printLog(logsSource, follow)
+	},
+}
+
+// printLog 按命令行参数输出日志（logs 与 relay logs 共用）
+func printLog(source string, follow bool) error {
+	sources, err := logSources(source)
+	if err != nil {
+		return err
+	}
+	filter, err := newLogFilter(logsLevel, logsSince, logsGrep)
+	if err != nil {
+		return err
+	}
+	if logsLines <= 0 {
+		return fmt.Errorf("--lines 必须大于 0")
+	}
+	enc := json.NewEncoder(os.Stdout)
+	prefix := len(sources) > 1
+	color := isTerminal(os.Stdout) && os.Getenv("NO_COLOR") == ""
+	ctx, cancel := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer cancel()
+	return streamLogs(ctx, sources, logsLines, follow, filter, func(e logfile.Entry) {
+		switch {
+		case logsJSON:
+			enc.Encode(e)
+		case prefix:
+			fmt.Println(sourcePrefix(e.Source, color) + e.Line)
+		default:
+			fmt.Println(e.Line)
+		}
+	})
+}
+
+// 来源前缀颜色（ANSI）
+var sourceColors = map[string]string{
+	"cloudflared": "36", // 青色
+	"relay":       "35", // 品红
+	"auth":        "33", // 黄色
+}
+
+func sourcePrefix(source string, color bool) string {
+	p := fmt.Sprintf("%-14s ", "["+source+"]")
+	if !color {
+		return p
+	}
+	kind, _, _ := strings.Cut(source, ":")
+	return "\033[" + sourceColors[kind] + "m" + p + "\033[0m"
+}
+
+// logSource 日志来源
+type logSource struct {
+	name    string             // cloudflared / relay / auth:<路由>
+	path    string             // 日志文件
+	unit    string             // systemd 单元，服务运行时日志在 journal 中
+	journal []string           // journalctl 选择该单元的参数，由 streamLogs 按服务级别填入
+	parse   logfile.LineParser // 为空时按 cloudflared / frpc 格式解析
+}
+
+// logSources 解析 --source：cloudflared（别名 tunnel）/ relay / auth / all
+func logSources(source string) ([]logSource, error) {
+	cloudflared := logSource{name: "cloudflared", path: logFilePath(), unit: "cftunnel"}
+	relaySrc := logSource{name: "relay", path: relay.LogFilePath(), unit: "cftunnel-relay"}
+	switch source {
+	case "", "cloudflared", "tunnel":
+		return []logSource{cloudflared}, nil
+	case "relay":
+		return []logSource{relaySrc}, nil
+	case "auth", "all":
+		cfg, err := config.Load()
 		if err != nil {
-			return fmt.Errorf("日志文件不存在: %s", logFile)
+			return nil, err
+		}
+		var sources []logSource
+		if source == "all" {
+			sources = append(sources, cloudflared, relaySrc)
 		}
-		defer f.Close()
+		for _, r := range cfg.Routes {
+			if r.Proxied() {
+				sources = append(sources, logSource{name: "auth:" + r.Name, path: authLogPath(r.Name), parse: parseAccessLine})
+			}
+		}
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("没有启用鉴权或限流的路由")
+		}
+		return sources, nil
+	}
+	return nil, fmt.Errorf("未知日志来源: %s（可选 cloudflared / relay / auth / all）", source)
+}
 
-		// 读取最后 100 行
-		lines, err := tailLines(f, 100)
+// parseAccessLine 解析鉴权代理访问日志（JSON Lines），失败访问记为 warn
+func parseAccessLine(line string) (logfile.Entry, bool) {
+	var a authproxy.AccessEntry
+	if err := json.Unmarshal([]byte(line), &a); err != nil {
+		return logfile.Entry{}, false
+	}
+	level := logfile.LevelInfo
+	if a.Failed() {
+		level = logfile.LevelWarn
+	}
+	text := formatAccessEntry(a)
+	return logfile.Entry{Time: a.Time, Level: level, Message: text, Line: text}, true
+}
+
+// journalArgs Linux 下对应的 systemd 服务（系统级或 --user 用户级）正在运行时，
+// 返回 journalctl 选择该服务的参数，否则为 nil
+func (s logSource) journalArgs() []string {
+	if runtime.GOOS != "linux" || s.unit == "" {
+		return nil
+	}
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return nil
+	}
+	if exec.Command("systemctl", "is-active", "--quiet", s.unit).Run() == nil {
+		return []string{"-u", s.unit}
+	}
+	if exec.Command("systemctl", "--user", "is-active", "--quiet", s.unit).Run() == nil {
+		return []string{"--user", "-u", s.unit}
+	}
+	return nil
+}
+
+// streamLogs 按时间合并输出各来源最后 n 条匹配的日志，follow 时持续跟踪直到 ctx 取消（CLI 与管理 API 共用）
+// 只有一个来源且日志不存在时返回错误，多来源时跳过不存在的来源
+func streamLogs(ctx context.Context, sources []logSource, n int, follow bool, filter *logFilter, emit func(e logfile.Entry)) error {
+	type followState struct {
+		src    logSource
+		offset int64
+	}
+	var all []logfile.Entry
+	var follows []followState
+	for _, src := range sources {
+		var entries []logfile.Entry
+		var err error
+		if src.journal = src.journalArgs(); src.journal != nil {
+			entries, err = journalTail(ctx, src, n, filter)
+			follows = append(follows, followState{src: src, offset: -1})
+		} else {
+			var size int64
+			if fi, statErr := os.Stat(src.path); statErr == nil {
+				size = fi.Size()
+			}
+			entries, err = logfile.Tail(src.path, n, src.parse, filter.match, filter.since)
+			if os.IsNotExist(err) {
+				if len(sources) == 1 {
+					return fmt.Errorf("日志文件不存在: %s", src.path)
+				}
+				err = nil
+			}
+			follows = append(follows, followState{src: src, offset: size})
+		}
 		if err != nil {
 			return err
 		}
-		for _, line := range lines {
-			fmt.Println(line)
+		for i := range entries {
+			entries[i].Source = src.name
 		}
+		all = append(all, entries...)
+	}
 
-		if !follow {
-			return nil
-		}
+	// 多来源按时间合并后取最后 n 条
+	if len(sources) > 1 {
+		sort.SliceStable(all, func(i, j int) bool { return all[i].Time.Before(all[j].Time) })
+	}
+	if len(all) > n {
+		all = all[len(all)-n:]
+	}
+	for _, e := range all {
+		emit(e)
+	}
+	if !follow {
+		return nil
+	}
 
-		// 实时跟踪：轮询文件变化
-		stat, _ := f.Stat()
-		offset := stat.Size()
-		for {
-			f2, err := os.Open(logFile)
-			if err != nil {
-				continue
-			}
-			stat2, _ := f2.Stat()
-			if stat2.Size() > offset {
-				f2.Seek(offset, 0)
-				scanner := bufio.NewScanner(f2)
-				for scanner.Scan() {
-					fmt.Println(scanner.Text())
+	// 实时跟踪：每个来源一个 goroutine，按到达顺序输出
+	ch := make(chan logfile.Entry, 64)
+	for _, fs := range follows {
+		go func(src logSource, offset int64) {
+			parser := logfile.Parser{Func: src.parse}
+			send := func(line string) {
+				e := parser.Parse(line)
+				e.Source = src.name
+				if filter.match(e) {
+					select {
+					case ch <- e:
+					case <-ctx.Done():
+					}
 				}
-				offset = stat2.Size()
 			}
-			f2.Close()
-			time.Sleep(500 * time.Millisecond)
+			if offset < 0 {
+				journalFollow(ctx, src, send)
+			} else {
+				logfile.Follow(ctx, src.path, offset, send)
+			}
+		}(fs.src, fs.offset)
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case e := <-ch:
+			emit(e)
 		}
-	},
+	}
 }
 
-// tailLines 读取文件最后 n 行
-func tailLines(f *os.File, n int) ([]string, error) {
-	scanner := bufio.NewScanner(f)
-	var lines []string
+// journalMaxLines 使用过滤条件且未指定 --since 时最多读取的 journal 行数
+const journalMaxLines = 10000
+
+// journalTail 通过 journalctl 读取 systemd 服务最后 n 条匹配的日志
+func journalTail(ctx context.Context, src logSource, n int, filter *logFilter) ([]logfile.Entry, error) {
+	args := append(src.journal, "-o", "cat", "--no-pager")
+	switch {
+	case !filter.since.IsZero():
+		args = append(args, "--since", filter.since.Format("2006-01-02 15:04:05"))
+	case filter.level > logfile.LevelDebug || filter.grep != nil:
+		args = append(args, "-n", strconv.Itoa(journalMaxLines))
+	default:
+		args = append(args, "-n", strconv.Itoa(n))
+	}
+	out, err := exec.CommandContext(ctx, "journalctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("读取 journal 失败 (%s): %w", src.unit, err)
+	}
+	var parser logfile.Parser
+	var entries []logfile.Entry
+	for _, line := range strings.Split(strings.TrimRight(string(out), "\n"), "\n") {
+		if e := parser.Parse(line); filter.match(e) {
+			entries = append(entries, e)
+		}
+	}
+	if len(entries) > n {
+		entries = entries[len(entries)-n:]
+	}
+	return entries, nil
+}
+
+// journalFollow 通过 journalctl -f 跟踪 systemd 服务的新日志
+func journalFollow(ctx context.Context, src logSource, emit func(line string)) error {
+	args := append(src.journal, "-o", "cat", "--no-pager", "-f", "-n", "0")
+	cmd := exec.CommandContext(ctx, "journalctl", args...)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
 	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-		if len(lines) > n {
-			lines = lines[1:]
+		emit(scanner.Text())
+	}
+	return cmd.Wait()
+}
+
+// logFilter 日志过滤条件，零值不过滤
+type logFilter struct {
+	level logfile.Level
+	since time.Time
+	grep  *regexp.Regexp
+}
+
+func newLogFilter(level, since, grep string) (*logFilter, error) {
+	f := &logFilter{level: logfile.LevelDebug}
+	var err error
+	if level != "" {
+		if f.level, err = logfile.ParseLevel(level); err != nil {
+			return nil, err
+		}
+	}
+	if since != "" {
+		if f.since, err = parseSince(since); err != nil {
+			return nil, err
 		}
 	}
-	return lines, scanner.Err()
+	if grep != "" {
+		if f.grep, err = regexp.Compile(grep); err != nil {
+			return nil, fmt.Errorf("--grep 正则无效: %w", err)
+		}
+	}
+	return f, nil
+}
+
+// parseSince 支持相对时长（1h、30m）和绝对时间
+func parseSince(s string) (time.Time, error) {
+	if d, err := time.ParseDuration(s); err == nil {
+		return time.Now().Add(-d), nil
+	}
+	for _, layout := range []string{time.RFC3339, "2006-01-02 15:04:05", "2006-01-02 15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("--since 格式无效: %s（示例: 1h、30m、2006-01-02 15:04）", s)
+}
+
+func (f *logFilter) match(e logfile.Entry) bool {
+	if e.Level < f.level {
+		return false
+	}
+	// 无法解析时间的行不按时间过滤
+	if !f.since.IsZero() && !e.Time.IsZero() && e.Time.Before(f.since) {
+		return false
+	}
+	return f.grep == nil || f.grep.MatchString(e.Line)
 }
@@ -0,0 +1,327 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/authproxy"
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/daemon"
+	"github.com/qingchencloud/cftunnel/internal/metrics"
+	"github.com/qingchencloud/cftunnel/internal/relay"
+)
+
+// probeInterval 链路探测（diagnose / relay check）结果的缓存时长
+const probeInterval = time.Minute
+
+// liveProxies 当前进程内运行的鉴权 / 限流代理，由 startAuthProxies / stopAuthProxies 维护
+var liveProxies struct {
+	sync.Mutex
+	list []*authproxy.Proxy
+}
+
+func trackProxies(add, remove []*authproxy.Proxy) {
+	liveProxies.Lock()
+	defer liveProxies.Unlock()
+	liveProxies.list = append(liveProxies.list, add...)
+	for _, p := range remove {
+		for i, q := range liveProxies.list {
+			if q == p {
+				liveProxies.list = append(liveProxies.list[:i], liveProxies.list[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// newMetricsRegistry 组装 /metrics：进程状态、代理请求统计、链路探测结果和 cloudflared 自身指标
+func newMetricsRegistry() *metrics.Registry {
+	reg := metrics.NewRegistry()
+	reg.Register(processMetrics)
+	reg.Register(proxyMetrics)
+	reg.Register(newProber(probeInterval).collect)
+	reg.RegisterRaw(cloudflaredMetrics)
+	return reg
+}
+
+// serveMetrics 在 addr 上提供 /metrics，返回关闭函数
+func serveMetrics(addr string) (func(), error) {
+	mux := http.NewServeMux()
+	mux.Handle("GET /metrics", newMetricsRegistry())
+	srv := &http.Server{Handler: mux}
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("监听指标地址 %s 失败: %w", addr, err)
+	}
+	go srv.Serve(ln)
+	log.Printf("[metrics] 指标已启动: http://%s/metrics", ln.Addr())
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}, nil
+}
+
+// processMetrics cloudflared / frpc 进程状态与守护重启次数
+func processMetrics() []metrics.Family {
+	up := metrics.Family{
+		Name: "cftunnel_process_up",
+		Help: "进程是否运行（1 运行，0 未运行）",
+		Type: metrics.TypeGauge,
+		Samples: []metrics.Sample{
+			{Labels: metrics.Labels{"process": "cloudflared"}, Value: metrics.Bool(daemon.Running())},
+			{Labels: metrics.Labels{"process": "frpc"}, Value: metrics.Bool(relay.Running())},
+		},
+	}
+	fams := []metrics.Family{up}
+
+	st := daemon.SupervisorState()
+	fams = append(fams, metrics.Family{
+		Name:    "cftunnel_supervisor_up",
+		Help:    "cloudflared 是否由守护进程（up --supervise）管理",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Labels: metrics.Labels{"process": "cloudflared"}, Value: metrics.Bool(st != nil)}},
+	})
+	if st != nil {
+		labels := metrics.Labels{"process": "cloudflared"}
+		fams = append(fams, metrics.Family{
+			Name:    "cftunnel_process_restarts_total",
+			Help:    "守护进程启动以来子进程的重启次数",
+			Type:    metrics.TypeCounter,
+			Samples: []metrics.Sample{{Labels: labels, Value: float64(st.Restarts)}},
+		}, metrics.Family{
+			Name:    "cftunnel_supervisor_start_time_seconds",
+			Help:    "守护进程启动时间（Unix 时间戳）",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Labels: labels, Value: float64(st.StartedAt.Unix())}},
+		})
+		if !st.LastExitAt.IsZero() {
+			fams = append(fams, metrics.Family{
+				Name:    "cftunnel_process_last_exit_time_seconds",
+				Help:    "子进程最近一次退出时间（Unix 时间戳）",
+				Type:    metrics.TypeGauge,
+				Samples: []metrics.Sample{{Labels: labels, Value: float64(st.LastExitAt.Unix())}},
+			})
+		}
+	}
+	return fams
+}
+
+// proxyMetrics 本进程内鉴权 / 限流代理的请求统计
+func proxyMetrics() []metrics.Family {
+	liveProxies.Lock()
+	proxies := append([]*authproxy.Proxy{}, liveProxies.list...)
+	liveProxies.Unlock()
+
+	var fams []metrics.Family
+	for _, p := range proxies {
+		fams = append(fams, p.Metrics()...)
+	}
+	return fams
+}
+
+// prober 缓存 diagnose / relay check 结果，过期后在后台刷新，避免每次抓取都发起网络探测
+type prober struct {
+	interval time.Duration
+
+	mu      sync.Mutex
+	running bool
+	at      time.Time
+	diag    *daemon.DiagnoseResult
+	relay   *relay.CheckResult
+}
+
+func newProber(interval time.Duration) *prober {
+	return &prober{interval: interval}
+}
+
+func (p *prober) refresh() {
+	cfg, err := config.Load()
+	var diag *daemon.DiagnoseResult
+	var rc *relay.CheckResult
+	if err == nil {
+		if cfg.Tunnel.Token != "" {
+			d := diagnoseRoutes(cfg)
+			diag = &d
+		}
+		if cfg.Relay.Server != "" {
+			c := relay.Check(&cfg.Relay, "")
+			rc = &c
+		}
+	} else {
+		log.Printf("[metrics] 读取配置失败: %v", err)
+	}
+
+	p.mu.Lock()
+	p.diag, p.relay, p.at, p.running = diag, rc, time.Now(), false
+	p.mu.Unlock()
+}
+
+func (p *prober) collect() []metrics.Family {
+	p.mu.Lock()
+	if !p.running && time.Since(p.at) > p.interval {
+		p.running = true
+		if p.at.IsZero() {
+			// 首次抓取同步探测，保证立即有数据
+			p.mu.Unlock()
+			p.refresh()
+			p.mu.Lock()
+		} else {
+			go p.refresh()
+		}
+	}
+	diag, rc, at := p.diag, p.relay, p.at
+	p.mu.Unlock()
+
+	fams := []metrics.Family{{
+		Name:    "cftunnel_probe_time_seconds",
+		Help:    "最近一次链路探测时间（Unix 时间戳）",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: float64(at.Unix())}},
+	}}
+	if diag != nil {
+		fams = append(fams, diagnoseMetrics(diag)...)
+	}
+	if rc != nil {
+		fams = append(fams, relayCheckMetrics(rc)...)
+	}
+	return fams
+}
+
+// diagnoseMetrics 将 cftunnel diagnose 的结果转换为指标
+func diagnoseMetrics(d *daemon.DiagnoseResult) []metrics.Family {
+	api := []metrics.Family{{
+		Name:    "cftunnel_api_up",
+		Help:    "Cloudflare API 是否可达",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: metrics.Bool(d.API.Reachable)}},
+	}}
+	if d.API.Reachable {
+		api = append(api, metrics.Family{
+			Name:    "cftunnel_api_latency_seconds",
+			Help:    "Cloudflare API 请求耗时",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Value: float64(d.API.LatencyMS) / 1000}},
+		})
+	}
+
+	check := metrics.Family{
+		Name: "cftunnel_route_check_up",
+		Help: "路由链路检测结果（check: local 本地服务，dns 解析，https 公网访问）",
+		Type: metrics.TypeGauge,
+	}
+	for _, r := range d.Routes {
+		labels := metrics.Labels{"route": r.Name, "hostname": r.Hostname}
+		check.Samples = append(check.Samples,
+			metrics.Sample{Labels: labels.With("check", "local"), Value: metrics.Bool(r.LocalOK)},
+			metrics.Sample{Labels: labels.With("check", "dns"), Value: metrics.Bool(r.DNSOK)},
+			metrics.Sample{Labels: labels.With("check", "https"), Value: metrics.Bool(r.HTTPOK)},
+		)
+	}
+	return append(api, check)
+}
+
+// relayCheckMetrics 将 cftunnel relay check 的结果转换为指标
+func relayCheckMetrics(c *relay.CheckResult) []metrics.Family {
+	server := metrics.Labels{"server": c.Server}
+	fams := []metrics.Family{{
+		Name:    "cftunnel_relay_server_up",
+		Help:    "frps 服务器是否可连接",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Labels: server, Value: metrics.Bool(c.ServerOK)}},
+	}}
+	if c.ServerOK {
+		fams = append(fams, metrics.Family{
+			Name:    "cftunnel_relay_server_latency_seconds",
+			Help:    "连接 frps 服务器的耗时",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Labels: server, Value: float64(c.ServerLatency) / 1000}},
+		})
+	}
+
+	check := metrics.Family{
+		Name: "cftunnel_relay_rule_up",
+		Help: "中继规则检测结果（check: local 本地端口，remote 远程端口）",
+		Type: metrics.TypeGauge,
+	}
+	latency := metrics.Family{
+		Name: "cftunnel_relay_rule_latency_seconds",
+		Help: "经 frps 访问规则远程端口的耗时",
+		Type: metrics.TypeGauge,
+	}
+	for _, r := range c.Rules {
+		labels := metrics.Labels{"rule": r.Name, "proto": r.Proto}
+		check.Samples = append(check.Samples,
+			metrics.Sample{Labels: labels.With("check", "local"), Value: metrics.Bool(r.LocalOK)},
+			metrics.Sample{Labels: labels.With("check", "remote"), Value: metrics.Bool(r.RemoteOK)},
+		)
+		if r.RemoteOK {
+			latency.Samples = append(latency.Samples, metrics.Sample{Labels: labels, Value: float64(r.LatencyMS) / 1000})
+		}
+	}
+	return append(fams, check, latency)
+}
+
+// cloudflaredMetrics 转发 cloudflared 自身的指标，并附加 tunnel 标签
+func cloudflaredMetrics(w io.Writer) {
+	addr := daemon.MetricsAddr()
+	ok := false
+	if addr != "" {
+		tunnel := ""
+		if cfg, err := config.Load(); err == nil {
+			tunnel = cfg.Tunnel.Name
+		}
+		ok = scrapeCloudflared(w, addr, tunnel) == nil
+	}
+	metrics.Write(w, []metrics.Family{{
+		Name:    "cftunnel_cloudflared_scrape_up",
+		Help:    "是否成功抓取 cloudflared 自身的指标",
+		Type:    metrics.TypeGauge,
+		Samples: []metrics.Sample{{Value: metrics.Bool(ok)}},
+	}})
+}
+
+func scrapeCloudflared(w io.Writer, addr, tunnel string) error {
+	client := &http.Client{Timeout: 3 * time.Second}
+	resp, err := client.Get("http://" + addr + "/metrics")
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+	label := `tunnel="` + metrics.EscapeLabel(tunnel) + `"`
+	sc := bufio.NewScanner(resp.Body)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		io.WriteString(w, relabel(sc.Text(), label)+"\n")
+	}
+	return sc.Err()
+}
+
+// relabel 为样本行追加标签，注释行原样返回
+func relabel(line, label string) string {
+	if line == "" || strings.HasPrefix(line, "#") {
+		return line
+	}
+	i := strings.IndexAny(line, "{ ")
+	if i < 0 {
+		return line
+	}
+	if line[i] == '{' {
+		if strings.HasPrefix(line[i+1:], "}") {
+			return line[:i+1] + label + line[i+1:]
+		}
+		return line[:i+1] + label + "," + line[i+1:]
+	}
+	return line[:i] + "{" + label + "}" + line[i:]
+}
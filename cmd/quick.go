@@ -2,7 +2,9 @@ package cmd
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/qingchencloud/cftunnel/internal/daemon"
 	"github.com/qingchencloud/cftunnel/internal/relay"
@@ -13,35 +15,78 @@ var (
 	quickAuth  string
 	quickRelay bool
 	quickProto string
+	quickJSON  bool
+	quickURL   string
+	quickHook  string
+	quickQR    bool
+	quickTTL   time.Duration
+	quickIdle  time.Duration
 )
 
 func init() {
 	quickCmd.Flags().StringVar(&quickAuth, "auth", "", "启用密码保护 (格式: 用户名:密码)")
 	quickCmd.Flags().BoolVar(&quickRelay, "relay", false, "使用中继模式穿透（需先 relay init）")
 	quickCmd.Flags().StringVar(&quickProto, "proto", "tcp", "中继协议 (tcp/udp)，仅 --relay 时有效")
+	quickCmd.Flags().BoolVar(&quickJSON, "json", false, "以 JSON Lines 输出事件（starting / url_ready / connection_registered / error / exiting）")
+	quickCmd.Flags().StringVar(&quickURL, "url-file", "", "地址就绪后写入该文件（每行一个，顺序同端口），退出时删除")
+	quickCmd.Flags().StringVar(&quickHook, "on-ready", "", "地址就绪后执行的命令，地址通过 CFTUNNEL_URL / CFTUNNEL_URLS 环境变量传入")
+	quickCmd.Flags().BoolVar(&quickQR, "qr", false, "地址就绪后在终端打印二维码")
+	quickCmd.Flags().DurationVar(&quickTTL, "ttl", 0, "有效期，到期自动关闭（如 30m、2h）")
+	quickCmd.Flags().DurationVar(&quickIdle, "idle-timeout", 0, "连续无访问流量超过该时长后自动关闭（如 30m）")
 	rootCmd.AddCommand(quickCmd)
 }
 
 var quickCmd = &cobra.Command{
-	Use:   "quick <端口>",
+	Use:   "quick <端口>...",
 	Short: "快速启动免域名隧道（生成 *.trycloudflare.com 随机域名）",
-	Long:  "无需 Cloudflare 账户、API Token 或域名，一条命令生成临时公网地址。\n适合临时分享、快速调试，Ctrl+C 退出后域名自动失效。",
-	Args:  cobra.ExactArgs(1),
+	Long: `无需 Cloudflare 账户、API Token 或域名，一条命令生成临时公网地址。
+适合临时分享、快速调试，Ctrl+C 退出后域名自动失效。
+可同时指定多个端口（如 quick 3000 8080 9000），每个端口一个独立地址，Ctrl+C 时一起退出。
+脚本或 AI 助手集成时可用 --json 读取事件流，或用 --url-file / --on-ready 获取地址，无需解析输出文本。
+临时分享建议加上 --ttl 2h 或 --idle-timeout 30m，忘记关闭也会按时自动失效（可与 --auth 同时使用）。`,
+	Args: cobra.MinimumNArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
+		if err := checkQuickPorts(args); err != nil {
+			return err
+		}
+		if quickTTL < 0 || quickIdle < 0 {
+			return fmt.Errorf("--ttl 和 --idle-timeout 不能为负数")
+		}
 		if quickRelay {
-			return relay.StartQuick(args[0], quickProto)
+			if quickJSON || quickURL != "" || quickHook != "" || quickQR || quickTTL > 0 || quickIdle > 0 {
+				return fmt.Errorf("--json、--url-file、--on-ready、--qr、--ttl、--idle-timeout 仅支持免域名模式，不能与 --relay 同时使用")
+			}
+			return relay.StartQuick(args, quickProto)
+		}
+		opts := daemon.QuickOptions{
+			Ports: args, JSON: quickJSON, URLFile: quickURL, OnReady: quickHook, QR: quickQR,
+			TTL: quickTTL, IdleTimeout: quickIdle,
 		}
 		if quickAuth != "" {
-			user, pass, err := parseAuth(quickAuth)
-			if err != nil {
+			var err error
+			if opts.Username, opts.Password, err = parseAuth(quickAuth); err != nil {
 				return err
 			}
-			return daemon.StartQuickWithAuth(args[0], user, pass)
 		}
-		return daemon.StartQuick(args[0])
+		return daemon.StartQuick(opts)
 	},
 }
 
+// checkQuickPorts 校验端口格式并拒绝重复端口
+func checkQuickPorts(ports []string) error {
+	seen := map[string]bool{}
+	for _, p := range ports {
+		if n, err := strconv.Atoi(p); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("端口格式错误: %s", p)
+		}
+		if seen[p] {
+			return fmt.Errorf("端口重复: %s", p)
+		}
+		seen[p] = true
+	}
+	return nil
+}
+
 // parseAuth 解析 "用户名:密码" 格式，密码部分允许包含冒号
 func parseAuth(s string) (string, string, error) {
 	idx := strings.Index(s, ":")
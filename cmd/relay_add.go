@@ -27,28 +27,14 @@ var relayAddCmd = &cobra.Command{
 	Short: "添加中继穿透规则",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
-
-		cfg, err := config.Load()
-		if err != nil {
-			return err
-		}
-		if cfg.Relay.Server == "" {
-			return fmt.Errorf("未配置中继服务器，请先执行 cftunnel relay init")
-		}
-		if cfg.FindRelayRule(name) != nil {
-			return fmt.Errorf("规则 %q 已存在", name)
-		}
-
 		rule := config.RelayRule{
-			Name:       name,
+			Name:       args[0],
 			Proto:      relayAddProto,
 			LocalPort:  relayAddLocal,
 			RemotePort: relayAddRemote,
 			Domain:     relayAddDomain,
 		}
-		cfg.Relay.Rules = append(cfg.Relay.Rules, rule)
-		if err := cfg.Save(); err != nil {
+		if err := addRelayRule(rule); err != nil {
 			return err
 		}
 
@@ -56,7 +42,26 @@ var relayAddCmd = &cobra.Command{
 		if relayAddRemote > 0 {
 			desc += " → :" + strconv.Itoa(relayAddRemote)
 		}
-		fmt.Printf("✔ 规则已添加: %s (%s %s)\n", name, relayAddProto, desc)
+		fmt.Printf("✔ 规则已添加: %s (%s %s)\n", rule.Name, relayAddProto, desc)
 		return nil
 	},
 }
+
+// addRelayRule 保存中继规则（CLI 与管理 API 共用）
+func addRelayRule(rule config.RelayRule) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if cfg.Relay.Server == "" {
+		return fmt.Errorf("未配置中继服务器，请先执行 cftunnel relay init")
+	}
+	if cfg.FindRelayRule(rule.Name) != nil {
+		return fmt.Errorf("规则 %q 已存在", rule.Name)
+	}
+	if rule.Proto == "" {
+		rule.Proto = "tcp"
+	}
+	cfg.Relay.Rules = append(cfg.Relay.Rules, rule)
+	return cfg.Save()
+}
@@ -0,0 +1,44 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/relay"
+	"github.com/qingchencloud/cftunnel/internal/service"
+)
+
+// frpcBinary bin 目录中托管的 frpc
+var frpcBinary = &managedBinary{
+	name:       "frpc",
+	path:       relay.FrpcPath,
+	version:    relay.FrpVersion,
+	latest:     relay.LatestFrpVersion,
+	pin:        func(cfg *config.Config) *string { return &cfg.Relay.FrpVersion },
+	autoUpdate: func(cfg *config.Config) bool { return cfg.Relay.FrpAutoUpdate },
+	install:    relay.InstallFrp,
+	rollback:   relay.RollbackFrp,
+	restart:    restartFrpc,
+}
+
+func init() {
+	relayCmd.AddCommand(frpcBinary.command("frp", "管理 frpc 版本（查看、升级、锁定、回滚）"))
+}
+
+// restartFrpc 按运行方式重启正在运行的中继客户端，使新版本生效
+func restartFrpc(cfg *config.Config) error {
+	switch {
+	case service.New().RelayRunning():
+		fmt.Println("正在重启中继系统服务...")
+		if err := service.New().RestartRelay(); err != nil {
+			return fmt.Errorf("重启服务失败: %w", err)
+		}
+		fmt.Println("✓ 中继客户端已重启")
+	case relay.Running():
+		if err := relay.Stop(); err != nil {
+			return err
+		}
+		return relay.Start()
+	}
+	return nil
+}
@@ -2,18 +2,17 @@ package cmd
 
 import (
 	"fmt"
-	"os"
-	"os/exec"
-	"path/filepath"
-	"runtime"
-	"text/template"
 
 	"github.com/qingchencloud/cftunnel/internal/config"
 	"github.com/qingchencloud/cftunnel/internal/relay"
+	"github.com/qingchencloud/cftunnel/internal/service"
 	"github.com/spf13/cobra"
 )
 
+var relayInstallUser bool
+
 func init() {
+	relayInstallCmd.Flags().BoolVar(&relayInstallUser, "user", false, "注册为当前用户的服务（Linux 下为 systemd 用户级单元，无需 root）")
 	relayCmd.AddCommand(relayInstallCmd)
 	relayCmd.AddCommand(relayUninstallCmd)
 }
@@ -39,160 +38,24 @@ var relayInstallCmd = &cobra.Command{
 		if err := relay.GenerateFrpcConfig(&cfg.Relay); err != nil {
 			return err
 		}
-		switch runtime.GOOS {
-		case "darwin":
-			return installRelayLaunchd(binPath)
-		case "linux":
-			return installRelaySystemd(binPath)
-		case "windows":
-			return installRelayWindows(binPath)
-		default:
-			return fmt.Errorf("不支持的平台: %s", runtime.GOOS)
+		svc := serviceFor(relayInstallUser)
+		if err := svc.InstallRelay(binPath, relay.FrpcConfigPath()); err != nil {
+			return fmt.Errorf("注册中继服务失败: %w", err)
 		}
+		fmt.Println("✓ 中继客户端已注册为系统服务")
+		printUserHint(relayInstallUser)
+		return nil
 	},
 }
 
-// ==================== macOS launchd ====================
-
-const relayPlistName = "com.cftunnel.frpc"
-
-const relayPlistTmpl = `<?xml version="1.0" encoding="UTF-8"?>
-<!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
-<plist version="1.0">
-<dict>
-    <key>Label</key>
-    <string>{{.Label}}</string>
-    <key>ProgramArguments</key>
-    <array>
-        <string>{{.BinPath}}</string>
-        <string>-c</string>
-        <string>{{.ConfigPath}}</string>
-    </array>
-    <key>KeepAlive</key>
-    <true/>
-    <key>RunAtLoad</key>
-    <true/>
-    <key>StandardOutPath</key>
-    <string>{{.LogPath}}</string>
-    <key>StandardErrorPath</key>
-    <string>{{.LogPath}}</string>
-</dict>
-</plist>
-`
-
-func relayPlistPath() string {
-	home, _ := os.UserHomeDir()
-	return filepath.Join(home, "Library/LaunchAgents", relayPlistName+".plist")
-}
-
-func installRelayLaunchd(binPath string) error {
-	home, _ := os.UserHomeDir()
-	data := map[string]string{
-		"Label":      relayPlistName,
-		"BinPath":    binPath,
-		"ConfigPath": relay.FrpcConfigPath(),
-		"LogPath":    filepath.Join(home, "Library/Logs/cftunnel-relay.log"),
-	}
-	f, err := os.Create(relayPlistPath())
-	if err != nil {
-		return err
-	}
-	defer f.Close()
-	if err := template.Must(template.New("").Parse(relayPlistTmpl)).Execute(f, data); err != nil {
-		return err
-	}
-	if err := exec.Command("launchctl", "load", relayPlistPath()).Run(); err != nil {
-		return fmt.Errorf("launchctl load 失败: %w", err)
-	}
-	fmt.Printf("✓ 已注册 launchd 服务: %s\n", relayPlistName)
-	return nil
-}
-
-func uninstallRelayLaunchd() error {
-	exec.Command("launchctl", "unload", relayPlistPath()).Run()
-	os.Remove(relayPlistPath())
-	fmt.Printf("✓ 已卸载 launchd 服务: %s\n", relayPlistName)
-	return nil
-}
-
-// ==================== Linux systemd ====================
-
-const relayUnitName = "cftunnel-relay"
-
-func relayUnitPath() string {
-	return "/etc/systemd/system/" + relayUnitName + ".service"
-}
-
-func installRelaySystemd(binPath string) error {
-	unit := fmt.Sprintf(`[Unit]
-Description=cftunnel relay (frpc)
-After=network.target
-
-[Service]
-ExecStart=%s -c %s
-Restart=always
-RestartSec=5
-
-[Install]
-WantedBy=multi-user.target
-`, binPath, relay.FrpcConfigPath())
-
-	if err := os.WriteFile(relayUnitPath(), []byte(unit), 0644); err != nil {
-		return fmt.Errorf("写入 systemd unit 失败: %w", err)
-	}
-	if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
-		return fmt.Errorf("systemctl daemon-reload 失败: %w", err)
-	}
-	if err := exec.Command("systemctl", "enable", "--now", relayUnitName).Run(); err != nil {
-		return fmt.Errorf("systemctl enable 失败: %w", err)
-	}
-	fmt.Printf("✓ 已注册 systemd 服务: %s\n", relayUnitName)
-	return nil
-}
-
-func uninstallRelaySystemd() error {
-	exec.Command("systemctl", "disable", "--now", relayUnitName).Run()
-	os.Remove(relayUnitPath())
-	fmt.Printf("✓ 已卸载 systemd 服务: %s\n", relayUnitName)
-	return nil
-}
-
-// ==================== Windows sc ====================
-
-const relaySvcName = "cftunnel-relay"
-
-func installRelayWindows(binPath string) error {
-	binArg := fmt.Sprintf(`%s -c %s`, binPath, relay.FrpcConfigPath())
-	if err := exec.Command("sc", "create", relaySvcName, "binPath=", binArg, "start=", "auto").Run(); err != nil {
-		return fmt.Errorf("创建 Windows 服务失败: %w", err)
-	}
-	if err := exec.Command("sc", "start", relaySvcName).Run(); err != nil {
-		return fmt.Errorf("启动服务失败: %w", err)
-	}
-	fmt.Printf("✓ 已注册 Windows 服务: %s\n", relaySvcName)
-	return nil
-}
-
-func uninstallRelayWindows() error {
-	exec.Command("sc", "stop", relaySvcName).Run()
-	exec.Command("sc", "delete", relaySvcName).Run()
-	fmt.Printf("✓ 已卸载 Windows 服务: %s\n", relaySvcName)
-	return nil
-}
-
 var relayUninstallCmd = &cobra.Command{
 	Use:   "uninstall",
 	Short: "卸载中继客户端系统服务",
 	RunE: func(cmd *cobra.Command, args []string) error {
-		switch runtime.GOOS {
-		case "darwin":
-			return uninstallRelayLaunchd()
-		case "linux":
-			return uninstallRelaySystemd()
-		case "windows":
-			return uninstallRelayWindows()
-		default:
-			return fmt.Errorf("不支持的平台: %s", runtime.GOOS)
+		if err := service.New().UninstallRelay(); err != nil {
+			return fmt.Errorf("卸载中继服务失败: %w", err)
 		}
+		fmt.Println("✓ 中继客户端系统服务已卸载")
+		return nil
 	},
 }
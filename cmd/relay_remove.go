@@ -16,18 +16,22 @@ var relayRemoveCmd = &cobra.Command{
 	Short: "删除中继穿透规则",
 	Args:  cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
-		name := args[0]
-		cfg, err := config.Load()
-		if err != nil {
+		if err := removeRelayRule(args[0]); err != nil {
 			return err
 		}
-		if !cfg.RemoveRelayRule(name) {
-			return fmt.Errorf("规则 %q 不存在", name)
-		}
-		if err := cfg.Save(); err != nil {
-			return err
-		}
-		fmt.Printf("✔ 规则已删除: %s\n", name)
+		fmt.Printf("✔ 规则已删除: %s\n", args[0])
 		return nil
 	},
 }
+
+// removeRelayRule 删除中继规则（CLI 与管理 API 共用）
+func removeRelayRule(name string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return err
+	}
+	if !cfg.RemoveRelayRule(name) {
+		return fmt.Errorf("规则 %q 不存在", name)
+	}
+	return cfg.Save()
+}
@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 
+	"github.com/qingchencloud/cftunnel/internal/config"
 	"github.com/qingchencloud/cftunnel/internal/relay"
 	"github.com/spf13/cobra"
 )
@@ -15,6 +16,14 @@ var relayUpCmd = &cobra.Command{
 	Use:   "up",
 	Short: "启动中继客户端",
 	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := config.Load()
+		if err != nil {
+			return err
+		}
+		// 按锁定版本 / 自动更新同步 frpc（已在运行时跳过）
+		if !relay.Running() {
+			frpcBinary.sync(cfg)
+		}
 		if err := relay.Start(); err != nil {
 			return err
 		}
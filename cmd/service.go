@@ -0,0 +1,154 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/service"
+	"github.com/spf13/cobra"
+)
+
+var (
+	serviceStatusJSON bool
+	serviceRelay      bool
+	serviceUser       bool
+	serviceProtocol   string
+	serviceLogLines   int
+)
+
+func init() {
+	serviceStatusCmd.Flags().BoolVar(&serviceStatusJSON, "json", false, "JSON 格式输出")
+	serviceRestartCmd.Flags().BoolVar(&serviceRelay, "relay", false, "重启中继客户端服务")
+	serviceReinstallCmd.Flags().BoolVar(&serviceUser, "user", false, "重新注册为当前用户的服务（已是用户级服务时自动沿用）")
+	serviceReinstallCmd.Flags().StringVar(&serviceProtocol, "protocol", "", "cloudflared 传输协议 auto|quic|http2（同 install --protocol）")
+	serviceLogsCmd.Flags().IntVarP(&serviceLogLines, "lines", "n", 50, "显示最后 N 行")
+	serviceCmd.AddCommand(serviceStatusCmd, serviceRestartCmd, serviceReinstallCmd, serviceLogsCmd)
+	rootCmd.AddCommand(serviceCmd)
+}
+
+var serviceCmd = &cobra.Command{
+	Use:   "service",
+	Short: "管理已注册的系统服务（状态、重启、重新注册、服务日志）",
+}
+
+// ServiceStatusOutput service status 的结构化输出，鉴权代理与中继服务仅在已注册时输出
+type ServiceStatusOutput struct {
+	Tunnel    service.Status  `json:"tunnel"`
+	AuthProxy *service.Status `json:"authproxy,omitempty"`
+	Relay     *service.Status `json:"relay,omitempty"`
+}
+
+var serviceStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "查看系统服务状态（开机自启、运行状态、PID、运行时长、重启次数、上次退出码）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc := service.New()
+		st, err := svc.Status()
+		if err != nil {
+			return err
+		}
+		out := ServiceStatusOutput{Tunnel: st}
+		if st, err := svc.AuthProxyStatus(); err == nil && st.Installed {
+			out.AuthProxy = &st
+		}
+		if st, err := svc.RelayStatus(); err == nil && st.Installed {
+			out.Relay = &st
+		}
+
+		if serviceStatusJSON {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(out)
+		}
+		fmt.Printf("隧道:     %s\n", serviceSummary(out.Tunnel))
+		if out.AuthProxy != nil {
+			fmt.Printf("鉴权代理: %s\n", serviceSummary(*out.AuthProxy))
+		}
+		if out.Relay != nil {
+			fmt.Printf("中继:     %s\n", serviceSummary(*out.Relay))
+		}
+		return nil
+	},
+}
+
+// serviceSummary 单行描述服务状态，如 "systemd，开机自启，✓ active (running)，PID 123，已运行 1h0m0s，重启 0 次"
+func serviceSummary(st service.Status) string {
+	if !st.Installed {
+		return "未注册（" + st.Backend + "）"
+	}
+	backend := st.Backend
+	if st.User {
+		backend += " --user"
+	}
+	parts := []string{backend}
+	if st.Enabled {
+		parts = append(parts, "开机自启")
+	} else {
+		parts = append(parts, "未启用开机自启")
+	}
+	mark := "✗"
+	if st.Running {
+		mark = "✓"
+	}
+	parts = append(parts, mark+" "+st.Active)
+	if st.PID > 0 {
+		parts = append(parts, fmt.Sprintf("PID %d", st.PID))
+	}
+	if st.Uptime > 0 {
+		parts = append(parts, "已运行 "+(time.Duration(st.Uptime)*time.Second).String())
+	}
+	if st.Restarts != nil {
+		parts = append(parts, fmt.Sprintf("重启 %d 次", *st.Restarts))
+	}
+	if st.ExitCode != nil {
+		parts = append(parts, fmt.Sprintf("上次退出码 %d", *st.ExitCode))
+	}
+	return strings.Join(parts, "，")
+}
+
+var serviceRestartCmd = &cobra.Command{
+	Use:   "restart",
+	Short: "重启隧道系统服务（--relay 重启中继客户端服务）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		svc := service.New()
+		restart, name := svc.Restart, "隧道"
+		if serviceRelay {
+			restart, name = svc.RestartRelay, "中继客户端"
+		}
+		if err := restart(); err != nil {
+			return fmt.Errorf("重启%s服务失败: %w", name, err)
+		}
+		fmt.Printf("✓ %s服务已重启\n", name)
+		return nil
+	},
+}
+
+var serviceReinstallCmd = &cobra.Command{
+	Use:   "reinstall",
+	Short: "卸载并重新注册隧道系统服务（修复损坏或过期的服务定义）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		user := serviceUser
+		if st, err := service.New().Status(); err == nil && st.User {
+			user = true
+		}
+		return registerService(user, serviceProtocol, true)
+	},
+}
+
+var serviceLogsCmd = &cobra.Command{
+	Use:   "logs",
+	Short: "查看隧道系统服务自身的日志（journal 或 init 系统的服务日志文件）",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		lines, err := service.New().Logs(serviceLogLines)
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			fmt.Println(line)
+		}
+		return nil
+	},
+}
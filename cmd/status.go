@@ -4,10 +4,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"os"
+	"time"
 
 	"github.com/qingchencloud/cftunnel/internal/config"
 	"github.com/qingchencloud/cftunnel/internal/daemon"
 	"github.com/qingchencloud/cftunnel/internal/relay"
+	"github.com/qingchencloud/cftunnel/internal/service"
 	"github.com/spf13/cobra"
 )
 
@@ -26,27 +28,41 @@ type StatusOutput struct {
 
 // CloudStatus Cloud 模式状态
 type CloudStatus struct {
-	TunnelName string        `json:"tunnel_name"`
-	TunnelID   string        `json:"tunnel_id"`
-	Running    bool          `json:"running"`
-	PID        int           `json:"pid,omitempty"`
-	Routes     []RouteStatus `json:"routes"`
+	TunnelName string            `json:"tunnel_name"`
+	TunnelID   string            `json:"tunnel_id"`
+	Running    bool              `json:"running"`
+	PID        int               `json:"pid,omitempty"`
+	Supervisor *SupervisorStatus `json:"supervisor,omitempty"`
+	Service    *service.Status   `json:"service,omitempty"` // 已注册为系统服务时
+	Routes     []RouteStatus     `json:"routes"`
+}
+
+// SupervisorStatus 守护模式状态（up --supervise / --detach）
+type SupervisorStatus struct {
+	PID        int       `json:"pid"`
+	StartedAt  time.Time `json:"started_at"`
+	Restarts   int       `json:"restarts"`
+	LastExit   string    `json:"last_exit,omitempty"`
+	LastExitAt time.Time `json:"last_exit_at"`
 }
 
 // RouteStatus 路由状态
 type RouteStatus struct {
-	Name     string `json:"name"`
-	Hostname string `json:"hostname"`
-	Service  string `json:"service"`
-	Auth     bool   `json:"auth"`
+	Name      string `json:"name"`
+	Hostname  string `json:"hostname"`
+	Service   string `json:"service"`
+	Auth      bool   `json:"auth"`
+	Limits    bool   `json:"limits,omitempty"`
+	ProxyPort int    `json:"proxy_port,omitempty"` // 本地代理固定端口
 }
 
 // RelayStatus Relay 模式状态
 type RelayStatus struct {
-	Server  string       `json:"server"`
-	Running bool         `json:"running"`
-	PID     int          `json:"pid,omitempty"`
-	Rules   []RuleStatus `json:"rules"`
+	Server  string          `json:"server"`
+	Running bool            `json:"running"`
+	PID     int             `json:"pid,omitempty"`
+	Service *service.Status `json:"service,omitempty"` // 已注册为系统服务时
+	Rules   []RuleStatus    `json:"rules"`
 }
 
 // RuleStatus 规则状态
@@ -82,6 +98,7 @@ var statusCmd = &cobra.Command{
 
 func buildStatus(cfg *config.Config) StatusOutput {
 	var out StatusOutput
+	svc := service.New()
 
 	if cfg.Tunnel.ID != "" {
 		cs := &CloudStatus{
@@ -92,13 +109,34 @@ func buildStatus(cfg *config.Config) StatusOutput {
 		if cs.Running {
 			cs.PID = daemon.PID()
 		}
+		if st := daemon.SupervisorState(); st != nil {
+			cs.Supervisor = &SupervisorStatus{
+				PID:        st.PID,
+				StartedAt:  st.StartedAt,
+				Restarts:   st.Restarts,
+				LastExit:   st.LastExit,
+				LastExitAt: st.LastExitAt,
+			}
+		}
+		if st, err := svc.Status(); err == nil && st.Installed {
+			cs.Service = &st
+			// 以系统服务运行时没有 PID 文件
+			if st.Running && !cs.Running {
+				cs.Running, cs.PID = true, st.PID
+			}
+		}
 		for _, r := range cfg.Routes {
-			cs.Routes = append(cs.Routes, RouteStatus{
+			rs := RouteStatus{
 				Name:     r.Name,
 				Hostname: r.Hostname,
 				Service:  r.Service,
 				Auth:     r.Auth != nil,
-			})
+				Limits:   r.Limits != nil,
+			}
+			if r.Proxied() {
+				rs.ProxyPort = r.ProxyPort
+			}
+			cs.Routes = append(cs.Routes, rs)
 		}
 		out.Cloud = cs
 	}
@@ -111,6 +149,12 @@ func buildStatus(cfg *config.Config) StatusOutput {
 		if rs.Running {
 			rs.PID = relay.PID()
 		}
+		if st, err := svc.RelayStatus(); err == nil && st.Installed {
+			rs.Service = &st
+			if st.Running && !rs.Running {
+				rs.Running, rs.PID = true, st.PID
+			}
+		}
 		for _, r := range cfg.Relay.Rules {
 			rs.Rules = append(rs.Rules, RuleStatus{
 				Name:       r.Name,
@@ -141,12 +185,27 @@ func printStatus(out StatusOutput) {
 		} else {
 			fmt.Println("  状态: ✗ 已停止")
 		}
+		if sv := cs.Supervisor; sv != nil {
+			fmt.Printf("  守护: PID %d，运行 %s，重启 %d 次\n", sv.PID, time.Since(sv.StartedAt).Round(time.Second), sv.Restarts)
+			if sv.LastExit != "" {
+				fmt.Printf("  最近退出: %s (%s)\n", sv.LastExit, sv.LastExitAt.Format("2006-01-02 15:04:05"))
+			}
+		}
+		if cs.Service != nil {
+			fmt.Printf("  服务: %s\n", serviceSummary(*cs.Service))
+		}
 		fmt.Printf("  路由: %d 条\n", len(cs.Routes))
 		for _, r := range cs.Routes {
 			auth := ""
 			if r.Auth {
 				auth = " [鉴权]"
 			}
+			if r.Limits {
+				auth += " [限流]"
+			}
+			if r.ProxyPort > 0 {
+				auth += fmt.Sprintf(" (代理 :%d)", r.ProxyPort)
+			}
 			fmt.Printf("    %s → %s%s\n", r.Hostname, r.Service, auth)
 		}
 	}
@@ -164,6 +223,9 @@ func printStatus(out StatusOutput) {
 		} else {
 			fmt.Println("  状态:   ✗ 已停止")
 		}
+		if rs.Service != nil {
+			fmt.Printf("  服务:   %s\n", serviceSummary(*rs.Service))
+		}
 		fmt.Printf("  规则:   %d 条\n", len(rs.Rules))
 		for _, r := range rs.Rules {
 			remote := "-"
@@ -1,22 +1,37 @@
 package cmd
 
 import (
+	"cmp"
 	"context"
 	"encoding/hex"
 	"fmt"
-	"strconv"
-	"strings"
+	"io"
+	"log"
+	"os"
 	"time"
 
 	"github.com/qingchencloud/cftunnel/internal/authproxy"
 	"github.com/qingchencloud/cftunnel/internal/cfapi"
 	"github.com/qingchencloud/cftunnel/internal/config"
 	"github.com/qingchencloud/cftunnel/internal/daemon"
+	"github.com/qingchencloud/cftunnel/internal/logfile"
 	"github.com/qingchencloud/cftunnel/internal/selfupdate"
+	"github.com/qingchencloud/cftunnel/internal/service"
 	"github.com/spf13/cobra"
 )
 
+var (
+	upSupervise bool
+	upDetach    bool
+	upMetrics   string
+	upProtocol  string
+)
+
 func init() {
+	upCmd.Flags().BoolVar(&upSupervise, "supervise", false, "前台守护运行：cloudflared 崩溃后自动重启，鉴权代理随守护进程常驻")
+	upCmd.Flags().BoolVarP(&upDetach, "detach", "d", false, "以守护模式在后台运行（输出写入 cftunnel logs 的日志文件）")
+	upCmd.Flags().StringVar(&upMetrics, "metrics", "", "守护模式下提供 Prometheus 指标的监听地址，如 127.0.0.1:9464")
+	upCmd.Flags().StringVar(&upProtocol, "protocol", "", "cloudflared 传输协议 auto|quic|http2（覆盖 config.yml 中的 cloudflared.protocol）")
 	rootCmd.AddCommand(upCmd)
 }
 
@@ -31,47 +46,47 @@ var upCmd = &cobra.Command{
 		if cfg.Tunnel.Token == "" {
 			return fmt.Errorf("请先运行 cftunnel init && cftunnel create <名称>")
 		}
+		if service.New().Legacy() {
+			fmt.Println("提示: 已注册的系统服务仍在命令行参数中携带隧道令牌（其他用户可通过 ps 看到），运行 cftunnel install 迁移")
+		}
 
-		// 为有鉴权配置的路由启动代理
-		var proxies []*authproxy.Proxy
-		for i, r := range cfg.Routes {
-			if r.Auth == nil {
-				continue
-			}
-			sigKey, err := hex.DecodeString(r.Auth.SigningKey)
+		if upMetrics != "" && !upSupervise && !upDetach {
+			return fmt.Errorf("--metrics 需配合 --supervise 或 --detach 使用")
+		}
+		if err := checkProtocol(cmp.Or(upProtocol, cfg.Cloudflared.Protocol)); err != nil {
+			return err
+		}
+
+		// 后台守护：重新执行 up --supervise 后立即返回
+		if upDetach {
+			pid, err := tunnelUpDetached()
 			if err != nil {
-				return fmt.Errorf("路由 %s 的 signing_key 无效: %w", r.Name, err)
-			}
-			// 从 service URL 提取端口
-			port := extractPort(r.Service)
-			if port == "" {
-				return fmt.Errorf("路由 %s 的 service 格式无效: %s", r.Name, r.Service)
+				return err
 			}
-			proxy, err := authproxy.New(authproxy.Config{
-				Username:   r.Auth.Username,
-				Password:   r.Auth.Password,
-				TargetPort: port,
-				SigningKey:  sigKey,
-				CookieTTL:  time.Duration(r.Auth.CookieTTLOrDefault()) * time.Second,
-			})
+			fmt.Printf("已在后台启动守护进程 (PID: %d)，日志: %s\n", pid, logFilePath())
+			fmt.Println("查看状态: cftunnel status，停止: cftunnel down")
+			return nil
+		}
+
+		// 为启用鉴权或限流的路由分配固定端口并启动代理（已注册 sidecar 服务时由服务负责）
+		if _, err := ensureProxyPorts(cfg); err != nil {
+			return err
+		}
+		sidecar := hasProxiedRoutes(cfg) && service.New().AuthProxyRunning()
+		switch {
+		case sidecar:
+			fmt.Println("鉴权代理由系统服务 cftunnel-authproxy 提供")
+		case hasProxiedRoutes(cfg) && !upSupervise:
+			// 普通 up 在 cloudflared 启动后即退出，随进程运行的代理也随之关闭，ingress 指向的代理端口将全部 502
+			return fmt.Errorf("存在启用鉴权或限流的路由，代理需要常驻进程：请使用 cftunnel up --supervise / --detach，或 cftunnel install 注册鉴权代理服务")
+		default:
+			proxies, err := startAuthProxies(cfg)
 			if err != nil {
-				return fmt.Errorf("路由 %s 启动鉴权代理失败: %w", r.Name, err)
+				return err
 			}
-			if err := proxy.Start(); err != nil {
-				return fmt.Errorf("路由 %s 启动鉴权代理失败: %w", r.Name, err)
-			}
-			proxies = append(proxies, proxy)
-			proxyPort := strconv.Itoa(proxy.ListenPort())
-			fmt.Printf("鉴权代理已启动: %s → 127.0.0.1:%s → 127.0.0.1:%s\n", r.Hostname, proxyPort, port)
-			// 临时修改 service 指向代理端口（仅内存，不持久化）
-			cfg.Routes[i].Service = "http://localhost:" + proxyPort
+			// 确保退出时关闭所有代理
+			defer stopAuthProxies(proxies)
 		}
-		// 确保退出时关闭所有代理
-		defer func() {
-			for _, p := range proxies {
-				p.Stop()
-			}
-		}()
 
 		// 启动前同步 ingress 配置到远端，确保本地与远端一致
 		if len(cfg.Routes) > 0 {
@@ -91,15 +106,225 @@ var upCmd = &cobra.Command{
 				}
 			}
 		}
-		return daemon.Start(cfg.Tunnel.Token)
+
+		// 按锁定版本 / 自动更新同步 cloudflared（已在运行时跳过）
+		if !daemon.Running() && daemon.SupervisorState() == nil {
+			cloudflaredBinary.sync(cfg)
+		}
+
+		if upSupervise {
+			if upMetrics != "" {
+				stopMetrics, err := serveMetrics(upMetrics)
+				if err != nil {
+					return err
+				}
+				defer stopMetrics()
+			}
+			// cloudflared 输出与守护日志写入轮转日志文件，前台运行时同时输出到终端
+			logw, err := logfile.Open(logFilePath(), logOptions(cfg))
+			if err != nil {
+				return fmt.Errorf("打开日志文件失败: %w", err)
+			}
+			defer logw.Close()
+			var out io.Writer = logw
+			if isTerminal(os.Stdout) {
+				out = io.MultiWriter(logw, os.Stdout)
+			}
+			log.SetOutput(out)
+
+			// 鉴权代理与 cloudflared 同属本进程，Ctrl+C / down 时一起退出
+			return service.Serve("cftunnel", func(stop <-chan struct{}) error {
+				return daemon.Supervise(tunnelArgs(cfg, upProtocol), cfg.Tunnel.Token, out, stop)
+			})
+		}
+		return daemon.Start(tunnelArgs(cfg, upProtocol), cfg.Tunnel.Token)
 	},
 }
 
-// extractPort 从 "http://localhost:3000" 格式中提取端口号
-func extractPort(service string) string {
-	idx := strings.LastIndex(service, ":")
-	if idx < 0 {
-		return ""
+// tunnelUpDetached 在后台以守护模式启动隧道，返回守护进程 PID（CLI 与管理 API 共用）
+func tunnelUpDetached() (int, error) {
+	if st := daemon.SupervisorState(); st != nil {
+		return 0, fmt.Errorf("守护进程已在运行 (PID: %d)", st.PID)
+	}
+	if daemon.Running() {
+		return 0, fmt.Errorf("cloudflared 已在运行")
+	}
+	args := []string{"up", "--supervise"}
+	if upMetrics != "" {
+		args = append(args, "--metrics", upMetrics)
+	}
+	if upProtocol != "" {
+		args = append(args, "--protocol", upProtocol)
+	}
+	return daemon.Detach(args, logFilePath())
+}
+
+// tunnelArgs 按 config.yml 生成 cloudflared 运行参数，protocol 非空时覆盖配置中的协议
+func tunnelArgs(cfg *config.Config, protocol string) []string {
+	opts := cfg.Cloudflared
+	if protocol != "" {
+		opts.Protocol = protocol
+	}
+	for _, w := range daemon.TunnelWarnings(opts) {
+		fmt.Println("警告: " + w)
+	}
+	return daemon.TunnelArgs(opts)
+}
+
+// checkProtocol 校验 --protocol 取值
+func checkProtocol(protocol string) error {
+	switch protocol {
+	case "", "auto", "quic", "http2":
+		return nil
+	}
+	return fmt.Errorf("不支持的协议: %s（可选 auto / quic / http2）", protocol)
+}
+
+// hasProxiedRoutes 是否存在需要本地代理的路由（启用鉴权或限流）
+func hasProxiedRoutes(cfg *config.Config) bool {
+	for _, r := range cfg.Routes {
+		if r.Proxied() {
+			return true
+		}
+	}
+	return false
+}
+
+// routeProxyConfig 将路由的鉴权 / 限流配置转换为代理配置
+func routeProxyConfig(r config.RouteConfig) (authproxy.Config, error) {
+	if r.Auth == nil {
+		// 仅限流：不启用登录
+		return authproxy.Config{
+			Name:       r.Name,
+			Target:     r.Service,
+			ListenPort: r.ProxyPort,
+			Upstream:   routeUpstream(r.Upstream),
+			Anonymous:  true,
+			Limits:     routeLimits(r.Limits),
+			AccessLog:  authLogPath(r.Name),
+		}, nil
+	}
+	sigKey, err := hex.DecodeString(r.Auth.SigningKey)
+	if err != nil {
+		return authproxy.Config{}, fmt.Errorf("路由 %s 的 signing_key 无效: %w", r.Name, err)
+	}
+	allowNets, err := authproxy.ParseNets(r.Auth.AllowIPs)
+	if err != nil {
+		return authproxy.Config{}, fmt.Errorf("路由 %s 的 allow_ips 无效: %w", r.Name, err)
+	}
+	denyNets, err := authproxy.ParseNets(r.Auth.DenyIPs)
+	if err != nil {
+		return authproxy.Config{}, fmt.Errorf("路由 %s 的 deny_ips 无效: %w", r.Name, err)
+	}
+	var users []authproxy.User
+	for _, u := range r.Auth.Users {
+		users = append(users, authproxy.User{Username: u.Username, Password: u.Password, Groups: u.Groups})
+	}
+	var identity authproxy.IdentityHeaders
+	if id := r.Auth.Identity; id != nil {
+		identity = authproxy.IdentityHeaders{
+			User:   id.UserHeader,
+			Method: id.MethodHeader,
+			Groups: id.GroupsHeader,
+			JWT:    id.JWTHeader,
+			JWTKey: []byte(id.JWTKey),
+			JWTTTL: time.Duration(id.JWTTTL) * time.Second,
+		}
+	}
+	cookie, err := routeCookie(r.Auth.Cookie)
+	if err != nil {
+		return authproxy.Config{}, fmt.Errorf("路由 %s 的 cookie 配置无效: %w", r.Name, err)
+	}
+	var rules []authproxy.PathRule
+	for _, pr := range r.Auth.Paths {
+		rule, err := authproxy.NewPathRule(pr.Path, pr.Access, pr.Users)
+		if err != nil {
+			return authproxy.Config{}, fmt.Errorf("路由 %s: %w", r.Name, err)
+		}
+		rules = append(rules, rule)
+	}
+	return authproxy.Config{
+		Name:           r.Name,
+		Username:       r.Auth.Username,
+		Password:       r.Auth.Password,
+		Target:         r.Service,
+		ListenPort:     r.ProxyPort,
+		Upstream:       routeUpstream(r.Upstream),
+		SigningKey:     sigKey,
+		Limits:         routeLimits(r.Limits),
+		CookieTTL:      time.Duration(r.Auth.CookieTTLOrDefault()) * time.Second,
+		Cookie:         cookie,
+		SessionFile:    authproxy.SessionStorePath(r.Name),
+		SlidingExpiry:  r.Auth.SlidingExpiry,
+		AccessLog:      authLogPath(r.Name),
+		Groups:         r.Auth.Groups,
+		Users:          users,
+		Rules:          rules,
+		Identity:       identity,
+		Page:           routeLoginPage(r.Auth.LoginPage),
+		AllowNets:      allowNets,
+		DenyNets:       denyNets,
+		AllowCountries: r.Auth.AllowCountries,
+		DenyCountries:  r.Auth.DenyCountries,
+		AllowSkipLogin: r.Auth.AllowIPsSkipLogin,
+	}, nil
+}
+
+// routeLimits 转换限流配置
+func routeLimits(l *config.Limits) authproxy.Limits {
+	if l == nil {
+		return authproxy.Limits{}
+	}
+	return authproxy.Limits{
+		RPS:                   l.RPS,
+		Burst:                 l.Burst,
+		MaxConns:              l.MaxConns,
+		MaxBodyBytes:          int64(l.MaxBodyMB) << 20,
+		DialTimeout:           time.Duration(l.ConnectTimeout) * time.Second,
+		ResponseHeaderTimeout: time.Duration(l.ResponseTimeout) * time.Second,
+	}
+}
+
+// routeCookie 转换会话 Cookie 配置
+func routeCookie(c *config.CookieConfig) (authproxy.CookieOptions, error) {
+	if c == nil {
+		return authproxy.CookieOptions{}, nil
+	}
+	sameSite, err := authproxy.ParseSameSite(c.SameSite)
+	if err != nil {
+		return authproxy.CookieOptions{}, err
+	}
+	return authproxy.CookieOptions{
+		Name:     c.Name,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		SameSite: sameSite,
+	}, nil
+}
+
+// routeLoginPage 转换登录页定制配置
+func routeLoginPage(lp *config.LoginPage) authproxy.LoginPage {
+	if lp == nil {
+		return authproxy.LoginPage{}
+	}
+	return authproxy.LoginPage{
+		TemplateDir: lp.TemplateDir,
+		Title:       lp.Title,
+		Logo:        lp.Logo,
+		Color:       lp.Color,
+		Locale:      lp.Locale,
+	}
+}
+
+// routeUpstream 转换路由的上游连接选项
+func routeUpstream(up *config.Upstream) authproxy.Upstream {
+	if up == nil {
+		return authproxy.Upstream{}
+	}
+	return authproxy.Upstream{
+		InsecureSkipVerify: up.InsecureSkipVerify,
+		ServerName:         up.ServerName,
+		CAFile:             up.CAFile,
+		HostHeader:         up.HostHeader,
 	}
-	return service[idx+1:]
 }
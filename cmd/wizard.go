@@ -149,7 +149,7 @@ func runWizard(cmd *cobra.Command, args []string) error {
 	// 启动 tunnel（如果未运行）
 	if !daemon.Running() {
 		fmt.Println("📋 第3步: 启动 Tunnel")
-		go daemon.Start(cfg.Tunnel.Token)
+		go daemon.Start(tunnelArgs(cfg, ""), cfg.Tunnel.Token)
 		fmt.Println("✓ Tunnel 已启动")
 		fmt.Println()
 	}
@@ -240,6 +240,11 @@ func runWizard(cmd *cobra.Command, args []string) error {
 			Password:   pass,
 			SigningKey: hex.EncodeToString(authproxy.RandomKey()),
 		}
+		port, err := authproxy.AllocatePort(service, usedProxyPorts(cfg))
+		if err != nil {
+			return err
+		}
+		route.ProxyPort = port
 		fmt.Printf("✓ 已启用密码保护: %s\n", wizardAuth)
 	}
 
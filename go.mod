@@ -5,6 +5,7 @@ go 1.25.7
 require (
 	github.com/charmbracelet/huh v0.8.0
 	github.com/cloudflare/cloudflare-go/v6 v6.7.0
+	github.com/fsnotify/fsnotify v1.9.0
 	github.com/spf13/cobra v1.10.2
 	golang.org/x/crypto v0.48.0
 	golang.org/x/sys v0.41.0
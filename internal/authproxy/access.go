@@ -0,0 +1,116 @@
+package authproxy
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ParseNets 解析 IP / CIDR 列表，单个 IP 视为 /32（IPv6 为 /128）
+func ParseNets(list []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if s == "" {
+			continue
+		}
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP: %s", s)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, n, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR: %s", s)
+		}
+		nets = append(nets, n)
+	}
+	return nets, nil
+}
+
+// clientIP 返回访问者真实 IP（优先 cloudflared 转发的 CF-Connecting-IP）
+func clientIP(r *http.Request) string {
+	if ip, ok := edgeIP(r); ok {
+		return ip
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// edgeIP 返回 Cloudflare 注入的 CF-Connecting-IP
+// 只信任来自本机回环地址（cloudflared）的连接携带的该头，且必须是合法 IP，其他来源可任意伪造
+func edgeIP(r *http.Request) (string, bool) {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if peer := net.ParseIP(host); err != nil || peer == nil || !peer.IsLoopback() {
+		return "", false
+	}
+	ip := net.ParseIP(strings.TrimSpace(r.Header.Get("CF-Connecting-IP")))
+	if ip == nil {
+		return "", false
+	}
+	return ip.String(), true
+}
+
+// clientCountry 返回 Cloudflare 识别的国家代码（大写，未知时为空）
+func clientCountry(r *http.Request) string {
+	return strings.ToUpper(strings.TrimSpace(r.Header.Get("CF-IPCountry")))
+}
+
+// checkAccess 执行 IP / 国家访问控制
+// 返回是否放行、是否免登录，以及拒绝原因
+func (p *Proxy) checkAccess(ipStr, country string) (allowed, skipLogin bool, reason string) {
+	ip := net.ParseIP(ipStr)
+
+	if containsIP(p.cfg.DenyNets, ip) {
+		return false, false, "IP 在黑名单中"
+	}
+	if country != "" && containsCountry(p.cfg.DenyCountries, country) {
+		return false, false, "国家/地区在黑名单中"
+	}
+
+	// 未配置白名单时全部放行
+	if len(p.cfg.AllowNets) == 0 && len(p.cfg.AllowCountries) == 0 {
+		return true, false, ""
+	}
+	// 白名单之间为"或"关系：命中任一即放行
+	if containsIP(p.cfg.AllowNets, ip) {
+		return true, p.cfg.AllowSkipLogin, ""
+	}
+	if country != "" && containsCountry(p.cfg.AllowCountries, country) {
+		return true, false, ""
+	}
+	return false, false, "不在白名单中"
+}
+
+func containsIP(nets []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, n := range nets {
+		if n.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func containsCountry(list []string, country string) bool {
+	for _, c := range list {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,69 @@
+package authproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	tests := []struct {
+		remote, header string
+		want           string
+		viaEdge        bool
+	}{
+		{"127.0.0.1:5000", "203.0.113.7", "203.0.113.7", true},
+		{"[::1]:5000", "2001:db8::1", "2001:db8::1", true},
+		// 非回环对端携带的头不可信
+		{"192.0.2.10:5000", "203.0.113.7", "192.0.2.10", false},
+		// 非法 IP 忽略
+		{"127.0.0.1:5000", "203.0.113.7, 10.0.0.1", "127.0.0.1", false},
+		{"127.0.0.1:5000", "", "127.0.0.1", false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.RemoteAddr = tt.remote
+		if tt.header != "" {
+			r.Header.Set("CF-Connecting-IP", tt.header)
+		}
+		if got := clientIP(r); got != tt.want {
+			t.Errorf("clientIP(%s, %q) = %q, want %q", tt.remote, tt.header, got, tt.want)
+		}
+		if _, ok := edgeIP(r); ok != tt.viaEdge {
+			t.Errorf("edgeIP(%s, %q) ok = %v, want %v", tt.remote, tt.header, ok, tt.viaEdge)
+		}
+	}
+}
+
+func TestServeSkipLogin(t *testing.T) {
+	nets, _ := ParseNets([]string{"203.0.113.0/24", "127.0.0.1"})
+	p := newRuleProxy(t)
+	p.cfg.AllowNets = nets
+	p.cfg.AllowSkipLogin = true
+
+	tests := []struct {
+		name, header string
+		status       int
+		upstream     bool
+	}{
+		{"edge allowlisted", "203.0.113.7", http.StatusOK, true},
+		// 本机进程直连：对端地址在白名单中也必须登录（返回登录页）
+		{"local without header", "", http.StatusOK, false},
+		{"edge not allowlisted", "198.51.100.1", http.StatusForbidden, false},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/other", nil)
+		r.RemoteAddr = "127.0.0.1:5000"
+		if tt.header != "" {
+			r.Header.Set("CF-Connecting-IP", tt.header)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if got := w.Body.String() == "upstream:/other"; got != tt.upstream {
+			t.Errorf("%s: reached upstream = %v, want %v", tt.name, got, tt.upstream)
+		}
+	}
+}
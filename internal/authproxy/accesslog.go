@@ -0,0 +1,123 @@
+package authproxy
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+)
+
+// 审计事件类型
+const (
+	EventRequest     = "request"
+	EventLogin       = "login"
+	EventLoginFailed = "login_failed"
+	EventLogout      = "logout"
+	EventDenied      = "denied"
+	EventLimited     = "limited"
+)
+
+// AccessEntry 访问日志条目（JSON Lines 格式）
+type AccessEntry struct {
+	Time      time.Time `json:"time"`
+	Event     string    `json:"event"`
+	IP        string    `json:"ip"`
+	Country   string    `json:"country,omitempty"`
+	User      string    `json:"user,omitempty"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	Status    int       `json:"status"`
+	Bytes     int64     `json:"bytes"`
+	LatencyMS int64     `json:"latency_ms"`
+}
+
+// Failed 判断条目是否为失败访问（登录失败、拒绝或错误状态码）
+func (e AccessEntry) Failed() bool {
+	return e.Event == EventLoginFailed || e.Event == EventDenied || e.Event == EventLimited || e.Status >= 400
+}
+
+// accessLogger 访问日志写入器
+type accessLogger struct {
+	w *logfile.Writer
+}
+
+func openAccessLog(path string) (*accessLogger, error) {
+	w, err := logfile.Open(path, logfile.Options{})
+	if err != nil {
+		return nil, fmt.Errorf("打开访问日志失败: %w", err)
+	}
+	return &accessLogger{w: w}, nil
+}
+
+// log 写入一条日志，logger 为 nil 时忽略（未配置访问日志）
+func (l *accessLogger) log(e AccessEntry) {
+	if l == nil {
+		return
+	}
+	data, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+	// 单次 Write 保证并发写入时行完整
+	l.w.Write(append(data, '\n'))
+}
+
+func (l *accessLogger) close() error {
+	if l == nil {
+		return nil
+	}
+	return l.w.Close()
+}
+
+// accessRecorder 包装 ResponseWriter，记录状态码、字节数和审计信息
+type accessRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+	event  string
+	user   string
+}
+
+func (r *accessRecorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+	r.ResponseWriter.WriteHeader(code)
+}
+
+func (r *accessRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush 支持流式响应（SSE 等）
+func (r *accessRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Hijack 支持 WebSocket 升级
+func (r *accessRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := r.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("ResponseWriter 不支持 Hijack")
+	}
+	if r.status == 0 {
+		r.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap 供 http.ResponseController 访问底层 ResponseWriter
+func (r *accessRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
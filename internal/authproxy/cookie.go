@@ -0,0 +1,124 @@
+package authproxy
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// cookieVersion 会话 Cookie 格式版本，格式变更时递增，旧版本 Cookie 一律视为未登录
+const cookieVersion = "v2"
+
+const (
+	defaultCookieName = "cftunnel_auth"
+	csrfCookieName    = "cftunnel_csrf"
+	csrfField         = "csrf"
+)
+
+// CookieOptions 会话 Cookie 选项
+type CookieOptions struct {
+	Name     string        // 默认 cftunnel_auth
+	Domain   string        // 为空时仅当前域名有效
+	Path     string        // 默认 /
+	SameSite http.SameSite // 默认 Lax
+}
+
+// ParseSameSite 解析 SameSite 配置（lax / strict / none）
+func ParseSameSite(s string) (http.SameSite, error) {
+	switch strings.ToLower(s) {
+	case "", "lax":
+		return http.SameSiteLaxMode, nil
+	case "strict":
+		return http.SameSiteStrictMode, nil
+	case "none":
+		return http.SameSiteNoneMode, nil
+	default:
+		return 0, fmt.Errorf("无效的 same_site: %s（应为 lax/strict/none）", s)
+	}
+}
+
+func (c *CookieOptions) setDefaults() {
+	if c.Name == "" {
+		c.Name = defaultCookieName
+	}
+	if c.Path == "" {
+		c.Path = "/"
+	}
+	if c.SameSite == 0 {
+		c.SameSite = http.SameSiteLaxMode
+	}
+}
+
+// hostOnly 未指定 Domain 且 Path 为 / 时可使用 __Host- 前缀
+func (c CookieOptions) hostOnly() bool {
+	return c.Domain == "" && c.Path == "/"
+}
+
+// name 返回实际 Cookie 名，满足条件时加 __Host- 前缀（浏览器强制 Secure、禁止子域覆盖）
+func (c CookieOptions) name() string {
+	if c.hostOnly() {
+		return "__Host-" + c.Name
+	}
+	return c.Name
+}
+
+// sessionCookie 构造会话 Cookie，maxAge < 0 表示删除
+func (c CookieOptions) sessionCookie(value string, maxAge int) *http.Cookie {
+	return &http.Cookie{
+		Name:     c.name(),
+		Value:    value,
+		Domain:   c.Domain,
+		Path:     c.Path,
+		MaxAge:   maxAge,
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: c.SameSite,
+	}
+}
+
+// encodeSession 编码会话 Cookie：版本.会话ID.签名
+func encodeSession(key []byte, id string) string {
+	payload := cookieVersion + "." + id
+	return payload + "." + signPayload(key, payload)
+}
+
+// decodeSession 校验签名并返回会话 ID，版本不符或签名错误时返回空
+func decodeSession(key []byte, value string) string {
+	parts := strings.Split(value, ".")
+	if len(parts) != 3 || parts[0] != cookieVersion {
+		return ""
+	}
+	payload := parts[0] + "." + parts[1]
+	if !hmac.Equal([]byte(signPayload(key, payload)), []byte(parts[2])) {
+		return ""
+	}
+	return parts[1]
+}
+
+// issueCSRF 生成 CSRF Token 并写入 Cookie（double-submit：表单与 Cookie 需一致）
+func issueCSRF(w http.ResponseWriter) string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	token := hex.EncodeToString(b)
+	http.SetCookie(w, &http.Cookie{
+		Name:     "__Host-" + csrfCookieName,
+		Value:    token,
+		Path:     "/",
+		HttpOnly: true,
+		Secure:   true,
+		SameSite: http.SameSiteStrictMode,
+	})
+	return token
+}
+
+// checkCSRF 校验表单中的 CSRF Token 与 Cookie 一致
+func checkCSRF(r *http.Request) bool {
+	cookie, err := r.Cookie("__Host-" + csrfCookieName)
+	if err != nil || cookie.Value == "" {
+		return false
+	}
+	return hmac.Equal([]byte(cookie.Value), []byte(r.FormValue(csrfField)))
+}
@@ -0,0 +1,95 @@
+package authproxy
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDecodeSession(t *testing.T) {
+	key := []byte("test-key")
+	const id = "0123456789abcdef0123456789abcdef"
+	valid := encodeSession(key, id)
+	sig := valid[strings.LastIndex(valid, ".")+1:]
+	// 改动签名的最后一个十六进制字符
+	flipped := sig[:len(sig)-1] + "a"
+	if strings.HasSuffix(sig, "a") {
+		flipped = sig[:len(sig)-1] + "b"
+	}
+	// 旧版 Cookie：用户名:过期时间(hex).签名
+	legacyPayload := fmt.Sprintf("bob:%x", time.Now().Add(time.Hour).Unix())
+	legacy := legacyPayload + "." + signPayload(key, legacyPayload)
+
+	tests := []struct {
+		name, value, want string
+	}{
+		{"valid", valid, id},
+		{"format", "v2." + id + "." + signPayload(key, "v2."+id), id},
+		{"tampered hmac", "v2." + id + "." + flipped, ""},
+		{"truncated hmac", "v2." + id + "." + sig[:10], ""},
+		{"other key", encodeSession([]byte("other-key"), id), ""},
+		{"tampered id", "v2.fedcba9876543210fedcba9876543210." + sig, ""},
+		{"legacy version", "v1." + id + "." + signPayload(key, "v1."+id), ""},
+		{"legacy format", legacy, ""},
+		{"extra part", valid + ".x", ""},
+		{"empty", "", ""},
+	}
+	for _, tt := range tests {
+		if got := decodeSession(key, tt.value); got != tt.want {
+			t.Errorf("%s: decodeSession(%q) = %q, want %q", tt.name, tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAuth(t *testing.T) {
+	p := newRuleProxy(t)
+	active, _ := p.sessions.Create("bob", "", time.Hour)
+	expired, _ := p.sessions.Create("bob", "", -time.Second)
+	revoked, _ := p.sessions.Create("carol", "", time.Hour)
+	if _, err := p.sessions.Revoke("carol"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name, value string
+		want        string // 期望的用户名，空表示未登录
+	}{
+		{"active", encodeSession(p.cfg.SigningKey, active.ID), "bob"},
+		{"expired", encodeSession(p.cfg.SigningKey, expired.ID), ""},
+		{"revoked", encodeSession(p.cfg.SigningKey, revoked.ID), ""},
+		{"unknown id", encodeSession(p.cfg.SigningKey, "00000000000000000000000000000000"), ""},
+		{"forged", encodeSession([]byte("guess"), active.ID), ""},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		r.AddCookie(p.cfg.Cookie.sessionCookie(tt.value, 0))
+		got := ""
+		if sess := p.checkAuth(r); sess != nil {
+			got = sess.Username
+		}
+		if got != tt.want {
+			t.Errorf("%s: checkAuth user = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCookieName(t *testing.T) {
+	tests := []struct {
+		opts CookieOptions
+		want string
+	}{
+		{CookieOptions{}, "__Host-cftunnel_auth"},
+		{CookieOptions{Name: "sid"}, "__Host-sid"},
+		{CookieOptions{Domain: ".example.com"}, "cftunnel_auth"},
+		{CookieOptions{Path: "/app"}, "cftunnel_auth"},
+	}
+	for _, tt := range tests {
+		tt.opts.setDefaults()
+		if got := tt.opts.name(); got != tt.want {
+			t.Errorf("%+v: name() = %q, want %q", tt.opts, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,119 @@
+package authproxy
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// 鉴权方式，注入到上游的 method 头
+const (
+	MethodPassword = "password"
+	MethodIP       = "ip"
+)
+
+// identityPrefix 所有以此开头的请求头都会被剥离，防止客户端伪造身份
+const identityPrefix = "X-Cftunnel-"
+
+// IdentityHeaders 注入到上游的身份头配置
+type IdentityHeaders struct {
+	User   string // 默认 X-Cftunnel-User
+	Method string // 默认 X-Cftunnel-Auth-Method
+	Groups string // 默认 X-Cftunnel-Groups（逗号分隔）
+	JWT    string // 默认 X-Cftunnel-Jwt，仅 JWTKey 非空时注入
+
+	JWTKey []byte        // HS256 签名密钥
+	JWTTTL time.Duration // JWT 有效期，默认 60 秒
+}
+
+func (h *IdentityHeaders) setDefaults() {
+	if h.User == "" {
+		h.User = "X-Cftunnel-User"
+	}
+	if h.Method == "" {
+		h.Method = "X-Cftunnel-Auth-Method"
+	}
+	if h.Groups == "" {
+		h.Groups = "X-Cftunnel-Groups"
+	}
+	if h.JWT == "" {
+		h.JWT = "X-Cftunnel-Jwt"
+	}
+	if h.JWTTTL <= 0 {
+		h.JWTTTL = 60 * time.Second
+	}
+}
+
+// identity 已认证的访问者
+type identity struct {
+	User   string
+	Method string
+	Groups []string
+}
+
+// stripIdentity 剥离客户端传入的身份头（含自定义头名）
+func (p *Proxy) stripIdentity(r *http.Request) {
+	for name := range r.Header {
+		if strings.HasPrefix(http.CanonicalHeaderKey(name), identityPrefix) {
+			r.Header.Del(name)
+		}
+	}
+	h := p.cfg.Identity
+	for _, name := range []string{h.User, h.Method, h.Groups, h.JWT} {
+		r.Header.Del(name)
+	}
+}
+
+// injectIdentity 注入可信身份头
+func (p *Proxy) injectIdentity(r *http.Request, id *identity) {
+	h := p.cfg.Identity
+	if id.User != "" {
+		r.Header.Set(h.User, id.User)
+	}
+	r.Header.Set(h.Method, id.Method)
+	if len(id.Groups) > 0 {
+		r.Header.Set(h.Groups, strings.Join(id.Groups, ","))
+	}
+	if len(h.JWTKey) > 0 {
+		r.Header.Set(h.JWT, p.signJWT(id))
+	}
+}
+
+// groupsOf 返回用户所属分组
+func (p *Proxy) groupsOf(username string) []string {
+	if username == p.cfg.Username {
+		return p.cfg.Groups
+	}
+	for _, u := range p.cfg.Users {
+		if u.Username == username {
+			return u.Groups
+		}
+	}
+	return nil
+}
+
+// signJWT 签发短期 HS256 JWT
+func (p *Proxy) signJWT(id *identity) string {
+	now := time.Now()
+	header := map[string]string{"alg": "HS256", "typ": "JWT"}
+	claims := map[string]any{
+		"iss":    "cftunnel",
+		"aud":    p.cfg.Name,
+		"sub":    id.User,
+		"method": id.Method,
+		"groups": id.Groups,
+		"iat":    now.Unix(),
+		"exp":    now.Add(p.cfg.Identity.JWTTTL).Unix(),
+	}
+	h, _ := json.Marshal(header)
+	c, _ := json.Marshal(claims)
+	enc := base64.RawURLEncoding
+	unsigned := enc.EncodeToString(h) + "." + enc.EncodeToString(c)
+	mac := hmac.New(sha256.New, p.cfg.Identity.JWTKey)
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + enc.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,120 @@
+package authproxy
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Limits 路由限流与请求大小限制，零值表示不限制
+type Limits struct {
+	RPS          float64 // 每个客户端 IP 每秒请求数
+	Burst        int     // 突发请求数，默认取 RPS 向上取整
+	MaxConns     int     // 最大并发请求数（含 WebSocket 长连接）
+	MaxBodyBytes int64   // 请求体上限
+
+	DialTimeout           time.Duration // 连接上游超时
+	ResponseHeaderTimeout time.Duration // 等待上游响应头超时
+}
+
+// limiterIdleTTL 客户端令牌桶闲置超过该时长后回收
+const limiterIdleTTL = 10 * time.Minute
+
+// bucket 单个客户端的令牌桶
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// rateLimiter 按客户端 IP 的令牌桶限流
+type rateLimiter struct {
+	rate  float64
+	burst float64
+	now   func() time.Time // 时钟，测试时替换
+
+	mu      sync.Mutex
+	buckets map[string]*bucket
+	sweep   time.Time
+}
+
+func newRateLimiter(rps float64, burst int) *rateLimiter {
+	if rps <= 0 {
+		return nil
+	}
+	if burst <= 0 {
+		burst = int(math.Ceil(rps))
+	}
+	return &rateLimiter{rate: rps, burst: float64(burst), now: time.Now, buckets: map[string]*bucket{}, sweep: time.Now()}
+}
+
+// allow 消耗一个令牌，令牌不足时返回需要等待的时长
+func (l *rateLimiter) allow(ip string) (bool, time.Duration) {
+	if l == nil {
+		return true, 0
+	}
+	now := l.now()
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if now.Sub(l.sweep) > limiterIdleTTL {
+		for k, b := range l.buckets {
+			if now.Sub(b.last) > limiterIdleTTL {
+				delete(l.buckets, k)
+			}
+		}
+		l.sweep = now
+	}
+
+	b, ok := l.buckets[ip]
+	if !ok {
+		b = &bucket{tokens: l.burst, last: now}
+		l.buckets[ip] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// connLimiter 并发请求数限制
+type connLimiter chan struct{}
+
+func newConnLimiter(n int) connLimiter {
+	if n <= 0 {
+		return nil
+	}
+	return make(connLimiter, n)
+}
+
+func (c connLimiter) acquire() bool {
+	if c == nil {
+		return true
+	}
+	select {
+	case c <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+func (c connLimiter) release() {
+	if c != nil {
+		<-c
+	}
+}
+
+// tooManyRequests 返回 429 并提示重试时间
+func tooManyRequests(w http.ResponseWriter, retry time.Duration) {
+	secs := int(math.Ceil(retry.Seconds()))
+	if secs < 1 {
+		secs = 1
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(secs))
+	http.Error(w, "请求过于频繁，请稍后再试", http.StatusTooManyRequests)
+}
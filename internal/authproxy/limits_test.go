@@ -0,0 +1,160 @@
+package authproxy
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// fakeClock 手动推进的时钟
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(rps float64, burst int) (*rateLimiter, *fakeClock) {
+	clock := &fakeClock{t: time.Unix(1700000000, 0)}
+	l := newRateLimiter(rps, burst)
+	l.now = clock.now
+	l.sweep = clock.t
+	return l, clock
+}
+
+func TestRateLimiterBurstAndRefill(t *testing.T) {
+	type step struct {
+		advance time.Duration // 请求前推进的时长
+		want    bool
+		retry   time.Duration // 被拒绝时期望的等待时长
+	}
+	tests := []struct {
+		name  string
+		rps   float64
+		burst int
+		steps []step
+	}{
+		{"burst then refill", 2, 3, []step{
+			{0, true, 0}, {0, true, 0}, {0, true, 0},
+			{0, false, 500 * time.Millisecond},
+			{250 * time.Millisecond, false, 250 * time.Millisecond},
+			{250 * time.Millisecond, true, 0},
+			{0, false, 500 * time.Millisecond},
+		}},
+		// 闲置再久也只补满到 burst
+		{"refill capped at burst", 10, 2, []step{
+			{0, true, 0}, {0, true, 0}, {0, false, 100 * time.Millisecond},
+			{time.Hour, true, 0}, {0, true, 0}, {0, false, 100 * time.Millisecond},
+		}},
+		// burst 未设置时取 RPS 向上取整
+		{"default burst", 1.5, 0, []step{
+			{0, true, 0}, {0, true, 0}, {0, false, time.Second * 2 / 3},
+		}},
+		{"slow rate", 0.5, 1, []step{
+			{0, true, 0}, {0, false, 2 * time.Second},
+			{time.Second, false, time.Second},
+			{time.Second, true, 0},
+		}},
+	}
+	for _, tt := range tests {
+		l, clock := newTestLimiter(tt.rps, tt.burst)
+		for i, s := range tt.steps {
+			clock.advance(s.advance)
+			ok, retry := l.allow("203.0.113.7")
+			if ok != s.want {
+				t.Errorf("%s step %d: allow = %v, want %v", tt.name, i, ok, s.want)
+			}
+			if diff := retry - s.retry; diff < -time.Millisecond || diff > time.Millisecond {
+				t.Errorf("%s step %d: retry = %v, want %v", tt.name, i, retry, s.retry)
+			}
+		}
+	}
+}
+
+func TestRateLimiterPerIP(t *testing.T) {
+	l, clock := newTestLimiter(1, 2)
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("203.0.113.7"); !ok {
+			t.Fatalf("request %d from first IP rejected", i)
+		}
+	}
+	if ok, _ := l.allow("203.0.113.7"); ok {
+		t.Error("first IP allowed beyond burst")
+	}
+	// 其他 IP 有独立的令牌桶
+	for _, ip := range []string{"203.0.113.8", "2001:db8::1"} {
+		if ok, _ := l.allow(ip); !ok {
+			t.Errorf("%s rejected after another IP exhausted its bucket", ip)
+		}
+	}
+
+	// 闲置的令牌桶在下一次请求时回收
+	clock.advance(limiterIdleTTL + time.Second)
+	l.allow("198.51.100.1")
+	if len(l.buckets) != 1 {
+		t.Errorf("buckets after sweep = %d, want 1", len(l.buckets))
+	}
+}
+
+func TestRateLimiterDisabled(t *testing.T) {
+	l := newRateLimiter(0, 5)
+	for i := 0; i < 100; i++ {
+		if ok, _ := l.allow("203.0.113.7"); !ok {
+			t.Fatal("disabled limiter rejected a request")
+		}
+	}
+}
+
+func TestConnLimiter(t *testing.T) {
+	c := newConnLimiter(2)
+	steps := []struct {
+		op   string
+		want bool
+	}{
+		{"acquire", true},
+		{"acquire", true},
+		{"acquire", false},
+		{"release", true},
+		{"acquire", true},
+		{"acquire", false},
+		{"release", true},
+		{"release", true},
+		{"acquire", true},
+	}
+	for i, s := range steps {
+		if s.op == "release" {
+			c.release()
+			continue
+		}
+		if got := c.acquire(); got != s.want {
+			t.Errorf("step %d: acquire = %v, want %v", i, got, s.want)
+		}
+	}
+
+	// 未设置上限时不限制
+	unlimited := newConnLimiter(0)
+	for i := 0; i < 100; i++ {
+		if !unlimited.acquire() {
+			t.Fatal("unlimited connLimiter rejected acquire")
+		}
+	}
+	unlimited.release()
+}
+
+func TestTooManyRequests(t *testing.T) {
+	tests := []struct {
+		retry time.Duration
+		want  string
+	}{
+		{0, "1"},
+		{200 * time.Millisecond, "1"},
+		{1500 * time.Millisecond, "2"},
+		{3 * time.Second, "3"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		tooManyRequests(w, tt.retry)
+		if w.Code != http.StatusTooManyRequests || w.Header().Get("Retry-After") != tt.want {
+			t.Errorf("tooManyRequests(%v): status %d Retry-After %q, want 429 %q", tt.retry, w.Code, w.Header().Get("Retry-After"), tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package authproxy
+
+import (
+	_ "embed"
+	"fmt"
+	"html/template"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//go:embed login.html
+var loginHTML string
+
+//go:embed forbidden.html
+var forbiddenHTML string
+
+// LoginPage 登录页定制
+type LoginPage struct {
+	TemplateDir string // 自定义模板目录，需包含 login.html，forbidden.html 可选
+	Title       string // 页面标题，默认 cftunnel
+	Logo        string // Logo 图片 URL
+	Color       string // 主色调，如 #3b82f6
+	Locale      string // zh / en，为空时按 Accept-Language 自动选择
+}
+
+// pageData 模板变量
+type pageData struct {
+	Lang        string
+	Title       string
+	CustomTitle bool
+	Logo        string
+	Color       string
+	Route       string
+	Error       bool   // 是否显示错误提示
+	ErrorText   string // 错误提示文案
+	ReturnURL   string
+	CSRF        string
+	LoginPath   string
+	T           map[string]string
+}
+
+// 页面文案
+var locales = map[string]map[string]string{
+	"zh": {
+		"Verify":          "访问验证",
+		"Subtitle":        "此服务需要身份验证",
+		"Error":           "用户名或密码错误",
+		"CSRF":            "页面已过期，请重新登录",
+		"Username":        "用户名",
+		"Password":        "密码",
+		"Login":           "登 录",
+		"Forbidden":       "禁止访问",
+		"ForbiddenDetail": "你没有访问此页面的权限",
+	},
+	"en": {
+		"Verify":          "Sign in",
+		"Subtitle":        "This service requires authentication",
+		"Error":           "Invalid username or password",
+		"CSRF":            "This page has expired, please sign in again",
+		"Username":        "Username",
+		"Password":        "Password",
+		"Login":           "Sign in",
+		"Forbidden":       "Forbidden",
+		"ForbiddenDetail": "You do not have permission to access this page",
+	},
+}
+
+// pageCSP 登录页 / 403 页的内容安全策略：禁止脚本与被嵌入，表单只能提交到本站
+const pageCSP = "default-src 'none'; style-src 'unsafe-inline'; img-src * data:; form-action 'self'; frame-ancestors 'none'; base-uri 'none'"
+
+// pages 已解析的页面模板
+type pages struct {
+	cfg       LoginPage
+	login     *template.Template
+	forbidden *template.Template
+}
+
+// loadPages 加载内置模板，配置了模板目录时用目录中的同名文件覆盖
+func loadPages(cfg LoginPage) (*pages, error) {
+	p := &pages{cfg: cfg}
+	var err error
+	if p.login, err = loadTemplate(cfg.TemplateDir, "login.html", loginHTML, true); err != nil {
+		return nil, err
+	}
+	if p.forbidden, err = loadTemplate(cfg.TemplateDir, "forbidden.html", forbiddenHTML, false); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func loadTemplate(dir, name, builtin string, required bool) (*template.Template, error) {
+	text := builtin
+	if dir != "" {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		switch {
+		case err == nil:
+			text = string(data)
+		case !os.IsNotExist(err) || required:
+			return nil, fmt.Errorf("读取登录页模板失败: %w", err)
+		}
+	}
+	t, err := template.New(name).Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("解析模板 %s 失败: %w", name, err)
+	}
+	return t, nil
+}
+
+// data 构造模板变量
+func (pg *pages) data(r *http.Request, route string) pageData {
+	lang := pg.cfg.Locale
+	if _, ok := locales[lang]; !ok {
+		lang = negotiateLocale(r.Header.Get("Accept-Language"))
+	}
+	title := pg.cfg.Title
+	if title == "" {
+		title = "cftunnel"
+	}
+	return pageData{
+		Lang:        lang,
+		Title:       title,
+		CustomTitle: pg.cfg.Title != "",
+		Logo:        pg.cfg.Logo,
+		Color:       pg.cfg.Color,
+		Route:       route,
+		LoginPath:   loginPath,
+		T:           locales[lang],
+	}
+}
+
+// renderLogin 输出登录页并签发 CSRF Token，errKey 为错误文案键（为空时不显示错误）
+func (pg *pages) renderLogin(w http.ResponseWriter, r *http.Request, route, returnURL, errKey string, status int) {
+	d := pg.data(r, route)
+	if errKey != "" {
+		d.Error = true
+		d.ErrorText = d.T[errKey]
+	}
+	d.ReturnURL = returnURL
+	d.CSRF = issueCSRF(w)
+	render(w, pg.login, d, status)
+}
+
+// renderForbidden 输出 403 页
+func (pg *pages) renderForbidden(w http.ResponseWriter, r *http.Request, route string) {
+	render(w, pg.forbidden, pg.data(r, route), http.StatusForbidden)
+}
+
+func render(w http.ResponseWriter, t *template.Template, d pageData, status int) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Security-Policy", pageCSP)
+	w.Header().Set("X-Frame-Options", "DENY")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Referrer-Policy", "same-origin")
+	w.WriteHeader(status)
+	t.Execute(w, d)
+}
+
+// negotiateLocale 按 Accept-Language 选择语言，默认中文
+func negotiateLocale(header string) string {
+	for _, part := range strings.Split(header, ",") {
+		tag := strings.ToLower(strings.TrimSpace(strings.SplitN(part, ";", 2)[0]))
+		switch {
+		case strings.HasPrefix(tag, "zh"):
+			return "zh"
+		case strings.HasPrefix(tag, "en"):
+			return "en"
+		}
+	}
+	return "zh"
+}
+
+// safeReturnURL 仅允许站内相对路径，防止开放重定向
+func safeReturnURL(s string) string {
+	if s == "" || !strings.HasPrefix(s, "/") || strings.HasPrefix(s, "//") || strings.HasPrefix(s, "/\\") ||
+		strings.HasPrefix(s, "/___auth/") {
+		return "/"
+	}
+	return s
+}
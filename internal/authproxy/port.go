@@ -17,3 +17,24 @@ func FindAvailableListener(startPort int) (net.Listener, error) {
 	}
 	return nil, fmt.Errorf("在 %d-%d 范围内未找到可用端口", startPort, startPort+99)
 }
+
+// AllocatePort 为上游分配一个当前可用、且不在 used 中的固定端口（供持久化到配置）
+func AllocatePort(target string, used map[int]bool) (int, error) {
+	u, _, err := parseTarget(target)
+	if err != nil {
+		return 0, err
+	}
+	start := listenStart(u)
+	for p := start; p < start+100; p++ {
+		if used[p] {
+			continue
+		}
+		ln, err := net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(p))
+		if err != nil {
+			continue
+		}
+		ln.Close()
+		return p, nil
+	}
+	return 0, fmt.Errorf("在 %d-%d 范围内未找到可用端口", start, start+99)
+}
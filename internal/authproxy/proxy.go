@@ -5,9 +5,10 @@ import (
 	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
-	_ "embed"
+	"crypto/subtle"
 	"encoding/hex"
 	"fmt"
+	"log"
 	"net"
 	"net/http"
 	"net/http/httputil"
@@ -17,11 +18,8 @@ import (
 	"time"
 )
 
-//go:embed login.html
-var loginHTML []byte
-
-const cookieName = "__cftunnel_auth"
 const loginPath = "/___auth/login"
+const logoutPath = "/___auth/logout"
 
 // RandomKey 生成 32 字节随机签名密钥
 func RandomKey() []byte {
@@ -32,11 +30,44 @@ func RandomKey() []byte {
 
 // Config 鉴权代理配置
 type Config struct {
+	Name       string // 路由名称，用于日志
 	Username   string
 	Password   string
-	TargetPort string
-	SigningKey  []byte
+	Target     string   // 上游地址：http(s)://主机:端口、unix:/路径
+	ListenPort int      // 固定监听端口，为 0 时自动探测
+	Upstream   Upstream // 上游 TLS / Host 头选项
+	SigningKey []byte
 	CookieTTL  time.Duration
+	Cookie     CookieOptions // 会话 Cookie 名称 / 作用域 / SameSite
+
+	// 不启用登录，仅执行访问控制与限流
+	Anonymous bool
+	// 限流与请求大小限制
+	Limits Limits
+
+	// 会话存储文件，为空时仅保存在内存
+	SessionFile   string
+	SlidingExpiry bool // 每次访问顺延会话有效期
+
+	// 访问日志文件（JSON Lines，按大小轮转），为空时不记录
+	AccessLog string
+
+	Groups []string   // 主账号所属分组
+	Users  []User     // 额外账号
+	Rules  []PathRule // 路径规则，按顺序匹配；未命中时需登录
+
+	// 注入到上游的身份头
+	Identity IdentityHeaders
+
+	// 登录页定制
+	Page LoginPage
+
+	// 访问控制（可选）
+	AllowNets      []*net.IPNet
+	DenyNets       []*net.IPNet
+	AllowCountries []string
+	DenyCountries  []string
+	AllowSkipLogin bool // 命中 AllowNets 时免登录，仅对经 cloudflared 转发（带 CF-Connecting-IP）的请求生效
 }
 
 // Proxy 鉴权反向代理
@@ -45,27 +76,59 @@ type Proxy struct {
 	listener net.Listener
 	server   *http.Server
 	reverse  *httputil.ReverseProxy
+	sessions *SessionStore
+	access   *accessLogger
+	pages    *pages
+	limiter  *rateLimiter
+	conns    connLimiter
+	stats    *proxyStats
 }
 
 // New 创建鉴权代理实例，自动探测可用端口
 func New(cfg Config) (*Proxy, error) {
-	port, _ := strconv.Atoi(cfg.TargetPort)
-	ln, err := FindAvailableListener(port + 1)
+	target, sock, err := parseTarget(cfg.Target)
 	if err != nil {
 		return nil, err
 	}
-
-	target, _ := url.Parse("http://127.0.0.1:" + cfg.TargetPort)
-	rp := httputil.NewSingleHostReverseProxy(target)
+	rp, err := newReverseProxy(target, sock, cfg.Upstream, cfg.Limits)
+	if err != nil {
+		return nil, err
+	}
+	pg, err := loadPages(cfg.Page)
+	if err != nil {
+		return nil, err
+	}
+	var ln net.Listener
+	if cfg.ListenPort > 0 {
+		ln, err = net.Listen("tcp", "127.0.0.1:"+strconv.Itoa(cfg.ListenPort))
+		if err != nil {
+			return nil, fmt.Errorf("监听端口 %d 失败（可能已被其他程序或鉴权代理服务占用）: %w", cfg.ListenPort, err)
+		}
+	} else if ln, err = FindAvailableListener(listenStart(target)); err != nil {
+		return nil, err
+	}
 
 	if cfg.CookieTTL == 0 {
 		cfg.CookieTTL = 24 * time.Hour
 	}
+	cfg.Cookie.setDefaults()
+	cfg.Identity.setDefaults()
 
 	p := &Proxy{
 		cfg:      cfg,
 		listener: ln,
 		reverse:  rp,
+		sessions: OpenSessionStore(cfg.SessionFile),
+		pages:    pg,
+		limiter:  newRateLimiter(cfg.Limits.RPS, cfg.Limits.Burst),
+		conns:    newConnLimiter(cfg.Limits.MaxConns),
+		stats:    newProxyStats(),
+	}
+	if cfg.AccessLog != "" {
+		if p.access, err = openAccessLog(cfg.AccessLog); err != nil {
+			ln.Close()
+			return nil, err
+		}
 	}
 	p.server = &http.Server{Handler: p}
 	return p, nil
@@ -86,14 +149,102 @@ func (p *Proxy) Start() error {
 func (p *Proxy) Stop() error {
 	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
 	defer cancel()
-	return p.server.Shutdown(ctx)
+	err := p.server.Shutdown(ctx)
+	p.access.close()
+	return err
 }
 
-// ServeHTTP 核心路由逻辑
+// ServeHTTP 处理请求并记录访问日志和指标
 func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	// WebSocket 升级请求直接透传
-	if isWebSocket(r) {
-		p.reverse.ServeHTTP(w, r)
+	start := time.Now()
+	rec := &accessRecorder{ResponseWriter: w, event: EventRequest}
+	p.stats.inflight.Add(1)
+	p.serve(rec, r)
+	p.stats.inflight.Add(-1)
+
+	if rec.status == 0 {
+		// 未写入任何内容时 net/http 返回 200
+		rec.status = http.StatusOK
+	}
+	p.stats.observe(rec.event, rec.status, time.Since(start))
+
+	p.access.log(AccessEntry{
+		Time:      start,
+		Event:     rec.event,
+		IP:        clientIP(r),
+		Country:   clientCountry(r),
+		User:      rec.user,
+		Method:    r.Method,
+		Path:      r.URL.Path,
+		Status:    rec.status,
+		Bytes:     rec.bytes,
+		LatencyMS: time.Since(start).Milliseconds(),
+	})
+}
+
+// serve 核心路由逻辑
+func (p *Proxy) serve(w *accessRecorder, r *http.Request) {
+	// 配置了路径规则时先规范化路径：规则按规范路径匹配，含 . / .. 的路径重定向到规范路径，
+	// 保证规则匹配的路径与上游实际解析的路径一致；未配置规则时原样转发
+	matchPath := r.URL.Path
+	if len(p.cfg.Rules) > 0 {
+		clean, redirect, ok := canonicalPath(r.URL)
+		if !ok {
+			w.event = EventDenied
+			http.Error(w, "非法请求路径", http.StatusBadRequest)
+			return
+		}
+		if redirect != "" {
+			target := &url.URL{Path: redirect, RawQuery: r.URL.RawQuery}
+			http.Redirect(w, r, target.RequestURI(), http.StatusPermanentRedirect)
+			return
+		}
+		matchPath = clean
+	}
+
+	// IP / 国家访问控制，优先于一切鉴权逻辑
+	ip, country := clientIP(r), clientCountry(r)
+	allowed, skipLogin, reason := p.checkAccess(ip, country)
+	// 不带 CF-Connecting-IP 的连接来自本机其他进程，对端地址不代表访问者，不能据此免登录
+	if _, viaEdge := edgeIP(r); !viaEdge {
+		skipLogin = false
+	}
+	if !allowed {
+		log.Printf("[authproxy] %s 拒绝访问: ip=%s country=%s path=%s (%s)", p.cfg.Name, ip, country, r.URL.Path, reason)
+		w.event = EventDenied
+		p.pages.renderForbidden(w, r, p.cfg.Name)
+		return
+	}
+
+	// 限流：按客户端 IP 的令牌桶 + 并发请求数
+	if ok, retry := p.limiter.allow(ip); !ok {
+		w.event = EventLimited
+		tooManyRequests(w, retry)
+		return
+	}
+	if !p.conns.acquire() {
+		w.event = EventLimited
+		tooManyRequests(w, time.Second)
+		return
+	}
+	defer p.conns.release()
+
+	// 请求体大小：声明长度超限直接拒绝，分块上传在读取时截断（由 upstreamError 返回 413）
+	if max := p.cfg.Limits.MaxBodyBytes; max > 0 {
+		if r.ContentLength > max {
+			w.event = EventLimited
+			http.Error(w, "请求体过大", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, max)
+	}
+
+	if skipLogin {
+		p.forward(w, r, &identity{Method: MethodIP})
+		return
+	}
+	if p.cfg.Anonymous {
+		p.forward(w, r, nil)
 		return
 	}
 
@@ -103,77 +254,135 @@ func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// 退出登录
+	if r.URL.Path == logoutPath {
+		p.handleLogout(w, r)
+		return
+	}
+
+	// 路径规则：公开路径直接放行
+	rule, matched := p.matchRule(matchPath)
+	if matched && rule.Public {
+		p.forward(w, r, p.sessionIdentity(r))
+		return
+	}
+
 	// 检查 Cookie 鉴权
-	if p.checkAuth(r) {
-		p.reverse.ServeHTTP(w, r)
+	if sess := p.checkAuth(r); sess != nil {
+		w.user = sess.Username
+		if matched && !rule.Allows(sess.Username) {
+			log.Printf("[authproxy] %s 拒绝访问: user=%s path=%s (不在规则 %s 的用户列表中)", p.cfg.Name, sess.Username, r.URL.Path, rule.Pattern)
+			w.event = EventDenied
+			p.pages.renderForbidden(w, r, p.cfg.Name)
+			return
+		}
+		p.sessions.Touch(sess.ID, p.cfg.CookieTTL, p.cfg.SlidingExpiry)
+		p.forward(w, r, &identity{User: sess.Username, Method: MethodPassword, Groups: p.groupsOf(sess.Username)})
 		return
 	}
 
-	// 未认证，返回登录页
-	w.Header().Set("Content-Type", "text/html; charset=utf-8")
-	w.WriteHeader(http.StatusOK)
-	w.Write(loginHTML)
+	// 未认证：WebSocket 升级请求无法显示登录页，直接拒绝；其余返回登录页，登录后回到当前页面
+	if isWebSocket(r) {
+		http.Error(w, "未登录", http.StatusUnauthorized)
+		return
+	}
+	p.pages.renderLogin(w, r, p.cfg.Name, r.URL.RequestURI(), "", http.StatusOK)
+}
+
+// forward 剥离伪造的身份头，注入可信身份后转发到上游，id 为 nil 表示匿名访问
+func (p *Proxy) forward(w http.ResponseWriter, r *http.Request, id *identity) {
+	p.stripIdentity(r)
+	if id != nil {
+		p.injectIdentity(r, id)
+	}
+	p.reverse.ServeHTTP(w, r)
+}
+
+// sessionIdentity 返回请求携带的有效会话身份，无会话时返回 nil
+func (p *Proxy) sessionIdentity(r *http.Request) *identity {
+	sess := p.checkAuth(r)
+	if sess == nil {
+		return nil
+	}
+	return &identity{User: sess.Username, Method: MethodPassword, Groups: p.groupsOf(sess.Username)}
 }
 
 // handleLogin 处理登录表单提交
-func (p *Proxy) handleLogin(w http.ResponseWriter, r *http.Request) {
+func (p *Proxy) handleLogin(w *accessRecorder, r *http.Request) {
 	username := r.FormValue("username")
 	password := r.FormValue("password")
+	w.user = username
 
-	if username != p.cfg.Username || password != p.cfg.Password {
-		http.Redirect(w, r, "/?error=1", http.StatusSeeOther)
+	returnURL := safeReturnURL(r.FormValue("return"))
+
+	// CSRF 校验：表单中的 Token 必须与 Cookie 一致
+	if !checkCSRF(r) {
+		w.event = EventLoginFailed
+		p.pages.renderLogin(w, r, p.cfg.Name, returnURL, "CSRF", http.StatusForbidden)
 		return
 	}
 
-	// 签发 Cookie
-	expiry := time.Now().Add(p.cfg.CookieTTL).Unix()
-	payload := fmt.Sprintf("%s:%x", username, expiry)
-	sig := signPayload(p.cfg.SigningKey, payload)
-	value := payload + "." + sig
-
-	http.SetCookie(w, &http.Cookie{
-		Name:     cookieName,
-		Value:    value,
-		Path:     "/",
-		MaxAge:   int(p.cfg.CookieTTL.Seconds()),
-		HttpOnly: true,
-		Secure:   true,
-		SameSite: http.SameSiteLaxMode,
-	})
-	http.Redirect(w, r, "/", http.StatusSeeOther)
-}
+	if !p.verifyUser(username, password) {
+		w.event = EventLoginFailed
+		p.pages.renderLogin(w, r, p.cfg.Name, returnURL, "Error", http.StatusUnauthorized)
+		return
+	}
 
-// checkAuth 校验请求中的鉴权 Cookie
-func (p *Proxy) checkAuth(r *http.Request) bool {
-	cookie, err := r.Cookie(cookieName)
+	sess, err := p.sessions.Create(username, clientIP(r), p.cfg.CookieTTL)
 	if err != nil {
-		return false
+		log.Printf("[authproxy] %s 保存会话失败: %v", p.cfg.Name, err)
+		http.Error(w, "保存会话失败", http.StatusInternalServerError)
+		return
 	}
+	w.event = EventLogin
 
-	// 格式：username:expiry_hex.hmac_hex
-	dotIdx := strings.LastIndex(cookie.Value, ".")
-	if dotIdx < 0 {
-		return false
-	}
-	payload := cookie.Value[:dotIdx]
-	sig := cookie.Value[dotIdx+1:]
+	// 签发 Cookie（滑动过期时由浏览器保留，实际有效期以服务端会话为准）
+	http.SetCookie(w, p.cfg.Cookie.sessionCookie(encodeSession(p.cfg.SigningKey, sess.ID), int(p.cfg.CookieTTL.Seconds())))
+	http.Redirect(w, r, returnURL, http.StatusSeeOther)
+}
 
-	// 验证签名
-	if signPayload(p.cfg.SigningKey, payload) != sig {
+// verifyUser 校验账号密码（主账号 + 额外账号）
+func (p *Proxy) verifyUser(username, password string) bool {
+	if username == "" {
 		return false
 	}
+	ok := false
+	check := func(u, pw string) {
+		if u == username && subtle.ConstantTimeCompare([]byte(pw), []byte(password)) == 1 {
+			ok = true
+		}
+	}
+	check(p.cfg.Username, p.cfg.Password)
+	for _, u := range p.cfg.Users {
+		check(u.Username, u.Password)
+	}
+	return ok
+}
 
-	// 验证过期时间
-	colonIdx := strings.LastIndex(payload, ":")
-	if colonIdx < 0 {
-		return false
+// handleLogout 删除服务端会话并清除 Cookie
+func (p *Proxy) handleLogout(w *accessRecorder, r *http.Request) {
+	w.event = EventLogout
+	if sess := p.checkAuth(r); sess != nil {
+		w.user = sess.Username
+		p.sessions.Delete(sess.ID)
 	}
-	expiryHex := payload[colonIdx+1:]
-	expiry, err := strconv.ParseInt(expiryHex, 16, 64)
+	http.SetCookie(w, p.cfg.Cookie.sessionCookie("", -1))
+	http.Redirect(w, r, "/", http.StatusSeeOther)
+}
+
+// checkAuth 校验请求中的鉴权 Cookie，返回对应的有效会话
+func (p *Proxy) checkAuth(r *http.Request) *Session {
+	cookie, err := r.Cookie(p.cfg.Cookie.name())
 	if err != nil {
-		return false
+		return nil
+	}
+
+	// 格式：版本.会话ID.签名，旧格式或签名错误一律视为未登录
+	id := decodeSession(p.cfg.SigningKey, cookie.Value)
+	if id == "" {
+		return nil
 	}
-	return time.Now().Unix() < expiry
+	return p.sessions.Get(id)
 }
 
 // signPayload 使用 HMAC-SHA256 签名
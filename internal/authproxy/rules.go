@@ -0,0 +1,111 @@
+package authproxy
+
+import (
+	"fmt"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// PathRule 路径鉴权规则
+type PathRule struct {
+	Pattern string   // 前缀（/webhook）或 glob（/static/*.js、/api/**）
+	Public  bool     // 公开访问，跳过登录
+	Users   []string // 非空时仅允许这些用户访问
+}
+
+// User 登录账号
+type User struct {
+	Username string
+	Password string
+	Groups   []string
+}
+
+// NewPathRule 根据配置创建路径规则，access 取值 public / protected
+func NewPathRule(pattern, access string, users []string) (PathRule, error) {
+	if !strings.HasPrefix(pattern, "/") {
+		return PathRule{}, fmt.Errorf("路径规则必须以 / 开头: %s", pattern)
+	}
+	if hasGlob(pattern) {
+		if _, err := path.Match(strings.ReplaceAll(pattern, "**", "*"), "/"); err != nil {
+			return PathRule{}, fmt.Errorf("无效的路径规则 %s: %w", pattern, err)
+		}
+	}
+	switch access {
+	case "public":
+		return PathRule{Pattern: pattern, Public: true}, nil
+	case "protected", "":
+		return PathRule{Pattern: pattern, Users: users}, nil
+	default:
+		return PathRule{}, fmt.Errorf("路径规则 %s 的 access 无效: %s（应为 public/protected）", pattern, access)
+	}
+}
+
+// Match 判断请求路径是否命中规则
+func (r PathRule) Match(p string) bool {
+	if !hasGlob(r.Pattern) {
+		// 前缀匹配，按路径段边界：/webhook 匹配 /webhook、/webhook/x，不匹配 /webhooks
+		prefix := strings.TrimSuffix(r.Pattern, "/")
+		return prefix == "" || p == prefix || strings.HasPrefix(p, prefix+"/")
+	}
+	// /api/** 匹配 /api 下任意层级
+	if strings.HasSuffix(r.Pattern, "/**") {
+		base := strings.TrimSuffix(r.Pattern, "/**")
+		if !hasGlob(base) {
+			return p == base || strings.HasPrefix(p, base+"/")
+		}
+	}
+	ok, _ := path.Match(r.Pattern, p)
+	return ok
+}
+
+// Allows 判断用户是否被规则允许
+func (r PathRule) Allows(username string) bool {
+	if len(r.Users) == 0 {
+		return true
+	}
+	for _, u := range r.Users {
+		if u == username {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRule 返回第一条命中的规则
+func (p *Proxy) matchRule(reqPath string) (PathRule, bool) {
+	for _, r := range p.cfg.Rules {
+		if r.Match(reqPath) {
+			return r, true
+		}
+	}
+	return PathRule{}, false
+}
+
+// canonicalPath 返回用于规则匹配的规范路径（保留末尾的 /）
+// 规则按路径段匹配，/webhook/../admin 原样转发时会被上游解析为 /admin，绕过规则，因此：
+//   - 解码后的路径（%2F、%5C、\ 均视为分隔符）按规范路径匹配，编码斜杠本身不影响转发（如 GitLab 项目路径）
+//   - 未编码的 . / .. 或重复斜杠返回 redirect，由调用方重定向到规范路径
+//   - 与编码分隔符混用的 . / ..（如 /webhook%2F..%2Fadmin）在不同上游中解释不一，ok 为 false
+func canonicalPath(u *url.URL) (clean, redirect string, ok bool) {
+	if !strings.HasPrefix(u.Path, "/") {
+		return "", "", false
+	}
+	decoded := strings.ReplaceAll(u.Path, "\\", "/")
+	clean = path.Clean(decoded)
+	if strings.HasSuffix(decoded, "/") && clean != "/" {
+		clean += "/"
+	}
+	if clean == decoded {
+		return clean, "", true
+	}
+	raw := strings.ToLower(u.EscapedPath())
+	if strings.Contains(u.Path, "\\") || strings.Contains(raw, "%2f") || strings.Contains(raw, "%5c") {
+		return "", "", false
+	}
+	return clean, clean, true
+}
+
+func hasGlob(s string) bool {
+	return strings.ContainsAny(s, "*?[")
+}
@@ -0,0 +1,247 @@
+package authproxy
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestPathRuleMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// 前缀按路径段边界匹配
+		{"/webhook", "/webhook", true},
+		{"/webhook", "/webhook/", true},
+		{"/webhook", "/webhook/github", true},
+		{"/webhook", "/webhooks", false},
+		{"/webhook", "/webhook-evil", false},
+		{"/webhook/", "/webhook/x", true},
+		{"/webhook/", "/webhook", true},
+		{"/", "/anything", true},
+		// /** 匹配任意层级，包括自身
+		{"/api/**", "/api", true},
+		{"/api/**", "/api/v1/users/1", true},
+		{"/api/**", "/apis", false},
+		{"/api/**", "/apis/v1", false},
+		// glob 单层匹配
+		{"/static/*.js", "/static/app.js", true},
+		{"/static/*.js", "/static/sub/app.js", false},
+		{"/static/*.js", "/static/app.css", false},
+		{"/files/?.txt", "/files/a.txt", true},
+		{"/files/?.txt", "/files/ab.txt", false},
+		{"/v[12]/ping", "/v2/ping", true},
+		{"/v[12]/ping", "/v3/ping", false},
+	}
+	for _, tt := range tests {
+		r, err := NewPathRule(tt.pattern, "public", nil)
+		if err != nil {
+			t.Fatalf("NewPathRule(%q): %v", tt.pattern, err)
+		}
+		if got := r.Match(tt.path); got != tt.want {
+			t.Errorf("%q.Match(%q) = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestNewPathRule(t *testing.T) {
+	tests := []struct {
+		pattern, access string
+		users           []string
+		wantErr         bool
+		wantPublic      bool
+	}{
+		{"/webhook", "public", nil, false, true},
+		{"/admin", "protected", []string{"alice"}, false, false},
+		{"/admin", "", nil, false, false},
+		{"webhook", "public", nil, true, false},
+		{"/static/[a", "public", nil, true, false},
+		{"/admin", "private", nil, true, false},
+	}
+	for _, tt := range tests {
+		r, err := NewPathRule(tt.pattern, tt.access, tt.users)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("NewPathRule(%q, %q) err = %v, wantErr %v", tt.pattern, tt.access, err, tt.wantErr)
+			continue
+		}
+		if err == nil && r.Public != tt.wantPublic {
+			t.Errorf("NewPathRule(%q, %q).Public = %v, want %v", tt.pattern, tt.access, r.Public, tt.wantPublic)
+		}
+	}
+}
+
+func TestPathRuleAllows(t *testing.T) {
+	open := PathRule{Pattern: "/"}
+	if !open.Allows("anyone") {
+		t.Error("rule without Users should allow every user")
+	}
+	admin := PathRule{Pattern: "/admin", Users: []string{"alice", "bob"}}
+	for user, want := range map[string]bool{"alice": true, "bob": true, "carol": false, "": false} {
+		if got := admin.Allows(user); got != want {
+			t.Errorf("Allows(%q) = %v, want %v", user, got, want)
+		}
+	}
+}
+
+// newRuleProxy 创建带 /webhook 公开规则和 /admin 仅 alice 可访问规则的代理，上游回显请求路径
+func newRuleProxy(t *testing.T) *Proxy {
+	t.Helper()
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "upstream:"+r.URL.Path)
+	}))
+	t.Cleanup(upstream.Close)
+
+	webhook, _ := NewPathRule("/webhook", "public", nil)
+	admin, _ := NewPathRule("/admin", "protected", []string{"alice"})
+	p, err := New(Config{
+		Name:       "test",
+		Username:   "bob",
+		Password:   "secret",
+		Target:     upstream.URL,
+		SigningKey: RandomKey(),
+		Rules:      []PathRule{webhook, admin},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.listener.Close() })
+	return p
+}
+
+func TestServeCanonicalPath(t *testing.T) {
+	p := newRuleProxy(t)
+	tests := []struct {
+		target   string
+		status   int
+		location string // 重定向目标
+		body     string // 转发到上游时的响应
+	}{
+		{"/webhook/github", http.StatusOK, "", "upstream:/webhook/github"},
+		{"/webhook/", http.StatusOK, "", "upstream:/webhook/"},
+		// 路径穿越：重定向到规范路径，不转发
+		{"/webhook/../admin", http.StatusPermanentRedirect, "/admin", ""},
+		{"/webhook/../admin/", http.StatusPermanentRedirect, "/admin/", ""},
+		{"/webhook/./../admin?x=1", http.StatusPermanentRedirect, "/admin?x=1", ""},
+		{"/webhook/%2e%2e/admin", http.StatusPermanentRedirect, "/admin", ""},
+		{"/webhook//x", http.StatusPermanentRedirect, "/webhook/x", ""},
+		{"/webhook/../../../admin", http.StatusPermanentRedirect, "/admin", ""},
+		// 编码的斜杠按分隔符匹配规则，原样转发
+		{"/webhook%2Fgithub", http.StatusOK, "", "upstream:/webhook/github"},
+		// 编码的斜杠 / 反斜杠与 .. 混用：拒绝
+		{"/webhook%2F..%2Fadmin", http.StatusBadRequest, "", ""},
+		{"/webhook%2f..%2fadmin", http.StatusBadRequest, "", ""},
+		{"/webhook/..%5Cadmin", http.StatusBadRequest, "", ""},
+		{"/webhook/..\\admin", http.StatusBadRequest, "", ""},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+		if w.Code != tt.status {
+			t.Errorf("GET %s: status %d, want %d", tt.target, w.Code, tt.status)
+			continue
+		}
+		if loc := w.Header().Get("Location"); loc != tt.location {
+			t.Errorf("GET %s: Location %q, want %q", tt.target, loc, tt.location)
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("GET %s: body %q, want %q", tt.target, w.Body.String(), tt.body)
+		}
+		if tt.body == "" && strings.Contains(w.Body.String(), "upstream:") {
+			t.Errorf("GET %s: request reached upstream: %q", tt.target, w.Body.String())
+		}
+	}
+}
+
+func TestServeUsersRuleTraversal(t *testing.T) {
+	p := newRuleProxy(t)
+	sess, err := p.sessions.Create("bob", "127.0.0.1", p.cfg.CookieTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := p.cfg.Cookie.sessionCookie(encodeSession(p.cfg.SigningKey, sess.ID), 0)
+
+	get := func(target string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, target, nil)
+		r.AddCookie(cookie)
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		return w
+	}
+	// bob 不在 /admin 的用户列表中
+	if w := get("/admin"); w.Code != http.StatusForbidden {
+		t.Errorf("GET /admin as bob: status %d, want 403", w.Code)
+	}
+	if w := get("/other"); w.Code != http.StatusOK || w.Body.String() != "upstream:/other" {
+		t.Errorf("GET /other as bob: status %d body %q", w.Code, w.Body.String())
+	}
+	for _, target := range []string{"/other/../admin", "/other/%2e%2e/admin", "/other%2F..%2Fadmin"} {
+		w := get(target)
+		if w.Code == http.StatusOK {
+			t.Errorf("GET %s as bob: reached upstream: %q", target, w.Body.String())
+		}
+	}
+}
+
+func TestServeWithoutRules(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "upstream:"+r.URL.EscapedPath())
+	}))
+	t.Cleanup(upstream.Close)
+	p, err := New(Config{Name: "test", Anonymous: true, Target: upstream.URL, SigningKey: RandomKey()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { p.listener.Close() })
+
+	// 未配置路径规则时不做规范化，编码斜杠等原样转发
+	for _, target := range []string{"/api/v4/projects/group%2Fproject", "/v2/a/../b", "/keys/a%5Cb"} {
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+		if w.Code != http.StatusOK || w.Body.String() != "upstream:"+target {
+			t.Errorf("GET %s: status %d body %q", target, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestServeWebSocketRules(t *testing.T) {
+	p := newRuleProxy(t)
+	sess, err := p.sessions.Create("bob", "127.0.0.1", p.cfg.CookieTTL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cookie := p.cfg.Cookie.sessionCookie(encodeSession(p.cfg.SigningKey, sess.ID), 0)
+
+	tests := []struct {
+		name     string
+		target   string
+		loggedIn bool
+		status   int
+		upstream bool
+	}{
+		{"public path", "/webhook/ws", false, http.StatusOK, true},
+		{"protected path without session", "/other/ws", false, http.StatusUnauthorized, false},
+		{"user rule without session", "/admin/ws", false, http.StatusUnauthorized, false},
+		{"user not in rule", "/admin/ws", true, http.StatusForbidden, false},
+		{"protected path with session", "/other/ws", true, http.StatusOK, true},
+	}
+	for _, tt := range tests {
+		r := httptest.NewRequest(http.MethodGet, tt.target, nil)
+		r.Header.Set("Connection", "Upgrade")
+		r.Header.Set("Upgrade", "websocket")
+		if tt.loggedIn {
+			r.AddCookie(cookie)
+		}
+		w := httptest.NewRecorder()
+		p.ServeHTTP(w, r)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d", tt.name, w.Code, tt.status)
+		}
+		if got := strings.HasPrefix(w.Body.String(), "upstream:"); got != tt.upstream {
+			t.Errorf("%s: reached upstream = %v, want %v", tt.name, got, tt.upstream)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package authproxy
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+)
+
+// touchInterval 滑动过期时最短的落盘间隔，避免每个请求都写文件
+const touchInterval = time.Minute
+
+const (
+	lockTimeout = 3 * time.Second  // 等待会话文件锁的最长时间
+	staleLock   = 10 * time.Second // 超过该时长的锁文件视为持有进程已退出
+)
+
+// Session 登录会话
+type Session struct {
+	ID        string    `json:"id"`
+	Username  string    `json:"username"`
+	IP        string    `json:"ip,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+	LastSeen  time.Time `json:"last_seen"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// SessionStorePath 返回路由会话文件路径
+func SessionStorePath(route string) string {
+	return filepath.Join(config.Dir(), "sessions", route+".json")
+}
+
+// SessionStore 会话存储
+// path 为空时仅保存在内存（quick 模式）；否则持久化为 JSON 文件，
+// 文件被其他进程（如 cftunnel auth sessions revoke）修改后会自动重新加载，
+// 修改操作在 <path>.lock 文件锁内重新读取后写回，多个进程同时修改不会互相覆盖
+type SessionStore struct {
+	path     string
+	mu       sync.Mutex
+	sessions map[string]*Session
+	modTime  time.Time
+	size     int64
+}
+
+// OpenSessionStore 打开会话存储，文件不存在时视为空
+func OpenSessionStore(path string) *SessionStore {
+	s := &SessionStore{path: path, sessions: map[string]*Session{}}
+	s.mu.Lock()
+	s.reload(false)
+	s.mu.Unlock()
+	return s
+}
+
+// Create 创建新会话
+func (s *SessionStore) Create(username, ip string, ttl time.Duration) (*Session, error) {
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	sess := &Session{
+		ID:        hex.EncodeToString(id),
+		Username:  username,
+		IP:        ip,
+		CreatedAt: now,
+		LastSeen:  now,
+		ExpiresAt: now.Add(ttl),
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	err := s.update(func() bool {
+		s.sessions[sess.ID] = sess
+		return true
+	})
+	return sess, err
+}
+
+// Get 返回未过期的会话，不存在或已过期时返回 nil
+func (s *SessionStore) Get(id string) *Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload(false)
+	sess, ok := s.sessions[id]
+	if !ok || time.Now().After(sess.ExpiresAt) {
+		return nil
+	}
+	cp := *sess
+	return &cp
+}
+
+// Touch 刷新会话活跃时间，sliding 为 true 时同时顺延过期时间
+func (s *SessionStore) Touch(id string, ttl time.Duration, sliding bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[id]
+	if !ok {
+		return
+	}
+	now := time.Now()
+	if now.Sub(sess.LastSeen) < touchInterval {
+		return
+	}
+	s.update(func() bool {
+		sess, ok := s.sessions[id]
+		if !ok {
+			return false
+		}
+		sess.LastSeen = now
+		if sliding {
+			sess.ExpiresAt = now.Add(ttl)
+		}
+		return true
+	})
+}
+
+// Delete 删除单个会话
+func (s *SessionStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.update(func() bool {
+		if _, ok := s.sessions[id]; !ok {
+			return false
+		}
+		delete(s.sessions, id)
+		return true
+	})
+}
+
+// List 返回所有未过期会话（按创建时间排序）
+func (s *SessionStore) List() []Session {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reload(false)
+	now := time.Now()
+	var list []Session
+	for _, sess := range s.sessions {
+		if now.Before(sess.ExpiresAt) {
+			list = append(list, *sess)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].CreatedAt.Before(list[j].CreatedAt) })
+	return list
+}
+
+// Revoke 吊销会话，username 为空时吊销全部，返回吊销数量
+func (s *SessionStore) Revoke(username string) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	n := 0
+	err := s.update(func() bool {
+		for id, sess := range s.sessions {
+			if username == "" || sess.Username == username {
+				delete(s.sessions, id)
+				n++
+			}
+		}
+		return n > 0
+	})
+	if err != nil {
+		return 0, err
+	}
+	return n, nil
+}
+
+// update 在文件锁内重新读取会话文件后执行 fn，fn 返回 true 时写回（调用方持有 mu）
+func (s *SessionStore) update(fn func() bool) error {
+	unlock, err := s.lock()
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	// 修改时间精度有限，持锁后总是重新读取，避免基于过期数据写回
+	s.reload(true)
+	if !fn() {
+		return nil
+	}
+	return s.save()
+}
+
+// lock 以独占创建 <path>.lock 的方式获取跨进程文件锁，返回解锁函数
+func (s *SessionStore) lock() (func(), error) {
+	if s.path == "" {
+		return func() {}, nil
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return nil, err
+	}
+	lockPath := s.path + ".lock"
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lockPath) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if stat, err := os.Stat(lockPath); err == nil && time.Since(stat.ModTime()) > staleLock {
+			os.Remove(lockPath)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("等待会话文件锁超时: %s", lockPath)
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+// reload 文件修改时间或大小变化时重新加载，force 为 true 时总是重新读取（调用方持有 mu）
+func (s *SessionStore) reload(force bool) {
+	if s.path == "" {
+		return
+	}
+	stat, err := os.Stat(s.path)
+	if err != nil {
+		if os.IsNotExist(err) && !s.modTime.IsZero() {
+			// 文件被删除，视为全部吊销
+			s.sessions = map[string]*Session{}
+			s.modTime, s.size = time.Time{}, 0
+		}
+		return
+	}
+	if !force && stat.ModTime().Equal(s.modTime) && stat.Size() == s.size {
+		return
+	}
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return
+	}
+	var list []*Session
+	if err := json.Unmarshal(data, &list); err != nil {
+		return
+	}
+	s.sessions = make(map[string]*Session, len(list))
+	for _, sess := range list {
+		s.sessions[sess.ID] = sess
+	}
+	s.modTime, s.size = stat.ModTime(), stat.Size()
+}
+
+// save 清理过期会话并原子写入文件（调用方持有文件锁）
+func (s *SessionStore) save() error {
+	now := time.Now()
+	list := make([]*Session, 0, len(s.sessions))
+	for id, sess := range s.sessions {
+		if now.After(sess.ExpiresAt) {
+			delete(s.sessions, id)
+			continue
+		}
+		list = append(list, sess)
+	}
+	if s.path == "" {
+		return nil
+	}
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0700); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, s.path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if stat, err := os.Stat(s.path); err == nil {
+		s.modTime, s.size = stat.ModTime(), stat.Size()
+	}
+	return nil
+}
@@ -0,0 +1,251 @@
+package authproxy
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// setModTime 把会话文件的修改时间设为 t，避免依赖文件系统时间精度
+func setModTime(t *testing.T, path string, mt time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSessionStoreSave(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "sessions", "web.json")
+	s := OpenSessionStore(path)
+	sess, err := s.Create("bob", "203.0.113.7", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Create("bob", "", -time.Second); err != nil {
+		t.Fatal(err)
+	}
+
+	stat, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := stat.Mode().Perm(); perm != 0600 {
+		t.Errorf("session file perm = %o, want 600", perm)
+	}
+	if dir, _ := os.Stat(filepath.Dir(path)); dir.Mode().Perm() != 0700 {
+		t.Errorf("session dir perm = %o, want 700", dir.Mode().Perm())
+	}
+	if _, err := os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temp file left behind: %v", err)
+	}
+
+	// 过期会话在写入时清理
+	data, _ := os.ReadFile(path)
+	var list []Session
+	if err := json.Unmarshal(data, &list); err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].ID != sess.ID || list[0].IP != "203.0.113.7" {
+		t.Errorf("saved sessions = %+v, want only %s", list, sess.ID)
+	}
+}
+
+func TestSessionStoreReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.json")
+	proxy := OpenSessionStore(path) // 鉴权代理进程
+	cli := OpenSessionStore(path)   // cftunnel auth sessions 命令
+	base := time.Now().Add(-time.Hour)
+
+	alice, _ := proxy.Create("alice", "", time.Hour)
+	bob, _ := proxy.Create("bob", "", time.Hour)
+	setModTime(t, path, base)
+
+	tests := []struct {
+		name   string
+		change func() // 另一进程对会话文件的修改
+		want   map[string]bool
+	}{
+		{"created by other process", func() {}, map[string]bool{alice.ID: true, bob.ID: true}},
+		{"revoke user", func() {
+			if n, err := cli.Revoke("bob"); n != 1 || err != nil {
+				t.Fatalf("Revoke(bob) = %d, %v", n, err)
+			}
+		}, map[string]bool{alice.ID: true, bob.ID: false}},
+		// 文件损坏时保留已加载的会话
+		{"corrupt file", func() { os.WriteFile(path, []byte("{"), 0600) }, map[string]bool{alice.ID: true, bob.ID: false}},
+		{"file removed", func() { os.Remove(path) }, map[string]bool{alice.ID: false, bob.ID: false}},
+	}
+	for i, tt := range tests {
+		tt.change()
+		if _, err := os.Stat(path); err == nil {
+			setModTime(t, path, base.Add(time.Duration(i+1)*time.Second))
+		}
+		for id, want := range tt.want {
+			if got := proxy.Get(id) != nil; got != want {
+				t.Errorf("%s: Get(%s) present = %v, want %v", tt.name, id[:8], got, want)
+			}
+		}
+	}
+
+	// 修改时间相同但大小变化时重新读取（文件系统时间精度可能区分不出两次写入）
+	carol, _ := proxy.Create("carol", "", time.Hour)
+	stat, _ := os.Stat(path)
+	os.WriteFile(path, []byte("[]"), 0600)
+	setModTime(t, path, stat.ModTime())
+	if proxy.Get(carol.ID) != nil {
+		t.Error("store not reloaded although file size changed")
+	}
+
+	// 修改时间和大小均未变时不重新读取文件
+	dave, _ := proxy.Create("dave", "", time.Hour)
+	stat, _ = os.Stat(path)
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, bytes.Replace(data, []byte(`"dave"`), []byte(`"evan"`), 1), 0600)
+	setModTime(t, path, stat.ModTime())
+	if sess := proxy.Get(dave.ID); sess == nil || sess.Username != "dave" {
+		t.Error("store reloaded although neither modification time nor size changed")
+	}
+}
+
+func TestSessionStoreConcurrentWriters(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.json")
+	// 多个进程（各自独立的 SessionStore）同时修改同一会话文件
+	const writers, perWriter = 4, 10
+	var wg sync.WaitGroup
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s := OpenSessionStore(path)
+			for j := 0; j < perWriter; j++ {
+				if _, err := s.Create("bob", "", time.Hour); err != nil {
+					t.Error(err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if n := len(OpenSessionStore(path).List()); n != writers*perWriter {
+		t.Errorf("sessions after concurrent writes = %d, want %d", n, writers*perWriter)
+	}
+	if _, err := os.Stat(path + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("lock file left behind: %v", err)
+	}
+}
+
+func TestSessionStoreStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.json")
+	// 持锁进程异常退出留下的锁文件
+	lock := path + ".lock"
+	if err := os.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	setModTime(t, lock, time.Now().Add(-2*staleLock))
+	if _, err := OpenSessionStore(path).Create("bob", "", time.Hour); err != nil {
+		t.Errorf("Create with stale lock: %v", err)
+	}
+}
+
+func TestSessionStoreRevoke(t *testing.T) {
+	tests := []struct {
+		username string
+		want     int
+		left     int
+	}{
+		{"bob", 2, 1},
+		{"", 3, 0},
+		{"nobody", 0, 3},
+	}
+	for _, tt := range tests {
+		s := OpenSessionStore(filepath.Join(t.TempDir(), "web.json"))
+		s.Create("bob", "", time.Hour)
+		s.Create("bob", "", time.Hour)
+		s.Create("alice", "", time.Hour)
+		n, err := s.Revoke(tt.username)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != tt.want || len(s.List()) != tt.left {
+			t.Errorf("Revoke(%q) = %d leaving %d, want %d leaving %d", tt.username, n, len(s.List()), tt.want, tt.left)
+		}
+	}
+}
+
+func TestSessionStoreTouch(t *testing.T) {
+	tests := []struct {
+		name        string
+		idle        time.Duration // 距上次活跃的时长
+		sliding     bool
+		wantSeen    bool // LastSeen 是否刷新
+		wantExtend  bool // ExpiresAt 是否顺延
+		wantWritten bool // 是否写入文件
+	}{
+		{"within interval", touchInterval / 2, true, false, false, false},
+		{"sliding", 2 * touchInterval, true, true, true, true},
+		{"fixed expiry", 2 * touchInterval, false, true, false, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "web.json")
+		s := OpenSessionStore(path)
+		sess, _ := s.Create("bob", "", time.Hour)
+		lastSeen := time.Now().Add(-tt.idle)
+		expires := sess.ExpiresAt.Add(-time.Minute)
+		s.sessions[sess.ID].LastSeen = lastSeen
+		s.sessions[sess.ID].ExpiresAt = expires
+		// 修改时会在文件锁内重新读取，调整后的会话需同时写入文件
+		if err := s.save(); err != nil {
+			t.Fatal(err)
+		}
+		old := time.Now().Add(-time.Hour)
+		setModTime(t, path, old)
+		s.modTime = old
+
+		s.Touch(sess.ID, 2*time.Hour, tt.sliding)
+		got := s.Get(sess.ID)
+		if seen := got.LastSeen.After(lastSeen); seen != tt.wantSeen {
+			t.Errorf("%s: LastSeen refreshed = %v, want %v", tt.name, seen, tt.wantSeen)
+		}
+		if extended := got.ExpiresAt.After(expires); extended != tt.wantExtend {
+			t.Errorf("%s: ExpiresAt extended = %v, want %v", tt.name, extended, tt.wantExtend)
+		}
+		stat, _ := os.Stat(path)
+		if written := !stat.ModTime().Equal(old); written != tt.wantWritten {
+			t.Errorf("%s: file written = %v, want %v", tt.name, written, tt.wantWritten)
+		}
+	}
+}
+
+func TestSessionStoreTouchRevoked(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "web.json")
+	proxy := OpenSessionStore(path)
+	sess, _ := proxy.Create("bob", "", time.Hour)
+	proxy.sessions[sess.ID].LastSeen = time.Now().Add(-2 * touchInterval)
+	setModTime(t, path, time.Now().Add(-time.Hour))
+
+	// 另一进程吊销后，Touch 不能把会话写回文件
+	cli := OpenSessionStore(path)
+	cli.Revoke("bob")
+	setModTime(t, path, time.Now().Add(-time.Minute))
+	proxy.Touch(sess.ID, time.Hour, true)
+	if OpenSessionStore(path).Get(sess.ID) != nil {
+		t.Error("Touch resurrected a revoked session")
+	}
+}
+
+func TestSessionStoreMemory(t *testing.T) {
+	s := OpenSessionStore("")
+	sess, err := s.Create("bob", "", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Get(sess.ID) == nil {
+		t.Fatal("in-memory session not found")
+	}
+	if err := s.Delete(sess.ID); err != nil || s.Get(sess.ID) != nil {
+		t.Errorf("Delete: err %v, session still present %v", err, s.Get(sess.ID) != nil)
+	}
+}
@@ -0,0 +1,91 @@
+package authproxy
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/metrics"
+)
+
+// statKey 请求计数维度
+type statKey struct {
+	event string
+	code  int
+}
+
+// proxyStats 代理请求统计，供 /metrics 输出
+type proxyStats struct {
+	mu       sync.Mutex
+	requests map[statKey]uint64
+	latency  *metrics.Histogram
+	inflight atomic.Int64
+}
+
+func newProxyStats() *proxyStats {
+	return &proxyStats{requests: map[statKey]uint64{}, latency: metrics.NewHistogram()}
+}
+
+// observe 记录一次请求的事件、状态码和耗时
+func (s *proxyStats) observe(event string, code int, d time.Duration) {
+	s.mu.Lock()
+	s.requests[statKey{event, code}]++
+	s.mu.Unlock()
+	s.latency.Observe(d.Seconds())
+}
+
+// Metrics 返回该路由的请求数、状态码、延迟和会话数指标（标签 route）
+func (p *Proxy) Metrics() []metrics.Family {
+	labels := metrics.Labels{"route": p.cfg.Name}
+
+	requests := metrics.Family{
+		Name: "cftunnel_authproxy_requests_total",
+		Help: "鉴权代理处理的请求数（按事件和状态码）",
+		Type: metrics.TypeCounter,
+	}
+	p.stats.mu.Lock()
+	keys := make([]statKey, 0, len(p.stats.requests))
+	for k := range p.stats.requests {
+		keys = append(keys, k)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].event != keys[j].event {
+			return keys[i].event < keys[j].event
+		}
+		return keys[i].code < keys[j].code
+	})
+	for _, k := range keys {
+		requests.Samples = append(requests.Samples, metrics.Sample{
+			Labels: labels.With("event", k.event, "code", strconv.Itoa(k.code)),
+			Value:  float64(p.stats.requests[k]),
+		})
+	}
+	p.stats.mu.Unlock()
+
+	fams := []metrics.Family{
+		requests,
+		{
+			Name:    "cftunnel_authproxy_request_duration_seconds",
+			Help:    "鉴权代理请求耗时（含上游响应）",
+			Type:    metrics.TypeHistogram,
+			Samples: p.stats.latency.Samples(labels),
+		},
+		{
+			Name:    "cftunnel_authproxy_inflight_requests",
+			Help:    "正在处理的请求数（含 WebSocket 长连接）",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Labels: labels, Value: float64(p.stats.inflight.Load())}},
+		},
+	}
+	if !p.cfg.Anonymous {
+		fams = append(fams, metrics.Family{
+			Name:    "cftunnel_authproxy_sessions",
+			Help:    "有效登录会话数",
+			Type:    metrics.TypeGauge,
+			Samples: []metrics.Sample{{Labels: labels, Value: float64(len(p.sessions.List()))}},
+		})
+	}
+	return fams
+}
@@ -0,0 +1,126 @@
+package authproxy
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// defaultListenStart 上游地址不含端口时，代理监听端口的探测起点
+const defaultListenStart = 18080
+
+// Upstream 上游连接选项
+type Upstream struct {
+	InsecureSkipVerify bool   // 跳过证书校验（自签名证书）
+	ServerName         string // TLS SNI，默认使用上游主机名
+	CAFile             string // 自定义 CA 证书（PEM）
+	HostHeader         string // 空或 preserve：保留原始 Host；rewrite：改写为上游地址；其他值：固定 Host
+}
+
+// parseTarget 解析上游地址，支持 http://、https://、unix:/path 和 unix+tls:/path（与 cloudflared 一致）
+// 返回用于转发的 URL 和 unix socket 路径（非 socket 时为空）
+func parseTarget(target string) (*url.URL, string, error) {
+	for _, s := range []struct{ prefix, scheme string }{{"unix+tls:", "https"}, {"unix:", "http"}} {
+		if strings.HasPrefix(target, s.prefix) {
+			sock := strings.TrimPrefix(target, s.prefix)
+			if sock == "" {
+				return nil, "", fmt.Errorf("unix socket 路径为空: %s", target)
+			}
+			return &url.URL{Scheme: s.scheme, Host: "localhost"}, sock, nil
+		}
+	}
+	u, err := url.Parse(target)
+	if err != nil || u.Host == "" || (u.Scheme != "http" && u.Scheme != "https") {
+		return nil, "", fmt.Errorf("上游地址格式无效（应为 http(s)://主机:端口 或 unix:/路径）: %s", target)
+	}
+	return u, "", nil
+}
+
+// listenStart 返回代理监听端口的探测起点：上游端口 +1，无端口时使用默认值
+func listenStart(u *url.URL) int {
+	if port, err := strconv.Atoi(u.Port()); err == nil && port > 0 && port < 65500 {
+		return port + 1
+	}
+	return defaultListenStart
+}
+
+// newReverseProxy 创建指向上游的反向代理
+func newReverseProxy(target *url.URL, sock string, up Upstream, lim Limits) (*httputil.ReverseProxy, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = lim.ResponseHeaderTimeout
+
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if lim.DialTimeout > 0 {
+		dialer.Timeout = lim.DialTimeout
+	}
+	transport.DialContext = dialer.DialContext
+	if sock != "" {
+		transport.DialContext = func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "unix", sock)
+		}
+	}
+
+	if target.Scheme == "https" {
+		tlsCfg := &tls.Config{
+			InsecureSkipVerify: up.InsecureSkipVerify,
+			ServerName:         up.ServerName,
+		}
+		if up.CAFile != "" {
+			pem, err := os.ReadFile(up.CAFile)
+			if err != nil {
+				return nil, fmt.Errorf("读取 CA 证书失败: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("CA 证书无效: %s", up.CAFile)
+			}
+			tlsCfg.RootCAs = pool
+		}
+		transport.TLSClientConfig = tlsCfg
+	}
+
+	rp := httputil.NewSingleHostReverseProxy(target)
+	rp.Transport = transport
+	director := rp.Director
+	rp.Director = func(r *http.Request) {
+		director(r)
+		switch up.HostHeader {
+		case "", "preserve":
+			// 保留访问者请求的 Host（与 cloudflared 默认行为一致）
+		case "rewrite":
+			r.Host = target.Host
+		default:
+			r.Host = up.HostHeader
+		}
+	}
+	rp.ErrorHandler = upstreamError
+	return rp, nil
+}
+
+// upstreamError 将转发错误映射为状态码：请求体超限 413、上游超时 504、其他 502
+func upstreamError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	var netErr net.Error
+	status := http.StatusBadGateway
+	switch {
+	case errors.As(err, &maxErr):
+		status = http.StatusRequestEntityTooLarge
+	case errors.Is(err, context.DeadlineExceeded), errors.As(err, &netErr) && netErr.Timeout():
+		status = http.StatusGatewayTimeout
+	}
+	if status != http.StatusRequestEntityTooLarge {
+		log.Printf("[authproxy] 转发 %s 失败: %v", r.URL.Path, err)
+	}
+	w.WriteHeader(status)
+}
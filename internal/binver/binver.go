@@ -0,0 +1,112 @@
+// Package binver 管理 bin 目录中的外部二进制（cloudflared / frpc / frps）：
+// 版本探测、升级时保留上一版本以及回滚
+package binver
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/verify"
+)
+
+var versionRe = regexp.MustCompile(`\d+\.\d+\.\d+`)
+
+// Version 执行 path args... 并从输出中提取版本号，无法识别时返回空
+func Version(path string, args ...string) string {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	out, err := exec.CommandContext(ctx, path, args...).CombinedOutput()
+	if err != nil && len(out) == 0 {
+		return ""
+	}
+	return versionRe.FindString(string(out))
+}
+
+// Normalize 去掉版本号前缀 v，便于比较
+func Normalize(v string) string {
+	return strings.TrimPrefix(strings.TrimSpace(v), "v")
+}
+
+// withSuffix 在扩展名前插入后缀（Windows 上需保留 .exe 才能执行）
+func withSuffix(path, suffix string) string {
+	ext := filepath.Ext(path)
+	if ext != ".exe" {
+		return path + suffix
+	}
+	return strings.TrimSuffix(path, ext) + suffix + ext
+}
+
+// PrevPath 上一版本二进制的保存路径
+func PrevPath(path string) string {
+	return withSuffix(path, ".prev")
+}
+
+// NewPath 下载新版本时使用的临时路径，校验通过后由 Replace 替换到正式路径
+func NewPath(path string) string {
+	return withSuffix(path, ".new")
+}
+
+// Discard 删除下载失败的临时二进制及其校验记录
+func Discard(newPath string) {
+	os.Remove(newPath)
+	os.Remove(verify.SumPath(newPath))
+}
+
+// Replace 用 newPath 替换 path，原二进制（及校验记录）保留为 PrevPath 供回滚
+// 运行中的进程不受影响（Unix 与 Windows 均允许重命名正在执行的文件）
+func Replace(path, newPath string) error {
+	prev := PrevPath(path)
+	hasCurrent := false
+	if _, err := os.Stat(path); err == nil {
+		hasCurrent = true
+		os.Remove(prev)
+		os.Remove(verify.SumPath(prev))
+		if err := os.Rename(path, prev); err != nil {
+			return fmt.Errorf("备份当前版本失败: %w", err)
+		}
+		os.Rename(verify.SumPath(path), verify.SumPath(prev))
+	}
+	if err := os.Rename(newPath, path); err != nil {
+		if hasCurrent {
+			os.Rename(prev, path)
+			os.Rename(verify.SumPath(prev), verify.SumPath(path))
+		}
+		return fmt.Errorf("替换二进制失败: %w", err)
+	}
+	if _, err := verify.Record(path); err != nil {
+		fmt.Printf("警告: 记录校验值失败: %v\n", err)
+	}
+	os.Remove(verify.SumPath(newPath))
+	return nil
+}
+
+// Rollback 交换当前版本与上一版本，再次执行即可撤销回滚
+func Rollback(path string) error {
+	prev := PrevPath(path)
+	if _, err := os.Stat(prev); err != nil {
+		return fmt.Errorf("没有可回滚的上一版本（%s 不存在）", prev)
+	}
+	tmp := withSuffix(path, ".swap")
+	os.Remove(tmp)
+	if err := os.Rename(path, tmp); err != nil {
+		return fmt.Errorf("回滚失败: %w", err)
+	}
+	if err := os.Rename(prev, path); err != nil {
+		os.Rename(tmp, path)
+		return fmt.Errorf("回滚失败: %w", err)
+	}
+	os.Rename(tmp, prev)
+
+	// 校验记录随二进制一起交换
+	sum, prevSum, tmpSum := verify.SumPath(path), verify.SumPath(prev), verify.SumPath(tmp)
+	os.Rename(sum, tmpSum)
+	os.Rename(prevSum, sum)
+	os.Rename(tmpSum, prevSum)
+	return nil
+}
@@ -53,10 +53,27 @@ func (c *Client) PushIngressConfig(ctx context.Context, tunnelID string, routes
 	// 添加 catch-all 规则
 	ingress := make([]zero_trust.TunnelCloudflaredConfigurationUpdateParamsConfigIngress, 0, len(routes)+1)
 	for _, r := range routes {
-		ingress = append(ingress, zero_trust.TunnelCloudflaredConfigurationUpdateParamsConfigIngress{
+		rule := zero_trust.TunnelCloudflaredConfigurationUpdateParamsConfigIngress{
 			Hostname: cf.F(r.Hostname),
 			Service:  cf.F(r.Service),
-		})
+		}
+		if r.NoTLSVerify || r.OriginServerName != "" || r.CAPool != "" || r.HTTPHostHeader != "" {
+			origin := zero_trust.TunnelCloudflaredConfigurationUpdateParamsConfigIngressOriginRequest{}
+			if r.NoTLSVerify {
+				origin.NoTLSVerify = cf.F(true)
+			}
+			if r.OriginServerName != "" {
+				origin.OriginServerName = cf.F(r.OriginServerName)
+			}
+			if r.CAPool != "" {
+				origin.CAPool = cf.F(r.CAPool)
+			}
+			if r.HTTPHostHeader != "" {
+				origin.HTTPHostHeader = cf.F(r.HTTPHostHeader)
+			}
+			rule.OriginRequest = cf.F(origin)
+		}
+		ingress = append(ingress, rule)
 	}
 	ingress = append(ingress, zero_trust.TunnelCloudflaredConfigurationUpdateParamsConfigIngress{
 		Service: cf.F("http_status:404"),
@@ -78,6 +95,12 @@ func (c *Client) PushIngressConfig(ctx context.Context, tunnelID string, routes
 type IngressRule struct {
 	Hostname string
 	Service  string
+
+	// originRequest 选项（可选）
+	NoTLSVerify      bool
+	OriginServerName string
+	CAPool           string
+	HTTPHostHeader   string
 }
 
 // GetTunnelToken 获取隧道运行 Token
@@ -16,6 +16,8 @@ type Config struct {
 	Relay       RelayConfig       `yaml:"relay,omitempty"`
 	Cloudflared CloudflaredConfig `yaml:"cloudflared"`
 	SelfUpdate  SelfUpdateConfig  `yaml:"self_update"`
+	Logs        *LogsConfig       `yaml:"logs,omitempty"`
+	Download    *DownloadConfig   `yaml:"download,omitempty"`
 }
 
 type AuthConfig struct {
@@ -36,14 +38,100 @@ type RouteConfig struct {
 	ZoneID      string     `yaml:"zone_id"`
 	DNSRecordID string     `yaml:"dns_record_id"`
 	Auth        *AuthProxy `yaml:"auth,omitempty"`
+	Upstream    *Upstream  `yaml:"upstream,omitempty"`
+	Limits      *Limits    `yaml:"limits,omitempty"`
+	ProxyPort   int        `yaml:"proxy_port,omitempty"` // 本地代理固定端口（启用鉴权或限流时），首次使用时自动分配并写回配置
+}
+
+// Proxied 路由是否需要经过本地代理（启用鉴权或限流）
+func (r *RouteConfig) Proxied() bool {
+	return r.Auth != nil || r.Limits != nil
+}
+
+// Limits 路由限流与请求大小限制（未启用鉴权的路由也会启动轻量代理）
+type Limits struct {
+	RPS             float64 `yaml:"rps,omitempty"`              // 每个访客 IP 每秒请求数
+	Burst           int     `yaml:"burst,omitempty"`            // 突发请求数，默认等于 rps
+	MaxConns        int     `yaml:"max_conns,omitempty"`        // 最大并发请求数，超出返回 429
+	MaxBodyMB       int     `yaml:"max_body_mb,omitempty"`      // 请求体上限（MB），超出返回 413
+	ConnectTimeout  int     `yaml:"connect_timeout,omitempty"`  // 连接上游超时（秒）
+	ResponseTimeout int     `yaml:"response_timeout,omitempty"` // 等待上游响应头超时（秒），超时返回 504
+}
+
+// Upstream 上游连接选项（service 为 https:// 或需要改写 Host 时使用）
+// 鉴权代理和 cloudflared（originRequest）共用同一份配置
+type Upstream struct {
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify,omitempty"` // 跳过证书校验（自签名证书）
+	ServerName         string `yaml:"server_name,omitempty"`          // TLS SNI
+	CAFile             string `yaml:"ca_file,omitempty"`              // 自定义 CA 证书（PEM）
+	HostHeader         string `yaml:"host_header,omitempty"`          // 空/preserve 保留原始 Host，rewrite 改写为上游地址，其他值为固定 Host
 }
 
 // AuthProxy 鉴权代理配置
 type AuthProxy struct {
-	Username  string `yaml:"username"`
-	Password  string `yaml:"password"`
-	SigningKey string `yaml:"signing_key,omitempty"`
-	CookieTTL int    `yaml:"cookie_ttl,omitempty"` // 秒，默认 86400
+	Username      string `yaml:"username"`
+	Password      string `yaml:"password"`
+	SigningKey    string `yaml:"signing_key,omitempty"`
+	CookieTTL     int    `yaml:"cookie_ttl,omitempty"`     // 秒，默认 86400
+	SlidingExpiry bool   `yaml:"sliding_expiry,omitempty"` // 每次访问顺延会话有效期
+
+	// 访问控制：按 CF-Connecting-IP / CF-IPCountry 判断，deny 优先于 allow
+	AllowIPs          []string `yaml:"allow_ips,omitempty"` // IP 或 CIDR，如 203.0.113.0/24
+	DenyIPs           []string `yaml:"deny_ips,omitempty"`
+	AllowCountries    []string `yaml:"allow_countries,omitempty"` // ISO 3166 国家代码，如 CN、US
+	DenyCountries     []string `yaml:"deny_countries,omitempty"`
+	AllowIPsSkipLogin bool     `yaml:"allow_ips_skip_login,omitempty"` // 命中 allow_ips 的请求免登录
+
+	Groups []string   `yaml:"groups,omitempty"` // 主账号所属分组
+	Users  []AuthUser `yaml:"users,omitempty"`  // 额外账号（username/password 之外）
+	Paths  []PathRule `yaml:"paths,omitempty"`  // 路径规则，按顺序匹配，首个命中生效；未命中时需登录
+
+	Identity  *IdentityHeaders `yaml:"identity,omitempty"`   // 注入到上游的身份头
+	LoginPage *LoginPage       `yaml:"login_page,omitempty"` // 登录页定制
+	Cookie    *CookieConfig    `yaml:"cookie,omitempty"`     // 会话 Cookie 选项
+}
+
+// CookieConfig 会话 Cookie 选项
+// 未设置 domain 且 path 为 / 时自动使用 __Host- 前缀（浏览器强制 Secure、禁止子域覆盖）
+type CookieConfig struct {
+	Name     string `yaml:"name,omitempty"`      // 默认 cftunnel_auth
+	Domain   string `yaml:"domain,omitempty"`    // 如 .example.com，在子域间共享登录态
+	Path     string `yaml:"path,omitempty"`      // 默认 /
+	SameSite string `yaml:"same_site,omitempty"` // lax（默认）/ strict / none
+}
+
+// LoginPage 登录页定制
+type LoginPage struct {
+	TemplateDir string `yaml:"template_dir,omitempty"` // 自定义模板目录（login.html 必需，forbidden.html 可选，Go html/template 语法）
+	Title       string `yaml:"title,omitempty"`        // 页面标题
+	Logo        string `yaml:"logo,omitempty"`         // Logo 图片 URL
+	Color       string `yaml:"color,omitempty"`        // 主色调，如 "#3b82f6"
+	Locale      string `yaml:"locale,omitempty"`       // zh / en，为空时按 Accept-Language 自动选择
+}
+
+// AuthUser 鉴权账号
+type AuthUser struct {
+	Username string   `yaml:"username"`
+	Password string   `yaml:"password"`
+	Groups   []string `yaml:"groups,omitempty"`
+}
+
+// IdentityHeaders 鉴权通过后注入到上游的身份头，头名为空时使用默认值
+// 客户端传入的同名头及所有 X-Cftunnel-* 头都会被剥离
+type IdentityHeaders struct {
+	UserHeader   string `yaml:"user_header,omitempty"`   // 默认 X-Cftunnel-User
+	MethodHeader string `yaml:"method_header,omitempty"` // 默认 X-Cftunnel-Auth-Method（password / ip）
+	GroupsHeader string `yaml:"groups_header,omitempty"` // 默认 X-Cftunnel-Groups
+	JWTHeader    string `yaml:"jwt_header,omitempty"`    // 默认 X-Cftunnel-Jwt
+	JWTKey       string `yaml:"jwt_key,omitempty"`       // HS256 密钥，设置后额外注入短期 JWT
+	JWTTTL       int    `yaml:"jwt_ttl,omitempty"`       // 秒，默认 60
+}
+
+// PathRule 路径鉴权规则
+type PathRule struct {
+	Path   string   `yaml:"path"`            // 前缀（如 /webhook）或 glob（如 /static/*.js）
+	Access string   `yaml:"access"`          // public / protected
+	Users  []string `yaml:"users,omitempty"` // 仅 protected 有效，限定可访问的用户
 }
 
 // CookieTTLOrDefault 返回 Cookie 有效期（秒），默认 86400
@@ -56,9 +144,11 @@ func (a *AuthProxy) CookieTTLOrDefault() int {
 
 // RelayConfig 中继模式配置
 type RelayConfig struct {
-	Server string      `yaml:"server,omitempty"`
-	Token  string      `yaml:"token,omitempty"`
-	Rules  []RelayRule `yaml:"rules,omitempty"`
+	Server        string      `yaml:"server,omitempty"`
+	Token         string      `yaml:"token,omitempty"`
+	Rules         []RelayRule `yaml:"rules,omitempty"`
+	FrpVersion    string      `yaml:"frp_version,omitempty"`     // 锁定的 frp 版本（cftunnel frp pin），为空使用内置默认版本
+	FrpAutoUpdate bool        `yaml:"frp_auto_update,omitempty"` // 启动中继时自动升级 frpc 到最新版（锁定版本时不生效）
 }
 
 // RelayRule 中继穿透规则
@@ -73,13 +163,39 @@ type RelayRule struct {
 
 type CloudflaredConfig struct {
 	Path       string `yaml:"path"`
-	AutoUpdate bool   `yaml:"auto_update"`
+	AutoUpdate bool   `yaml:"auto_update"`       // 启动隧道时自动升级到最新版（锁定版本时不生效）
+	Version    string `yaml:"version,omitempty"` // 锁定的版本号（cftunnel cloudflared pin），为空跟随最新版
+
+	// 以下为 cloudflared tunnel 运行参数，守护进程与系统服务共用
+	Protocol      string   `yaml:"protocol,omitempty"`        // auto / quic / http2，默认 http2；auto 在 UDP 被阻断时回退 http2
+	EdgeIPVersion string   `yaml:"edge_ip_version,omitempty"` // 连接边缘使用的 IP 版本：auto / 4 / 6
+	Region        string   `yaml:"region,omitempty"`          // 边缘区域，如 us
+	LogLevel      string   `yaml:"loglevel,omitempty"`        // debug / info / warn / error / fatal
+	Retries       int      `yaml:"retries,omitempty"`         // 连接失败最大重试次数
+	GracePeriod   string   `yaml:"grace_period,omitempty"`    // 退出时等待连接结束的时长，如 30s
+	PostQuantum   bool     `yaml:"post_quantum,omitempty"`    // 启用后量子加密（需 QUIC）
+	Metrics       string   `yaml:"metrics,omitempty"`         // cloudflared 指标监听地址，默认在 127.0.0.1:20241-20245 中自动选择
+	Args          []string `yaml:"args,omitempty"`            // 其它 cloudflared tunnel 参数，原样追加在 run 之前
 }
 
 type SelfUpdateConfig struct {
 	AutoCheck bool `yaml:"auto_check"` // 启动时自动检查 cftunnel 更新
 }
 
+// LogsConfig cloudflared / frpc 日志轮转配置，未设置时使用默认值
+type LogsConfig struct {
+	MaxSizeMB  int   `yaml:"max_size_mb,omitempty"`  // 单个文件上限，默认 10MB
+	MaxBackups int   `yaml:"max_backups,omitempty"`  // 保留的历史文件数，默认 5
+	MaxAgeDays int   `yaml:"max_age_days,omitempty"` // 历史文件保留天数，默认 30
+	Compress   *bool `yaml:"compress,omitempty"`     // 是否 gzip 压缩历史文件，默认开启
+}
+
+// DownloadConfig cloudflared / frp / cftunnel 下载设置，未设置时使用内置镜像列表与环境变量代理
+type DownloadConfig struct {
+	Mirrors []string `yaml:"mirrors,omitempty"` // GitHub 镜像前缀，按顺序尝试；"direct" 表示直连 GitHub
+	Proxy   string   `yaml:"proxy,omitempty"`   // HTTP(S) / SOCKS5 代理，如 http://127.0.0.1:7890、socks5://127.0.0.1:1080
+}
+
 var (
 	dirOnce    sync.Once
 	dirPath    string
@@ -107,6 +223,12 @@ func Dir() string {
 	return dirPath
 }
 
+// SetDir 指定配置目录（系统服务以其他用户身份运行时使用），覆盖自动探测结果
+func SetDir(dir string) {
+	Dir()
+	dirPath = dir
+}
+
 // Portable 返回当前是否处于便携模式
 func Portable() bool {
 	Dir() // 确保 dirOnce 已执行
@@ -3,10 +3,14 @@ package daemon
 import (
 	"net"
 	"net/http"
+	"net/url"
+	"os"
 	"os/exec"
 	"strings"
 	"sync"
 	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
 )
 
 const diagnoseTimeout = 5 * time.Second
@@ -15,6 +19,7 @@ const diagnoseTimeout = 5 * time.Second
 type DiagnoseResult struct {
 	Cloudflared CloudflaredCheck  `json:"cloudflared"`
 	API         APICheck          `json:"api"`
+	Edge        EdgeCheck         `json:"edge"`
 	Routes      []RouteDiagnose   `json:"routes"`
 	Total       int               `json:"total"`
 	Passed      int               `json:"passed"`
@@ -60,9 +65,18 @@ func Diagnose(routes []RouteInput) DiagnoseResult {
 	// 检测 Cloudflare API
 	result.API = checkAPI()
 
-	// 并行检测路由
+	// 并行检测边缘连通性与路由
 	result.Routes = make([]RouteDiagnose, len(routes))
 	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		region := ""
+		if cfg, err := config.Load(); err == nil {
+			region = cfg.Cloudflared.Region
+		}
+		result.Edge = checkEdge(region)
+	}()
 	for i, r := range routes {
 		wg.Add(1)
 		go func(idx int, route RouteInput) {
@@ -93,7 +107,7 @@ type RouteInput struct {
 
 func checkCloudflared() CloudflaredCheck {
 	var c CloudflaredCheck
-	path, err := EnsureCloudflared()
+	path, err := EnsureCloudflared(os.Stderr)
 	if err != nil {
 		return c
 	}
@@ -135,10 +149,10 @@ func diagnoseRoute(r RouteInput) RouteDiagnose {
 		Service:  r.Service,
 	}
 
-	// 检测本地服务
-	port := extractPort(r.Service)
-	if port != "" {
-		conn, err := net.DialTimeout("tcp", "127.0.0.1:"+port, diagnoseTimeout)
+	// 检测本地服务（支持远程主机和 unix socket）
+	network, addr := serviceAddr(r.Service)
+	if addr != "" {
+		conn, err := net.DialTimeout(network, addr, diagnoseTimeout)
 		if err == nil {
 			conn.Close()
 			d.LocalOK = true
@@ -146,7 +160,7 @@ func diagnoseRoute(r RouteInput) RouteDiagnose {
 			d.LocalErr = "未监听"
 		}
 	} else {
-		d.LocalErr = "无法解析端口"
+		d.LocalErr = "无法解析地址"
 	}
 
 	// 检测 DNS
@@ -174,17 +188,31 @@ func diagnoseRoute(r RouteInput) RouteDiagnose {
 	return d
 }
 
-// extractPort 从 service 字符串提取端口号（如 http://localhost:3000 → 3000）
-func extractPort(service string) string {
-	// 去掉协议前缀
-	s := service
-	for _, prefix := range []string{"https://", "http://"} {
-		s = strings.TrimPrefix(s, prefix)
+// serviceAddr 从 service 字符串解析拨号地址
+// http://localhost:3000 → tcp 127.0.0.1:3000，https://10.0.0.5 → tcp 10.0.0.5:443，unix:/run/app.sock → unix /run/app.sock
+func serviceAddr(service string) (network, addr string) {
+	for _, prefix := range []string{"unix+tls:", "unix:"} {
+		if strings.HasPrefix(service, prefix) {
+			return "unix", strings.TrimPrefix(service, prefix)
+		}
 	}
-	// 取 host:port 中的 port
-	_, port, err := net.SplitHostPort(s)
-	if err != nil {
-		return ""
+	u, err := url.Parse(service)
+	if err != nil || u.Host == "" {
+		return "", ""
+	}
+	host, port := u.Hostname(), u.Port()
+	if port == "" {
+		switch u.Scheme {
+		case "http":
+			port = "80"
+		case "https":
+			port = "443"
+		default:
+			return "", ""
+		}
+	}
+	if host == "localhost" {
+		host = "127.0.0.1"
 	}
-	return port
+	return "tcp", net.JoinHostPort(host, port)
 }
@@ -5,15 +5,16 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 
+	"github.com/qingchencloud/cftunnel/internal/binver"
 	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/download"
+	"github.com/qingchencloud/cftunnel/internal/verify"
 )
 
 // CloudflaredPath 返回 cloudflared 二进制路径
@@ -25,8 +26,9 @@ func CloudflaredPath() string {
 	return filepath.Join(config.Dir(), "bin", name)
 }
 
-// EnsureCloudflared 确保 cloudflared 已安装，未安装则自动下载
-func EnsureCloudflared() (string, error) {
+// EnsureCloudflared 确保 cloudflared 已安装，未安装则自动下载（config.yml 锁定了版本时下载该版本）
+// 下载提示与进度写入 out
+func EnsureCloudflared(out io.Writer) (string, error) {
 	path := CloudflaredPath()
 	if _, err := os.Stat(path); err == nil {
 		return path, nil
@@ -35,62 +37,108 @@ func EnsureCloudflared() (string, error) {
 	if p, err := exec.LookPath("cloudflared"); err == nil {
 		return p, nil
 	}
-	return path, download(path)
+	pinned := ""
+	if cfg, err := config.Load(); err == nil {
+		pinned = cfg.Cloudflared.Version
+	}
+	if err := fetchCloudflared(out, path, pinned); err != nil {
+		return path, err
+	}
+	fmt.Fprintf(out, "cloudflared 已下载到 %s\n", path)
+	return path, nil
 }
 
-// GitHub 镜像源列表（按优先级排序，最后一个是原始地址兜底）
-var mirrors = []string{
-	"https://ghfast.top/",
-	"https://gh-proxy.com/",
-	"https://ghproxy.cn/",
-	"", // 原始 GitHub 地址
+// CloudflaredVersion 返回 cloudflared 二进制的版本号，无法识别时返回空
+func CloudflaredVersion(path string) string {
+	return binver.Version(path, "--version")
 }
 
-func download(dest string) error {
-	filename, err := downloadFilename()
+// LatestCloudflaredVersion 查询 cloudflared 最新发布版本
+func LatestCloudflaredVersion() (string, error) {
+	rel, err := verify.FetchRelease(cloudflaredRepo, "")
 	if err != nil {
-		return err
+		return "", err
 	}
-	const origin = "https://github.com/cloudflare/cloudflared/releases/latest/download/"
-	fmt.Println("正在下载 cloudflared...")
+	return binver.Normalize(rel.Tag), nil
+}
 
-	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+// InstallCloudflared 下载并校验指定版本（空为最新版），替换 bin 目录中的 cloudflared
+// 原二进制保留为上一版本，可通过 RollbackCloudflared 恢复
+func InstallCloudflared(version string) error {
+	path := CloudflaredPath()
+	tmp := binver.NewPath(path)
+	if err := fetchCloudflared(os.Stdout, tmp, version); err != nil {
+		binver.Discard(tmp)
 		return err
 	}
+	return binver.Replace(path, tmp)
+}
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	var lastErr error
-	for _, mirror := range mirrors {
-		url := mirror + origin + filename
-		src := "GitHub"
-		if mirror != "" {
-			src = strings.TrimRight(mirror, "/")
-		}
-		fmt.Printf("尝试下载: %s ...\n", src)
+// RollbackCloudflared 切换回上一版本 cloudflared（再次执行可撤销）
+func RollbackCloudflared() error {
+	return binver.Rollback(CloudflaredPath())
+}
 
-		resp, err := client.Get(url)
-		if err != nil {
-			fmt.Printf("  连接失败: %v\n", err)
-			lastErr = err
-			continue
-		}
-		if resp.StatusCode != 200 {
-			resp.Body.Close()
-			fmt.Printf("  HTTP %d\n", resp.StatusCode)
-			lastErr = fmt.Errorf("HTTP %d from %s", resp.StatusCode, src)
-			continue
-		}
+// cloudflaredRepo cloudflared 官方仓库
+const cloudflaredRepo = "cloudflare/cloudflared"
 
-		err = saveCloudflared(resp.Body, dest, filename)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = err
-			continue
-		}
-		fmt.Printf("cloudflared 已下载到 %s\n", dest)
-		return nil
+// fetchCloudflared 下载指定版本的 cloudflared 到 dest，version 为空时下载最新版，提示写入 out
+func fetchCloudflared(out io.Writer, dest, version string) error {
+	filename, err := downloadFilename()
+	if err != nil {
+		return err
+	}
+	version = binver.Normalize(version)
+
+	// 校验值从 GitHub API 获取（不经过镜像），并固定下载同一版本
+	origin := "https://github.com/" + cloudflaredRepo + "/releases/latest/download/"
+	if version != "" {
+		origin = "https://github.com/" + cloudflaredRepo + "/releases/download/" + version + "/"
 	}
-	return fmt.Errorf("所有下载源均失败，最后错误: %w", lastErr)
+	want := ""
+	rel, err := verify.FetchRelease(cloudflaredRepo, version)
+	switch {
+	case err == nil && rel.Digests[filename] != "":
+		origin = "https://github.com/" + cloudflaredRepo + "/releases/download/" + rel.Tag + "/"
+		want = rel.Digests[filename]
+	case verify.Skip():
+		fmt.Fprintf(out, "警告: 已设置 %s，跳过 cloudflared 校验\n", verify.SkipEnv)
+	case err != nil:
+		return fmt.Errorf("获取 cloudflared 官方校验值失败: %w（无法访问 api.github.com 时可设置 %s=1 跳过校验）", err, verify.SkipEnv)
+	default:
+		return fmt.Errorf("cloudflared %s 的发布信息中没有 %s 的校验值", rel.Tag, filename)
+	}
+	if rel != nil {
+		version = binver.Normalize(rel.Tag)
+	}
+	if version != "" {
+		fmt.Fprintf(out, "正在下载 cloudflared %s...\n", version)
+	} else {
+		fmt.Fprintln(out, "正在下载 cloudflared...")
+	}
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	key := version
+	if key == "" {
+		key = "latest"
+	}
+	tmp, sum, err := download.Fetch(download.File{Name: filename, URL: origin + filename, SHA256: want, Key: key, Out: out})
+	if err != nil {
+		return err
+	}
+	defer download.Remove(tmp)
+	if err := saveCloudflared(tmp, dest, filename); err != nil {
+		return err
+	}
+	if _, err := verify.Record(dest); err != nil {
+		fmt.Fprintf(out, "警告: 记录校验值失败: %v\n", err)
+	}
+	if want != "" {
+		fmt.Fprintf(out, "✓ SHA-256 校验通过 (%s)\n", sum)
+	}
+	return nil
 }
 
 // saveCloudflared 将下载内容保存到目标路径
@@ -160,3 +208,38 @@ func downloadFilename() (string, error) {
 		return "", fmt.Errorf("不支持的平台: %s/%s", runtime.GOOS, runtime.GOARCH)
 	}
 }
+
+// IsCloudflaredFile 文件名是否为当前平台的 cloudflared 发布文件（或解压后的二进制）
+func IsCloudflaredFile(name string) bool {
+	if name == "cloudflared" || name == "cloudflared.exe" {
+		return true
+	}
+	filename, err := downloadFilename()
+	return err == nil && name == filename
+}
+
+// ImportCloudflared 从本地文件（官方二进制或 .tgz）离线安装 cloudflared，返回其版本
+// 原二进制保留为上一版本，可通过 RollbackCloudflared 恢复
+func ImportCloudflared(src string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	path := CloudflaredPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := binver.NewPath(path)
+	if err := saveCloudflared(f, tmp, filepath.Base(src)); err != nil {
+		binver.Discard(tmp)
+		return "", err
+	}
+	version := CloudflaredVersion(tmp)
+	if version == "" {
+		binver.Discard(tmp)
+		return "", fmt.Errorf("%s 不是可在当前平台运行的 cloudflared", src)
+	}
+	return version, binver.Replace(path, tmp)
+}
@@ -0,0 +1,99 @@
+package daemon
+
+import (
+	"crypto/rand"
+	"fmt"
+	"net"
+	"time"
+)
+
+// cloudflared 连接 Cloudflare 边缘的端口：QUIC 走 UDP，http2 走 TCP
+const edgePort = "7844"
+
+// edgeHost 返回边缘节点域名，指定区域时使用区域节点（如 us-region1.v2.argotunnel.com）
+func edgeHost(region string) string {
+	if region != "" {
+		return region + "-region1.v2.argotunnel.com"
+	}
+	return "region1.v2.argotunnel.com"
+}
+
+// EdgeCheck Cloudflare 边缘连通性
+type EdgeCheck struct {
+	QUIC     bool   `json:"quic"`
+	QUICErr  string `json:"quic_err,omitempty"`
+	HTTP2    bool   `json:"http2"`
+	HTTP2Err string `json:"http2_err,omitempty"`
+	Protocol string `json:"protocol"` // protocol: auto 时将使用的协议
+}
+
+func checkEdge(region string) EdgeCheck {
+	var e EdgeCheck
+	if err := ProbeQUIC(region, diagnoseTimeout); err != nil {
+		e.QUICErr = err.Error()
+	} else {
+		e.QUIC = true
+	}
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(edgeHost(region), edgePort), diagnoseTimeout)
+	if err != nil {
+		e.HTTP2Err = "TCP 7844 不可达"
+	} else {
+		conn.Close()
+		e.HTTP2 = true
+	}
+	e.Protocol = "http2"
+	if e.QUIC {
+		e.Protocol = "quic"
+	}
+	return e
+}
+
+// ProbeQUIC 检测能否通过 UDP 7844 与边缘建立 QUIC 连接
+// 发送一个使用保留版本号的 QUIC 长包头，边缘按 RFC 9000 应答版本协商包即视为可达
+func ProbeQUIC(region string, timeout time.Duration) error {
+	conn, err := net.DialTimeout("udp", net.JoinHostPort(edgeHost(region), edgePort), timeout)
+	if err != nil {
+		return fmt.Errorf("解析边缘地址失败: %w", err)
+	}
+	defer conn.Close()
+
+	// 长包头 | 保留版本 0x1a2a3a4a | DCID(8) | SCID(8)，填充到 1200 字节（服务端只应答不小于该长度的包）
+	pkt := make([]byte, 1200)
+	pkt[0] = 0xc0
+	copy(pkt[1:5], []byte{0x1a, 0x2a, 0x3a, 0x4a})
+	pkt[5] = 8
+	rand.Read(pkt[6:14])
+	pkt[14] = 8
+	rand.Read(pkt[15:23])
+
+	buf := make([]byte, 1500)
+	deadline := time.Now().Add(timeout)
+	// UDP 可能丢包，期间重发几次
+	for attempt := 0; attempt < 3 && time.Now().Before(deadline); attempt++ {
+		if _, err := conn.Write(pkt); err != nil {
+			return fmt.Errorf("UDP 7844 发送失败: %w", err)
+		}
+		conn.SetReadDeadline(minTime(deadline, time.Now().Add(timeout/3)))
+		// 通常应答版本协商包（版本字段为 0），收到任何应答都说明 UDP 未被阻断
+		if _, err := conn.Read(buf); err == nil {
+			return nil
+		}
+	}
+	return fmt.Errorf("UDP 7844 无响应（可能被防火墙阻断）")
+}
+
+// tunnelProtocol 将配置的协议转换为 cloudflared 的 --protocol 参数，未设置时保持 http2
+// auto 原样交给 cloudflared：每次建立连接时先尝试 QUIC，失败回退 http2，网络环境变化后无需重新注册服务
+func tunnelProtocol(protocol string) string {
+	if protocol == "" {
+		return "http2"
+	}
+	return protocol
+}
+
+func minTime(a, b time.Time) time.Time {
+	if a.Before(b) {
+		return a
+	}
+	return b
+}
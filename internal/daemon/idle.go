@@ -0,0 +1,100 @@
+package daemon
+
+import (
+	"io"
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// activity 记录最近一次有流量的时间，多个端口共用，全部空闲才算空闲
+type activity struct {
+	last atomic.Int64 // UnixNano
+}
+
+func newActivity() *activity {
+	a := &activity{}
+	a.touch()
+	return a
+}
+
+func (a *activity) touch() { a.last.Store(time.Now().UnixNano()) }
+
+// idle 返回距最近一次流量的时长
+func (a *activity) idle() time.Duration {
+	return time.Since(time.Unix(0, a.last.Load()))
+}
+
+// idleProxy 在本地端口前做 TCP 转发并记录流量时间，供 --idle-timeout 判断隧道是否空闲
+// cloudflared 与源站之间会复用长连接，因此按收发数据而非连接数判断
+type idleProxy struct {
+	ln     net.Listener
+	target string
+	act    *activity
+}
+
+// startIdleProxy 在 127.0.0.1 随机端口启动指向 target（host:port）的转发
+func startIdleProxy(target string, act *activity) (*idleProxy, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+	p := &idleProxy{ln: ln, target: target, act: act}
+	go p.serve()
+	return p, nil
+}
+
+// ListenPort 返回转发实际监听的端口
+func (p *idleProxy) ListenPort() int {
+	return p.ln.Addr().(*net.TCPAddr).Port
+}
+
+// Stop 停止接受新连接，已有连接随 cloudflared 退出而关闭
+func (p *idleProxy) Stop() {
+	p.ln.Close()
+}
+
+func (p *idleProxy) serve() {
+	for {
+		conn, err := p.ln.Accept()
+		if err != nil {
+			return
+		}
+		go p.handle(conn)
+	}
+}
+
+func (p *idleProxy) handle(conn net.Conn) {
+	defer conn.Close()
+	upstream, err := net.Dial("tcp", p.target)
+	if err != nil {
+		return
+	}
+	defer upstream.Close()
+	p.act.touch()
+
+	done := make(chan struct{}, 2)
+	pipe := func(dst, src net.Conn) {
+		io.Copy(activityWriter{dst, p.act}, src)
+		// 半关闭写方向，让对端读到 EOF，另一个方向的响应仍可继续传完
+		if tc, ok := dst.(*net.TCPConn); ok {
+			tc.CloseWrite()
+		}
+		done <- struct{}{}
+	}
+	go pipe(upstream, conn)
+	go pipe(conn, upstream)
+	<-done
+	<-done
+}
+
+// activityWriter 每次写入时刷新流量时间
+type activityWriter struct {
+	w   io.Writer
+	act *activity
+}
+
+func (w activityWriter) Write(p []byte) (int, error) {
+	w.act.touch()
+	return w.w.Write(p)
+}
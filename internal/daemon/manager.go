@@ -16,9 +16,9 @@ func pidFilePath() string {
 	return filepath.Join(config.Dir(), "cloudflared.pid")
 }
 
-// Start 启动 cloudflared（token 模式）
-func Start(token string) error {
-	binPath, err := EnsureCloudflared()
+// Start 以 TunnelArgs 生成的参数启动 cloudflared（token 模式），令牌经环境变量传入
+func Start(args []string, token string) error {
+	binPath, err := EnsureCloudflared(os.Stdout)
 	if err != nil {
 		return err
 	}
@@ -26,7 +26,8 @@ func Start(token string) error {
 		return fmt.Errorf("cloudflared 已在运行")
 	}
 
-	cmd := exec.Command(binPath, "tunnel", "--protocol", "http2", "run", "--token", token)
+	cmd := exec.Command(binPath, withMetricsAddr(args)...)
+	cmd.Env = TunnelEnv(token)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	if err := cmd.Start(); err != nil {
@@ -39,6 +40,53 @@ func Start(token string) error {
 	return nil
 }
 
+// TokenEnv cloudflared 读取隧道令牌的环境变量
+// 令牌不出现在命令行参数中，避免被其他本地用户通过 ps 看到
+const TokenEnv = "TUNNEL_TOKEN"
+
+// TunnelEnv 返回带隧道令牌的子进程环境变量
+func TunnelEnv(token string) []string {
+	return append(os.Environ(), TokenEnv+"="+token)
+}
+
+// TunnelArgs 按 config.yml 中的 cloudflared 选项生成运行参数（token 模式，令牌经 TokenEnv 传入），
+// 守护进程与系统服务共用；指标固定监听本机端口供 /metrics 转发
+func TunnelArgs(opts config.CloudflaredConfig) []string {
+	args := []string{"tunnel", "--protocol", tunnelProtocol(opts.Protocol)}
+	if opts.EdgeIPVersion != "" {
+		args = append(args, "--edge-ip-version", opts.EdgeIPVersion)
+	}
+	if opts.Region != "" {
+		args = append(args, "--region", opts.Region)
+	}
+	if opts.LogLevel != "" {
+		args = append(args, "--loglevel", opts.LogLevel)
+	}
+	if opts.Retries > 0 {
+		args = append(args, "--retries", strconv.Itoa(opts.Retries))
+	}
+	if opts.GracePeriod != "" {
+		args = append(args, "--grace-period", opts.GracePeriod)
+	}
+	if opts.PostQuantum {
+		args = append(args, "--post-quantum")
+	}
+	if opts.Metrics != "" {
+		args = append(args, "--metrics", opts.Metrics)
+	}
+	args = append(args, opts.Args...)
+	return append(args, "run")
+}
+
+// TunnelWarnings 返回 cloudflared 选项中可能导致启动失败的组合，由调用方决定如何提示
+func TunnelWarnings(opts config.CloudflaredConfig) []string {
+	var warnings []string
+	if protocol := tunnelProtocol(opts.Protocol); opts.PostQuantum && protocol == "http2" {
+		warnings = append(warnings, "post_quantum 需要 QUIC，当前协议为 "+protocol+"，cloudflared 可能拒绝启动")
+	}
+	return warnings
+}
+
 // Stop 停止 cloudflared
 func Stop() error {
 	pid, err := readPID()
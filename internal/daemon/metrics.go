@@ -0,0 +1,83 @@
+package daemon
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+)
+
+// cloudflared 默认使用的指标端口范围
+const (
+	metricsPortStart = 20241
+	metricsPortEnd   = 20245
+)
+
+// metricsAddrPath 记录 cloudflared 指标监听地址，供 /metrics 转发
+func metricsAddrPath() string {
+	return filepath.Join(config.Dir(), "cloudflared.metrics")
+}
+
+// metricsDialTimeout 探测指标地址是否在监听的超时
+const metricsDialTimeout = 500 * time.Millisecond
+
+// withMetricsAddr 在启动 cloudflared 前确定指标地址并记录：参数中已指定 --metrics 时记录该地址，
+// 否则在默认范围内选择空闲端口加入参数，均被占用时不指定（由 cloudflared 自行选择）
+// 在每次实际启动时调用，避免提前选定的端口到启动时已被占用
+func withMetricsAddr(args []string) []string {
+	for i, a := range args {
+		if a == "--metrics" && i+1 < len(args) {
+			recordMetricsAddr(args[i+1])
+			return args
+		}
+	}
+	for port := metricsPortStart; port <= metricsPortEnd; port++ {
+		addr := "127.0.0.1:" + strconv.Itoa(port)
+		ln, err := net.Listen("tcp", addr)
+		if err != nil {
+			continue
+		}
+		ln.Close()
+		recordMetricsAddr(addr)
+		// 全局参数须位于 run 子命令之前
+		if n := len(args); n > 0 && args[n-1] == "run" {
+			return append(slices.Clone(args[:n-1]), "--metrics", addr, "run")
+		}
+		return append(slices.Clone(args), "--metrics", addr)
+	}
+	os.Remove(metricsAddrPath())
+	return args
+}
+
+func recordMetricsAddr(addr string) {
+	os.MkdirAll(config.Dir(), 0700)
+	os.WriteFile(metricsAddrPath(), []byte(addr), 0600)
+}
+
+// MetricsAddr 返回正在监听的 cloudflared 指标地址，均无响应时返回空
+// 依次尝试 config.yml 指定的地址、cftunnel 启动时记录的地址和 cloudflared 的默认端口范围，
+// 系统服务直接启动的 cloudflared 没有 PID 文件和记录，只能按默认范围探测
+func MetricsAddr() string {
+	var candidates []string
+	if cfg, err := config.Load(); err == nil && cfg.Cloudflared.Metrics != "" {
+		candidates = append(candidates, cfg.Cloudflared.Metrics)
+	}
+	if data, err := os.ReadFile(metricsAddrPath()); err == nil {
+		candidates = append(candidates, strings.TrimSpace(string(data)))
+	}
+	for port := metricsPortStart; port <= metricsPortEnd; port++ {
+		candidates = append(candidates, "127.0.0.1:"+strconv.Itoa(port))
+	}
+	for _, addr := range candidates {
+		if conn, err := net.DialTimeout("tcp", addr, metricsDialTimeout); err == nil {
+			conn.Close()
+			return addr
+		}
+	}
+	return ""
+}
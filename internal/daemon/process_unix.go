@@ -6,6 +6,7 @@ import (
 	"os"
 	"os/exec"
 	"strconv"
+	"syscall"
 )
 
 // processRunning 检查进程是否存活（Unix: kill -0）
@@ -21,3 +22,8 @@ func processKill(pid int) error {
 	}
 	return proc.Signal(os.Interrupt)
 }
+
+// detachAttr 后台进程脱离当前终端会话（Unix: setsid）
+func detachAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
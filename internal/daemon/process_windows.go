@@ -6,6 +6,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"syscall"
 )
 
 // processRunning 检查进程是否存活（Windows: tasklist）
@@ -21,3 +22,9 @@ func processRunning(pid int) bool {
 func processKill(pid int) error {
 	return exec.Command("taskkill", "/PID", strconv.Itoa(pid)).Run()
 }
+
+// detachAttr 后台进程不继承当前控制台（Windows: DETACHED_PROCESS）
+func detachAttr() *syscall.SysProcAttr {
+	const detachedProcess = 0x00000008
+	return &syscall.SysProcAttr{CreationFlags: detachedProcess | syscall.CREATE_NEW_PROCESS_GROUP}
+}
@@ -1,18 +1,26 @@
 package daemon
 
 import (
-	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
 	"io"
+	"net"
 	"os"
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"runtime"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/qingchencloud/cftunnel/internal/authproxy"
 	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+	"github.com/qingchencloud/cftunnel/internal/qrcode"
+	"github.com/qingchencloud/cftunnel/internal/supervisor"
 )
 
 // quickConfigPath 返回 quick 模式专用的空配置文件路径
@@ -26,137 +34,464 @@ func quickConfigPath() string {
 	return p
 }
 
-// StartQuick 启动免域名模式（前台运行，Ctrl+C 退出）
-func StartQuick(port string) error {
-	binPath, err := EnsureCloudflared()
+// quickTunnel 一个本地端口对应的免域名隧道
+type quickTunnel struct {
+	port   string // 本地端口
+	target string // cloudflared --url 指向的端口，开启鉴权时为代理端口
+	url    string // 已分配的 trycloudflare 地址
+}
+
+// QuickOptions quick 模式选项
+type QuickOptions struct {
+	Ports    []string
+	Username string // 非空时每个端口前置独立的鉴权代理
+	Password string
+
+	JSON    bool   // 标准输出只输出 JSON Lines 事件（QuickEvent），其余提示改写到 stderr
+	URLFile string // 地址就绪后写入该文件（每行一个，顺序同 Ports），退出时删除
+	OnReady string // 全部地址就绪及重启换址后执行的命令，地址通过 CFTUNNEL_URL / CFTUNNEL_URLS 环境变量传入
+	QR      bool   // 地址就绪后在终端打印二维码
+
+	TTL         time.Duration // 大于 0 时到期自动关闭全部隧道
+	IdleTimeout time.Duration // 大于 0 时全部端口持续无流量超过该时长后自动关闭
+}
+
+// QuickEvent quick --json 输出的事件，每行一个 JSON 对象
+type QuickEvent struct {
+	Event    string    `json:"event"` // starting / url_ready / connection_registered / error / exiting
+	Time     time.Time `json:"time"`
+	Port     string    `json:"port,omitempty"`
+	URL      string    `json:"url,omitempty"`
+	PID      int       `json:"pid,omitempty"`      // starting：cloudflared 进程 PID
+	Location string    `json:"location,omitempty"` // connection_registered：边缘节点，如 sjc01
+	Protocol string    `json:"protocol,omitempty"` // connection_registered：quic / http2
+	Message  string    `json:"message,omitempty"`  // error 的错误信息、exiting 的退出原因（interrupted / ttl / idle_timeout / error）
+
+	ExpiresAt time.Time `json:"expires_at,omitzero"` // url_ready：--ttl 到期时间
+}
+
+// StartQuick 为每个端口各启动一个免域名隧道（前台运行），由当前进程统一守护，
+// Ctrl+C、到达 --ttl 或空闲超过 --idle-timeout 时全部退出
+func StartQuick(opts QuickOptions) error {
+	board := &quickBoard{opts: opts, out: os.Stdout}
+	if opts.TTL > 0 {
+		board.deadline = time.Now().Add(opts.TTL)
+	}
+	if opts.JSON {
+		// 标准输出留给事件流，下载进度、鉴权代理等提示一律写到 stderr
+		board.events = json.NewEncoder(os.Stdout)
+		board.out = os.Stderr
+	}
+
+	binPath, err := EnsureCloudflared(board.out)
 	if err != nil {
-		return err
+		return board.fail(err)
 	}
-	if Running() {
-		return fmt.Errorf("cloudflared 已在运行，请先执行 cftunnel down")
+
+	board.tunnels = make([]*quickTunnel, len(opts.Ports))
+	for i, port := range opts.Ports {
+		t := &quickTunnel{port: port, target: port}
+		if opts.IdleTimeout > 0 {
+			// 空闲检测转发紧贴本地端口，开启鉴权时只有通过鉴权的流量才算活动
+			if board.act == nil {
+				board.act = newActivity()
+			}
+			proxy, err := startIdleProxy(net.JoinHostPort("localhost", port), board.act)
+			if err != nil {
+				return board.fail(fmt.Errorf("启动空闲检测转发失败: %w", err))
+			}
+			defer proxy.Stop()
+			t.target = strconv.Itoa(proxy.ListenPort())
+		}
+		if opts.Username != "" {
+			proxy, err := startQuickProxy(t.target, opts.Username, opts.Password)
+			if err != nil {
+				return board.fail(err)
+			}
+			defer proxy.Stop()
+			t.target = strconv.Itoa(proxy.ListenPort())
+			fmt.Fprintf(board.out, "鉴权代理已启动 127.0.0.1:%s → 127.0.0.1:%s\n", t.target, port)
+		}
+		board.tunnels[i] = t
 	}
 
 	// 显式指定空配置文件，防止 cloudflared 读取用户已有的 ~/.cloudflared/config.yml
 	// 避免残留的 tunnel: 字段触发 UUID 解析失败 (issue #13)
 	cfgPath := quickConfigPath()
-	cmd := exec.Command(binPath, "tunnel", "--config", cfgPath, "--url", "http://localhost:"+port)
 
-	// 捕获 stderr 提取随机域名
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
-		return err
+	// 捕获 Ctrl+C，所有隧道一起优雅退出；到期或空闲超时同样经由 stop 关闭
+	stop := make(chan struct{})
+	var reason string
+	var once sync.Once
+	shutdown := func(r string) {
+		once.Do(func() {
+			reason = r
+			close(stop)
+		})
+	}
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	defer signal.Stop(sig)
+	go func() {
+		select {
+		case <-sig:
+			shutdown("interrupted")
+		case <-stop:
+		}
+	}()
+	if opts.TTL > 0 || opts.IdleTimeout > 0 {
+		go board.watchExpiry(stop, shutdown)
 	}
-	cmd.Stdout = os.Stdout
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("启动 cloudflared 失败: %w", err)
+	var wg sync.WaitGroup
+	for _, t := range board.tunnels {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			// 子进程异常退出时按退避重启，新地址会重新打印
+			supervisor.Run(supervisor.Options{
+				Name: "cloudflared:" + t.port,
+				Command: func() *exec.Cmd {
+					cmd := exec.Command(binPath, "tunnel", "--config", cfgPath, "--url", "http://localhost:"+t.target)
+					cmd.Stdout = board.out
+					cmd.Stderr = &lineWriter{emit: func(line string) { board.line(t, line) }}
+					return cmd
+				},
+				Interrupt: stopChildProcess,
+				OnStart:   func(pid int) { board.started(t, pid) },
+			}, stop)
+		}()
+	}
+	wg.Wait()
+	board.exit(reason)
+	return nil
+}
+
+// startQuickProxy 在随机端口启动指向本地端口的鉴权代理
+func startQuickProxy(port, username, password string) (*authproxy.Proxy, error) {
+	proxy, err := authproxy.New(authproxy.Config{
+		Username:   username,
+		Password:   password,
+		Target:     "http://127.0.0.1:" + port,
+		SigningKey: authproxy.RandomKey(),
+		CookieTTL:  24 * time.Hour,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("启动鉴权代理失败: %w", err)
+	}
+	if err := proxy.Start(); err != nil {
+		return nil, fmt.Errorf("启动鉴权代理失败: %w", err)
 	}
+	return proxy, nil
+}
 
-	// 后台读取 stderr，提取域名并转发输出
-	go scanForURL(stderr)
+// quickBoard 汇总各隧道的输出和地址，多个端口全部就绪后打印端口与地址对照表，并触发 --json 事件和就绪钩子
+type quickBoard struct {
+	mu      sync.Mutex
+	opts    QuickOptions
+	tunnels []*quickTunnel
+	printed bool
 
-	// 捕获 Ctrl+C 优雅退出
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
+	deadline time.Time // --ttl 到期时间，未设置时为零值
+	act      *activity // --idle-timeout 的流量记录，未设置时为 nil
 
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
+	out    io.Writer     // 人类可读提示，--json 时为 stderr
+	events *json.Encoder // --json 时的事件输出，否则为 nil
+}
+
+// emit 输出一条事件（调用方需持有 mu）
+func (b *quickBoard) emit(ev QuickEvent) {
+	if b.events == nil {
+		return
+	}
+	ev.Time = time.Now()
+	b.events.Encode(ev)
+}
 
-	select {
-	case <-sig:
-		stopChildProcess(cmd)
-		<-done
-	case err := <-done:
-		if err != nil {
-			return fmt.Errorf("cloudflared 异常退出: %w", err)
+func (b *quickBoard) started(t *quickTunnel, pid int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit(QuickEvent{Event: "starting", Port: t.port, PID: pid})
+}
+
+// line 处理 cloudflared 的一行输出：提取地址和连接事件并转发到 stderr（多个端口时加端口前缀）
+func (b *quickBoard) line(t *quickTunnel, line string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	// cloudflared 输出格式: ... https://xxx.trycloudflare.com ...
+	if url := extractURL(line); url != "" && url != t.url {
+		b.setURL(t, url)
+	}
+	if e, ok := logfile.ParseLine(line); ok {
+		switch {
+		case strings.Contains(e.Message, "Registered tunnel connection"):
+			// Registered tunnel connection connIndex=0 connection=... event=0 ip=... location=sjc01 protocol=quic
+			fields := logFields(e.Message)
+			b.emit(QuickEvent{Event: "connection_registered", Port: t.port, Location: fields["location"], Protocol: fields["protocol"]})
+		case e.Level >= logfile.LevelError:
+			b.emit(QuickEvent{Event: "error", Port: t.port, Message: e.Message})
 		}
 	}
-	return nil
+	if len(b.tunnels) > 1 {
+		line = "[" + t.port + "] " + line
+	}
+	fmt.Fprintln(os.Stderr, line)
 }
 
-func scanForURL(r io.Reader) {
-	scanner := bufio.NewScanner(r)
-	for scanner.Scan() {
-		line := scanner.Text()
-		// cloudflared 输出格式: ... https://xxx.trycloudflare.com ...
-		if strings.Contains(line, "trycloudflare.com") {
-			url := extractURL(line)
-			if url != "" {
-				fmt.Printf("\n✔ 隧道已启动: %s\n\n", url)
+func (b *quickBoard) setURL(t *quickTunnel, url string) {
+	restarted := t.url != ""
+	t.url = url
+	b.emit(QuickEvent{Event: "url_ready", Port: t.port, URL: url, ExpiresAt: b.deadline})
+	switch {
+	case len(b.tunnels) == 1:
+		fmt.Fprintf(b.out, "\n✔ 隧道已启动: %s\n\n", url)
+	case restarted || b.printed:
+		fmt.Fprintf(b.out, "\n✔ 端口 %s 的隧道已重启，新地址: %s\n\n", t.port, url)
+	default:
+		for _, t := range b.tunnels {
+			if t.url == "" {
+				return
 			}
 		}
-		fmt.Fprintln(os.Stderr, line)
+		b.printed = true
+		fmt.Fprintf(b.out, "\n✔ %d 个隧道已启动:\n", len(b.tunnels))
+		// 表头为全角字符，按显示宽度手动对齐
+		fmt.Fprintln(b.out, "  本地端口  公网地址")
+		for _, t := range b.tunnels {
+			fmt.Fprintf(b.out, "  %-8s  %s\n", t.port, t.url)
+		}
+		fmt.Fprintln(b.out)
+		b.printExpiry()
+		for _, t := range b.tunnels {
+			b.printQR(t)
+		}
+		b.ready()
+		return
+	}
+	if !restarted {
+		b.printExpiry()
+	}
+	b.printQR(t)
+	if len(b.tunnels) == 1 || b.printed {
+		b.ready()
 	}
 }
 
-func extractURL(line string) string {
-	for _, part := range strings.Fields(line) {
-		if strings.Contains(part, "trycloudflare.com") && strings.HasPrefix(part, "http") {
-			return part
+// printExpiry 地址首次就绪时说明自动关闭条件
+func (b *quickBoard) printExpiry() {
+	if !b.deadline.IsZero() {
+		fmt.Fprintf(b.out, "⏱ 有效期至 %s（%s 后自动关闭）\n", b.deadline.Format("01-02 15:04:05"), b.opts.TTL)
+	}
+	if b.opts.IdleTimeout > 0 {
+		fmt.Fprintf(b.out, "⏱ 连续 %s 无访问流量时自动关闭\n", b.opts.IdleTimeout)
+	}
+	if !b.deadline.IsZero() || b.opts.IdleTimeout > 0 {
+		fmt.Fprintln(b.out)
+	}
+}
+
+// watchExpiry 到达 --ttl 或空闲超过 --idle-timeout 时关闭全部隧道，期间按剩余时间逐渐加密倒计时提示
+func (b *quickBoard) watchExpiry(stop <-chan struct{}, shutdown func(reason string)) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	next := time.Now().Add(countdownInterval(b.remaining()))
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		now := time.Now()
+		switch {
+		case !b.deadline.IsZero() && !now.Before(b.deadline):
+			b.notice(fmt.Sprintf("⏱ 已到达有效期 %s，正在关闭隧道", b.opts.TTL))
+			shutdown("ttl")
+			return
+		case b.act != nil && b.act.idle() >= b.opts.IdleTimeout:
+			b.notice(fmt.Sprintf("⏱ 已连续 %s 无访问流量，正在关闭隧道", b.opts.IdleTimeout))
+			shutdown("idle_timeout")
+			return
+		case now.After(next):
+			b.notice(b.countdown())
+			next = now.Add(countdownInterval(b.remaining()))
 		}
 	}
-	return ""
 }
 
-// StartQuickWithAuth 启动带鉴权代理的免域名模式
-func StartQuickWithAuth(port, username, password string) error {
-	binPath, err := EnsureCloudflared()
-	if err != nil {
-		return err
+// remaining 返回距自动关闭的最短剩余时间
+func (b *quickBoard) remaining() time.Duration {
+	left := time.Duration(-1)
+	if !b.deadline.IsZero() {
+		left = time.Until(b.deadline)
 	}
-	if Running() {
-		return fmt.Errorf("cloudflared 已在运行，请先执行 cftunnel down")
+	if b.act != nil {
+		if idle := b.opts.IdleTimeout - b.act.idle(); left < 0 || idle < left {
+			left = idle
+		}
 	}
+	return left
+}
 
-	// 启动鉴权代理
-	proxy, err := authproxy.New(authproxy.Config{
-		Username:   username,
-		Password:   password,
-		TargetPort: port,
-		SigningKey:  authproxy.RandomKey(),
-		CookieTTL:  24 * time.Hour,
-	})
-	if err != nil {
-		return fmt.Errorf("启动鉴权代理失败: %w", err)
+// countdown 倒计时提示，如 "⏱ 剩余 1h30m0s，已空闲 2m0s（30m0s 无流量自动关闭）"
+func (b *quickBoard) countdown() string {
+	var parts []string
+	if !b.deadline.IsZero() {
+		parts = append(parts, "剩余 "+time.Until(b.deadline).Round(time.Second).String())
 	}
-	if err := proxy.Start(); err != nil {
-		return fmt.Errorf("启动鉴权代理失败: %w", err)
+	if b.act != nil {
+		parts = append(parts, fmt.Sprintf("已空闲 %s（%s 无流量自动关闭）", b.act.idle().Round(time.Second), b.opts.IdleTimeout))
 	}
-	defer proxy.Stop()
+	return "⏱ " + strings.Join(parts, "，")
+}
 
-	proxyPort := fmt.Sprintf("%d", proxy.ListenPort())
-	fmt.Printf("鉴权代理已启动 127.0.0.1:%s → 127.0.0.1:%s\n", proxyPort, port)
+// countdownInterval 剩余时间越短提示越频繁
+func countdownInterval(left time.Duration) time.Duration {
+	switch {
+	case left > time.Hour:
+		return 30 * time.Minute
+	case left > 10*time.Minute:
+		return 5 * time.Minute
+	case left > time.Minute:
+		return time.Minute
+	default:
+		return 10 * time.Second
+	}
+}
 
-	// cloudflared 指向代理端口（同样隔离配置文件）
-	cfgPath := quickConfigPath()
-	cmd := exec.Command(binPath, "tunnel", "--config", cfgPath, "--url", "http://localhost:"+proxyPort)
+// notice 输出一行提示
+func (b *quickBoard) notice(msg string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	fmt.Fprintln(b.out, msg)
+}
 
-	stderr, err := cmd.StderrPipe()
+// printQR 打印地址二维码（--qr），多个端口时附带端口标题
+func (b *quickBoard) printQR(t *quickTunnel) {
+	if !b.opts.QR {
+		return
+	}
+	code, err := qrcode.New(t.url)
 	if err != nil {
+		fmt.Fprintf(b.out, "生成二维码失败: %v\n", err)
+		return
+	}
+	if len(b.tunnels) > 1 {
+		fmt.Fprintf(b.out, "端口 %s:\n", t.port)
+	}
+	fmt.Fprintln(b.out, code.Terminal())
+}
+
+// ready 全部地址就绪（或重启换址）后写入 --url-file 并执行 --on-ready 命令
+func (b *quickBoard) ready() {
+	urls := make([]string, len(b.tunnels))
+	ports := make([]string, len(b.tunnels))
+	for i, t := range b.tunnels {
+		urls[i], ports[i] = t.url, t.port
+	}
+	if b.opts.URLFile != "" {
+		if err := writeURLFile(b.opts.URLFile, urls); err != nil {
+			b.report(fmt.Errorf("写入地址文件失败: %w", err))
+		}
+	}
+	if b.opts.OnReady != "" {
+		// 钩子可能耗时较长，不阻塞 cloudflared 输出
+		go func() {
+			if err := runReadyHook(b.opts.OnReady, urls, ports, b.out); err != nil {
+				b.mu.Lock()
+				defer b.mu.Unlock()
+				b.report(fmt.Errorf("执行 --on-ready 命令失败: %w", err))
+			}
+		}()
+	}
+}
+
+// report 输出非致命错误（调用方需持有 mu）
+func (b *quickBoard) report(err error) {
+	fmt.Fprintln(os.Stderr, "✗", err)
+	b.emit(QuickEvent{Event: "error", Message: err.Error()})
+}
+
+// fail 启动失败时输出 error 和 exiting 事件，返回原错误
+func (b *quickBoard) fail(err error) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.emit(QuickEvent{Event: "error", Message: err.Error()})
+	b.emit(QuickEvent{Event: "exiting", Message: "error"})
+	return err
+}
+
+// exit 全部隧道退出后清理地址文件并输出 exiting 事件
+func (b *quickBoard) exit(reason string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.opts.URLFile != "" {
+		os.Remove(b.opts.URLFile)
+	}
+	b.emit(QuickEvent{Event: "exiting", Message: reason})
+}
+
+// writeURLFile 原子写入地址文件，读取方不会读到写了一半的内容
+func writeURLFile(path string, urls []string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(strings.Join(urls, "\n")+"\n"), 0644); err != nil {
+		os.Remove(tmp)
 		return err
 	}
-	cmd.Stdout = os.Stdout
+	return os.Rename(tmp, path)
+}
 
-	if err := cmd.Start(); err != nil {
-		return fmt.Errorf("启动 cloudflared 失败: %w", err)
+// runReadyHook 通过系统 shell 执行就绪命令，输出转发到 out
+func runReadyHook(command string, urls, ports []string, out io.Writer) error {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
 	}
+	cmd.Env = append(os.Environ(),
+		"CFTUNNEL_URL="+urls[0],
+		"CFTUNNEL_URLS="+strings.Join(urls, " "),
+		"CFTUNNEL_PORTS="+strings.Join(ports, " "),
+	)
+	cmd.Stdout, cmd.Stderr = out, os.Stderr
+	return cmd.Run()
+}
 
-	go scanForURL(stderr)
+// logFields 解析 cloudflared 日志中的 key=value 字段
+func logFields(msg string) map[string]string {
+	fields := map[string]string{}
+	for _, part := range strings.Fields(msg) {
+		if k, v, ok := strings.Cut(part, "="); ok {
+			fields[k] = v
+		}
+	}
+	return fields
+}
 
-	sig := make(chan os.Signal, 1)
-	signal.Notify(sig, os.Interrupt)
+func extractURL(line string) string {
+	for _, part := range strings.Fields(line) {
+		if strings.Contains(part, "trycloudflare.com") && strings.HasPrefix(part, "http") {
+			return part
+		}
+	}
+	return ""
+}
 
-	done := make(chan error, 1)
-	go func() { done <- cmd.Wait() }()
+// lineWriter 将写入的内容按行回调（用作子进程的 stderr，exec 负责拷贝并在退出时等待写完）
+type lineWriter struct {
+	buf  []byte
+	emit func(line string)
+}
 
-	select {
-	case <-sig:
-		stopChildProcess(cmd)
-		<-done
-	case err := <-done:
-		if err != nil {
-			return fmt.Errorf("cloudflared 异常退出: %w", err)
+func (w *lineWriter) Write(p []byte) (int, error) {
+	w.buf = append(w.buf, p...)
+	for {
+		i := bytes.IndexByte(w.buf, '\n')
+		if i < 0 {
+			break
 		}
+		w.emit(strings.TrimSuffix(string(w.buf[:i]), "\r"))
+		w.buf = w.buf[i+1:]
 	}
-	return nil
+	return len(p), nil
 }
@@ -0,0 +1,146 @@
+package daemon
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/supervisor"
+)
+
+// supervisorStatePath 守护状态文件（重启次数、最近退出原因）
+func supervisorStatePath() string {
+	return filepath.Join(config.Dir(), "supervisor.json")
+}
+
+// stopRequestPath down 命令写入的停止请求文件（跨平台，无需向后台进程发信号）
+func stopRequestPath() string {
+	return filepath.Join(config.Dir(), "supervisor.stop")
+}
+
+// Supervise 前台守护 cloudflared：崩溃后按指数退避自动重启，直到 stop 关闭或收到 down 请求
+// args 为 TunnelArgs 生成的运行参数，令牌经环境变量传入；cloudflared 的标准输出和标准错误写入 out（通常为轮转日志文件）
+func Supervise(args []string, token string, out io.Writer, stop <-chan struct{}) error {
+	binPath, err := EnsureCloudflared(os.Stdout)
+	if err != nil {
+		return err
+	}
+	if st := SupervisorState(); st != nil {
+		return fmt.Errorf("守护进程已在运行 (PID: %d)", st.PID)
+	}
+	if Running() {
+		return fmt.Errorf("cloudflared 已在运行")
+	}
+	os.MkdirAll(config.Dir(), 0700)
+	os.Remove(stopRequestPath())
+
+	quit := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				close(quit)
+				return
+			case <-ticker.C:
+				if _, err := os.Stat(stopRequestPath()); err == nil {
+					os.Remove(stopRequestPath())
+					close(quit)
+					return
+				}
+			}
+		}
+	}()
+
+	return supervisor.Run(supervisor.Options{
+		Name: "cloudflared",
+		Command: func() *exec.Cmd {
+			cmd := exec.Command(binPath, withMetricsAddr(args)...)
+			cmd.Env = TunnelEnv(token)
+			cmd.Stdout = out
+			cmd.Stderr = out
+			return cmd
+		},
+		Interrupt: stopChildProcess,
+		StatePath: supervisorStatePath(),
+		OnStart: func(pid int) {
+			os.WriteFile(pidFilePath(), []byte(strconv.Itoa(pid)), 0600)
+		},
+		OnExit: func() {
+			os.Remove(pidFilePath())
+		},
+	}, quit)
+}
+
+// SupervisorState 返回运行中守护进程的状态，未运行时返回 nil
+func SupervisorState() *supervisor.State {
+	st, err := supervisor.LoadState(supervisorStatePath())
+	if err != nil || !processRunning(st.PID) {
+		return nil
+	}
+	return st
+}
+
+// StopSupervisor 请求守护进程停止 cloudflared 并退出，超时后强制结束
+func StopSupervisor() error {
+	st := SupervisorState()
+	if st == nil {
+		return fmt.Errorf("未找到运行中的守护进程")
+	}
+	if err := os.WriteFile(stopRequestPath(), nil, 0600); err != nil {
+		return err
+	}
+	for i := 0; i < 30; i++ {
+		if !processRunning(st.PID) {
+			fmt.Println("cloudflared 及守护进程已停止")
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	os.Remove(stopRequestPath())
+	if err := processKill(st.PID); err != nil {
+		return fmt.Errorf("停止守护进程失败: %w", err)
+	}
+	fmt.Println("守护进程未响应，已强制停止")
+	return nil
+}
+
+// Detach 在后台重新执行当前程序，标准输出写入 logPath，返回后台进程 PID
+func Detach(args []string, logPath string) (int, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return 0, err
+	}
+	os.MkdirAll(filepath.Dir(logPath), 0755)
+	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return 0, fmt.Errorf("打开日志文件失败: %w", err)
+	}
+	defer logFile.Close()
+
+	cmd := exec.Command(self, args...)
+	cmd.Stdout = logFile
+	cmd.Stderr = logFile
+	cmd.SysProcAttr = detachAttr()
+	if err := cmd.Start(); err != nil {
+		return 0, fmt.Errorf("启动后台进程失败: %w", err)
+	}
+	// 调用方为常驻进程（管理 API）时需回收子进程，避免僵尸进程被误判为仍在运行
+	go cmd.Wait()
+	return cmd.Process.Pid, nil
+}
+
+// RestartChild 结束守护进程下的 cloudflared，由守护进程重新拉起（如升级二进制后）
+func RestartChild() error {
+	st := SupervisorState()
+	if st == nil || st.ChildPID == 0 {
+		return fmt.Errorf("未找到守护进程下运行的 cloudflared")
+	}
+	return processKill(st.ChildPID)
+}
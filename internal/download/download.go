@@ -0,0 +1,231 @@
+// Package download cloudflared / frp / cftunnel 共用的发布文件下载器：
+// 可配置的 GitHub 镜像与代理、断点续传、进度显示，下载完成后校验 SHA-256
+package download
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/config"
+)
+
+// DefaultMirrors 内置 GitHub 镜像源（按优先级排序，"direct" 为原始地址兜底）
+var DefaultMirrors = []string{
+	"https://ghfast.top/",
+	"https://gh-proxy.com/",
+	"https://ghproxy.cn/",
+	Direct,
+}
+
+// Direct 镜像列表中表示直连 GitHub 的特殊值
+const Direct = "direct"
+
+// maxPasses 每轮依次尝试所有镜像，本轮有新进度时再续传一轮
+const maxPasses = 3
+
+// settings 读取 config.yml 中的下载设置
+func settings() *config.DownloadConfig {
+	if cfg, err := config.Load(); err == nil && cfg.Download != nil {
+		return cfg.Download
+	}
+	return &config.DownloadConfig{}
+}
+
+// Mirrors 返回生效的镜像列表：config.yml 配置优先，否则为内置列表
+func Mirrors() []string {
+	if m := settings().Mirrors; len(m) > 0 {
+		return m
+	}
+	return DefaultMirrors
+}
+
+// Client 返回使用配置代理的 HTTP 客户端（未配置时读取 HTTPS_PROXY / ALL_PROXY 等环境变量）
+// 代理支持 http://、https://、socks5://、socks5h://
+func Client(timeout time.Duration) *http.Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = 30 * time.Second
+	if p := settings().Proxy; p != "" {
+		u, err := url.Parse(p)
+		if err != nil || u.Host == "" {
+			fmt.Fprintf(os.Stderr, "警告: 代理地址无效: %s，已忽略\n", p)
+		} else {
+			tr.Proxy = http.ProxyURL(u)
+		}
+	}
+	return &http.Client{Timeout: timeout, Transport: tr}
+}
+
+// CacheDir 下载缓存目录（未完成的 .part 文件，用于断点续传）
+func CacheDir() string {
+	return filepath.Join(config.Dir(), "cache")
+}
+
+// File 待下载的发布文件
+type File struct {
+	Name   string // 发布文件名，如 cloudflared-linux-amd64
+	URL    string // GitHub 原始地址，镜像地址为 镜像前缀 + URL
+	SHA256 string // 期望的 SHA-256，为空时不校验
+	Key    string // 区分版本的缓存键（如发布 tag），避免不同版本的部分下载混用
+
+	Out io.Writer // 下载提示与进度的输出，为空时为标准输出
+}
+
+func (f File) output() io.Writer {
+	if f.Out == nil {
+		return os.Stdout
+	}
+	return f.Out
+}
+
+func (f File) partPath() string {
+	name := f.Name
+	if f.Key != "" {
+		name = f.Key + "-" + name
+	}
+	return filepath.Join(CacheDir(), name+".part")
+}
+
+// Fetch 依次尝试各镜像下载文件，中断后从已下载部分续传，SHA-256 不符时换下一个源
+// 返回定位到开头的本地文件及其 SHA-256，调用方负责关闭并删除
+func Fetch(f File) (*os.File, string, error) {
+	if err := os.MkdirAll(CacheDir(), 0700); err != nil {
+		return nil, "", err
+	}
+	part := f.partPath()
+	out := f.output()
+	client := Client(0) // 大文件下载不设总超时，仅限制等待响应头的时间
+
+	var lastErr error
+	for pass := 0; pass < maxPasses; pass++ {
+		before := fileSize(part)
+		for _, mirror := range Mirrors() {
+			src, link := "GitHub", f.URL
+			if mirror != Direct && mirror != "" {
+				src = strings.TrimRight(mirror, "/")
+				link = src + "/" + f.URL
+			}
+			fmt.Fprintf(out, "尝试下载: %s ...\n", src)
+
+			if err := fetchPart(out, client, link, part, f.Name); err != nil {
+				fmt.Fprintf(out, "  %v\n", err)
+				lastErr = err
+				continue
+			}
+			file, sum, err := openVerified(part, f)
+			if err != nil {
+				// 镜像内容被篡改或不完整，丢弃后换下一个源
+				os.Remove(part)
+				fmt.Fprintf(out, "  %v\n", err)
+				lastErr = err
+				continue
+			}
+			return file, sum, nil
+		}
+		if fileSize(part) <= before {
+			break
+		}
+		fmt.Fprintln(out, "下载未完成，继续断点续传...")
+	}
+	if fileSize(part) > 0 {
+		return nil, "", fmt.Errorf("所有下载源均失败，最后错误: %w（已下载部分保留在 %s，重新执行将断点续传）", lastErr, CacheDir())
+	}
+	return nil, "", fmt.Errorf("所有下载源均失败，最后错误: %w", lastErr)
+}
+
+// fetchPart 下载到 part 文件，已有部分内容时使用 Range 请求续传，提示与进度写入 w
+func fetchPart(w io.Writer, client *http.Client, link, part, name string) error {
+	offset := fileSize(part)
+	req, err := http.NewRequest("GET", link, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return fmt.Errorf("连接失败: %w", err)
+	}
+	defer resp.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	total := resp.ContentLength
+	switch resp.StatusCode {
+	case http.StatusPartialContent:
+		flags |= os.O_APPEND
+		if total >= 0 {
+			total += offset
+		}
+		fmt.Fprintf(w, "  从 %s 处续传\n", formatSize(offset))
+	case http.StatusOK:
+		flags |= os.O_TRUNC
+		offset = 0
+	case http.StatusRequestedRangeNotSatisfiable:
+		// 部分文件已完整，交给校验判断
+		return nil
+	default:
+		return fmt.Errorf("HTTP %d", resp.StatusCode)
+	}
+
+	out, err := os.OpenFile(part, flags, 0600)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	p := newProgress(w, name, offset, total)
+	_, err = io.Copy(out, io.TeeReader(resp.Body, p))
+	p.finish()
+	if err != nil {
+		return fmt.Errorf("下载中断: %w", err)
+	}
+	if total >= 0 && fileSize(part) < total {
+		return fmt.Errorf("下载中断: %w", io.ErrUnexpectedEOF)
+	}
+	return nil
+}
+
+// openVerified 计算下载完成的文件的 SHA-256 并与期望值比较
+func openVerified(path string, f File) (*os.File, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, "", err
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	sum := hex.EncodeToString(h.Sum(nil))
+	if f.SHA256 != "" && !strings.EqualFold(sum, f.SHA256) {
+		file.Close()
+		return nil, "", fmt.Errorf("%s 校验失败: SHA-256 为 %s，官方发布为 %s", f.Name, sum, f.SHA256)
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		file.Close()
+		return nil, "", err
+	}
+	return file, sum, nil
+}
+
+// Remove 关闭并删除 Fetch 返回的文件
+func Remove(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+func fileSize(path string) int64 {
+	info, err := os.Stat(path)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
@@ -0,0 +1,78 @@
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"time"
+)
+
+// progress 在终端上显示下载进度（非终端输出时只在结束时打印一行）
+type progress struct {
+	w     io.Writer
+	name  string
+	done  int64
+	total int64 // 未知时为 -1
+	start time.Time
+	last  time.Time
+	tty   bool
+	base  int64 // 续传起点，用于计算速度
+}
+
+func newProgress(w io.Writer, name string, offset, total int64) *progress {
+	tty := false
+	if f, ok := w.(*os.File); ok {
+		info, err := f.Stat()
+		tty = err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+	return &progress{
+		w:     w,
+		name:  name,
+		done:  offset,
+		base:  offset,
+		total: total,
+		start: time.Now(),
+		tty:   tty,
+	}
+}
+
+func (p *progress) Write(b []byte) (int, error) {
+	p.done += int64(len(b))
+	if p.tty && time.Since(p.last) >= 200*time.Millisecond {
+		p.last = time.Now()
+		fmt.Fprintf(p.w, "\r  %s", p.line())
+	}
+	return len(b), nil
+}
+
+func (p *progress) finish() {
+	if p.tty {
+		fmt.Fprintf(p.w, "\r  %s\n", p.line())
+		return
+	}
+	fmt.Fprintf(p.w, "  %s\n", p.line())
+}
+
+func (p *progress) line() string {
+	speed := ""
+	if elapsed := time.Since(p.start).Seconds(); elapsed > 0 {
+		speed = formatSize(int64(float64(p.done-p.base)/elapsed)) + "/s"
+	}
+	if p.total > 0 {
+		pct := float64(p.done) * 100 / float64(p.total)
+		return fmt.Sprintf("%s  %s / %s (%.0f%%)  %s   ", p.name, formatSize(p.done), formatSize(p.total), pct, speed)
+	}
+	return fmt.Sprintf("%s  %s  %s   ", p.name, formatSize(p.done), speed)
+}
+
+// formatSize 以 KB / MB 显示字节数
+func formatSize(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
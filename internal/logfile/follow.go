@@ -0,0 +1,105 @@
+package logfile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// followPollInterval 兜底轮询间隔（文件系统不支持事件通知时）
+const followPollInterval = 2 * time.Second
+
+// Follow 从 offset 开始跟踪 path 新增的行，直到 ctx 取消
+// 使用 fsnotify 监听所在目录，文件被轮转（重建）或截断后从新文件开头继续
+func Follow(ctx context.Context, path string, offset int64, emit func(line string)) error {
+	t := &follower{path: path, offset: offset, emit: emit}
+	if fi, err := os.Stat(path); err == nil {
+		t.info = fi
+	}
+
+	var events <-chan fsnotify.Event
+	if w, err := fsnotify.NewWatcher(); err == nil {
+		defer w.Close()
+		if w.Add(filepath.Dir(path)) == nil {
+			events = w.Events
+		}
+	}
+	poll := followPollInterval
+	if events == nil {
+		poll = 500 * time.Millisecond
+	}
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+
+	t.read()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case ev, ok := <-events:
+			if !ok {
+				events = nil
+				continue
+			}
+			if filepath.Clean(ev.Name) == filepath.Clean(path) {
+				t.read()
+			}
+		case <-ticker.C:
+			t.read()
+		}
+	}
+}
+
+// follower 跟踪状态
+type follower struct {
+	path    string
+	offset  int64
+	info    os.FileInfo
+	partial []byte // 尚未写完的行
+	emit    func(line string)
+}
+
+// read 读取新增内容并按行输出
+func (t *follower) read() {
+	f, err := os.Open(t.path)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	fi, err := f.Stat()
+	if err != nil {
+		return
+	}
+	if (t.info != nil && !os.SameFile(t.info, fi)) || fi.Size() < t.offset {
+		// 已轮转或截断
+		t.offset = 0
+		t.partial = nil
+	}
+	t.info = fi
+	if fi.Size() == t.offset {
+		return
+	}
+	if _, err := f.Seek(t.offset, io.SeekStart); err != nil {
+		return
+	}
+	data, err := io.ReadAll(io.LimitReader(f, fi.Size()-t.offset))
+	if err != nil {
+		return
+	}
+	t.offset += int64(len(data))
+	data = append(t.partial, data...)
+	for {
+		i := bytes.IndexByte(data, '\n')
+		if i < 0 {
+			break
+		}
+		t.emit(string(bytes.TrimSuffix(data[:i], []byte{'\r'})))
+		data = data[i+1:]
+	}
+	t.partial = append([]byte(nil), data...)
+}
@@ -0,0 +1,137 @@
+package logfile
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Level 日志级别
+type Level int
+
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+	LevelFatal
+)
+
+var levelNames = []string{"debug", "info", "warn", "error", "fatal"}
+
+func (l Level) String() string {
+	if l < 0 || int(l) >= len(levelNames) {
+		return "info"
+	}
+	return levelNames[l]
+}
+
+// MarshalText 以名称输出（JSON）
+func (l Level) MarshalText() ([]byte, error) {
+	return []byte(l.String()), nil
+}
+
+// ParseLevel 解析级别名称（debug / info / warn / error / fatal）
+func ParseLevel(s string) (Level, error) {
+	switch strings.ToLower(s) {
+	case "debug", "dbg", "trace":
+		return LevelDebug, nil
+	case "info", "inf":
+		return LevelInfo, nil
+	case "warn", "warning", "wrn":
+		return LevelWarn, nil
+	case "error", "err":
+		return LevelError, nil
+	case "fatal", "ftl", "panic":
+		return LevelFatal, nil
+	}
+	return LevelInfo, fmt.Errorf("未知日志级别: %s（可选 debug / info / warn / error / fatal）", s)
+}
+
+// Entry 解析后的日志行
+type Entry struct {
+	Source  string    `json:"source,omitempty"` // 日志来源（cloudflared / relay / auth:路由）
+	Time    time.Time `json:"time,omitzero"`
+	Level   Level     `json:"level"`
+	Message string    `json:"message"`
+	Line    string    `json:"line"` // 原始行
+}
+
+// cloudflared（zerolog 控制台格式）的级别缩写：2024-01-02T03:04:05Z INF 消息
+var cloudflaredLevels = map[string]Level{
+	"DBG": LevelDebug, "INF": LevelInfo, "WRN": LevelWarn, "ERR": LevelError, "FTL": LevelFatal, "PNC": LevelFatal,
+}
+
+// frpc 的级别缩写：2024-01-02 03:04:05.000 [I] [client/service.go:295] 消息
+var frpcLevels = map[string]Level{
+	"[T]": LevelDebug, "[D]": LevelDebug, "[I]": LevelInfo, "[W]": LevelWarn, "[E]": LevelError,
+}
+
+// LineParser 解析单行日志，ok 为 false 表示无时间戳的续行（如堆栈）
+type LineParser func(line string) (Entry, bool)
+
+// Parser 解析日志行；无时间戳的续行沿用上一行的时间和级别
+type Parser struct {
+	Func LineParser // 为空时使用 ParseLine
+	last Entry
+}
+
+// Parse 解析一行日志
+func (p *Parser) Parse(line string) Entry {
+	parse := p.Func
+	if parse == nil {
+		parse = ParseLine
+	}
+	e, ok := parse(line)
+	if !ok {
+		return continuation(p.last, line)
+	}
+	p.last = e
+	return e
+}
+
+// continuation 续行沿用所属记录的时间和级别
+func continuation(head Entry, line string) Entry {
+	return Entry{Time: head.Time, Level: head.Level, Message: line, Line: line}
+}
+
+// ParseLine 解析 cloudflared / frpc / cftunnel 自身的日志行
+func ParseLine(line string) (Entry, bool) {
+	fields := strings.Fields(line)
+	if len(fields) < 2 {
+		return Entry{}, false
+	}
+	e := Entry{Level: LevelInfo, Line: line}
+
+	// cloudflared：RFC3339 时间 + 级别缩写
+	if t, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		e.Time = t
+		rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		if lv, ok := cloudflaredLevels[fields[1]]; ok {
+			e.Level = lv
+			rest = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
+		}
+		e.Message = rest
+		return e, true
+	}
+
+	// frpc：日期 时间 [级别]；cftunnel 自身（log 包，无级别按 info）：2006/01/02 15:04:05 消息
+	for _, layout := range []string{"2006-01-02 15:04:05.000", "2006-01-02 15:04:05", "2006/01/02 15:04:05"} {
+		t, err := time.ParseInLocation(layout, fields[0]+" "+fields[1], time.Local)
+		if err != nil {
+			continue
+		}
+		e.Time = t
+		rest := strings.TrimSpace(strings.TrimPrefix(line, fields[0]))
+		rest = strings.TrimSpace(strings.TrimPrefix(rest, fields[1]))
+		if len(fields) > 2 {
+			if lv, ok := frpcLevels[fields[2]]; ok {
+				e.Level = lv
+				rest = strings.TrimSpace(strings.TrimPrefix(rest, fields[2]))
+			}
+		}
+		e.Message = rest
+		return e, true
+	}
+	return Entry{}, false
+}
@@ -0,0 +1,164 @@
+package logfile
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"time"
+)
+
+// reverseBlockSize 反向读取的块大小
+const reverseBlockSize = 32 * 1024
+
+// reverseScanner 从文件末尾向前逐行读取，只读取需要的部分
+type reverseScanner struct {
+	r    io.ReaderAt
+	pos  int64
+	buf  []byte
+	line string
+	err  error
+}
+
+func newReverseScanner(r io.ReaderAt, size int64) *reverseScanner {
+	s := &reverseScanner{r: r, pos: size}
+	// 忽略文件末尾的换行
+	if size > 0 {
+		last := make([]byte, 1)
+		if _, err := r.ReadAt(last, size-1); err == nil && last[0] == '\n' {
+			s.pos--
+		}
+	}
+	return s
+}
+
+// Scan 读取上一行，到达文件开头时返回 false
+func (s *reverseScanner) Scan() bool {
+	for {
+		if i := bytes.LastIndexByte(s.buf, '\n'); i >= 0 {
+			s.line = string(bytes.TrimSuffix(s.buf[i+1:], []byte{'\r'}))
+			s.buf = s.buf[:i]
+			return true
+		}
+		if s.pos == 0 {
+			if s.buf == nil {
+				return false
+			}
+			s.line = string(bytes.TrimSuffix(s.buf, []byte{'\r'}))
+			s.buf = nil
+			return true
+		}
+		n := min(int64(reverseBlockSize), s.pos)
+		s.pos -= n
+		chunk := make([]byte, n, n+int64(len(s.buf)))
+		if _, err := s.r.ReadAt(chunk, s.pos); err != nil && err != io.EOF {
+			s.err = err
+			return false
+		}
+		s.buf = append(chunk, s.buf...)
+	}
+}
+
+func (s *reverseScanner) Text() string {
+	return s.line
+}
+
+// Tail 返回 path 中最后 n 条匹配的记录（按时间正序），当前文件不足时继续读取历史文件
+// 从文件末尾反向读取，读够 n 条或遇到早于 since 的记录即停止
+func Tail(path string, n int, parse LineParser, match func(Entry) bool, since time.Time) ([]Entry, error) {
+	if parse == nil {
+		parse = ParseLine
+	}
+	t := &tailer{n: n, parse: parse, match: match, since: since}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	done, err := t.scan(newReverseScanner(f, fi.Size()))
+	f.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range Backups(path) {
+		if done {
+			break
+		}
+		// 历史文件可能已压缩，整体读入内存后反向扫描
+		r, err := OpenBackup(name)
+		if err != nil {
+			continue
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			continue
+		}
+		if done, err = t.scan(newReverseScanner(bytes.NewReader(data), int64(len(data)))); err != nil {
+			return nil, err
+		}
+	}
+	return t.entries, nil
+}
+
+// tailer 反向收集记录：续行暂存，遇到所属的首行后一起按正序判断
+type tailer struct {
+	n       int
+	parse   LineParser
+	match   func(Entry) bool
+	since   time.Time
+	entries []Entry // 正序
+}
+
+// scan 扫描一个文件，返回是否已收集完毕
+func (t *tailer) scan(s *reverseScanner) (bool, error) {
+	var pending []string // 续行，逆序
+	for s.Scan() {
+		line := s.Text()
+		head, ok := t.parse(line)
+		if !ok {
+			pending = append(pending, line)
+			continue
+		}
+		if !t.since.IsZero() && head.Time.Before(t.since) {
+			return true, nil
+		}
+		record := []Entry{head}
+		for i := len(pending) - 1; i >= 0; i-- {
+			record = append(record, continuation(head, pending[i]))
+		}
+		pending = pending[:0]
+		if t.add(record) {
+			return true, nil
+		}
+	}
+	if s.err != nil {
+		return true, s.err
+	}
+	// 文件开头没有首行的续行（所属记录在更早的文件中）单独输出
+	var orphans []Entry
+	for i := len(pending) - 1; i >= 0; i-- {
+		orphans = append(orphans, Entry{Level: LevelInfo, Message: pending[i], Line: pending[i]})
+	}
+	return t.add(orphans), nil
+}
+
+// add 将一条记录（正序）中匹配的行加到结果开头，返回是否已满 n 条
+func (t *tailer) add(record []Entry) bool {
+	var matched []Entry
+	for _, e := range record {
+		if t.match == nil || t.match(e) {
+			matched = append(matched, e)
+		}
+	}
+	if over := len(t.entries) + len(matched) - t.n; over > 0 {
+		matched = matched[min(over, len(matched)):]
+	}
+	t.entries = append(matched, t.entries...)
+	return len(t.entries) >= t.n
+}
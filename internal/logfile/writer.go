@@ -0,0 +1,224 @@
+package logfile
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultMaxSize    = 10 << 20 // 10MB
+	defaultMaxBackups = 5
+)
+
+// Options 轮转参数
+type Options struct {
+	MaxSize    int64         // 单个文件最大字节数，默认 10MB
+	MaxBackups int           // 保留的历史文件数（path.1 ~ path.N），默认 5
+	MaxAge     time.Duration // 历史文件保留时长，为 0 时不按时间清理
+	Compress   bool          // 轮转后 gzip 压缩历史文件（path.N.gz）
+}
+
+// Writer 按大小轮转的日志文件，可被多个 goroutine 并发写入
+type Writer struct {
+	path string
+	opts Options
+	mu   sync.Mutex
+	f    *os.File
+	size int64
+}
+
+// Open 以追加模式打开日志文件，目录不存在时自动创建
+func Open(path string, opts Options) (*Writer, error) {
+	if opts.MaxSize <= 0 {
+		opts.MaxSize = defaultMaxSize
+	}
+	if opts.MaxBackups <= 0 {
+		opts.MaxBackups = defaultMaxBackups
+	}
+	w := &Writer{path: path, opts: opts}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	w.prune()
+	return w, nil
+}
+
+// Path 返回当前日志文件路径
+func (w *Writer) Path() string {
+	return w.path
+}
+
+// Write 写入数据，超过大小上限时先轮转
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return 0, fmt.Errorf("日志文件已关闭: %s", w.path)
+	}
+	if w.size > 0 && w.size+int64(len(p)) > w.opts.MaxSize {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Close 关闭日志文件
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.f == nil {
+		return nil
+	}
+	err := w.f.Close()
+	w.f = nil
+	return err
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = stat.Size()
+	return nil
+}
+
+// rotate 依次后移历史文件：path.N-1 → path.N，…，path → path.1（调用方持有锁）
+// 压缩和旧版未压缩的历史文件一并后移，切换 compress 配置后编号仍然连续
+func (w *Writer) rotate() error {
+	w.f.Close()
+	w.f = nil
+	for _, ext := range []string{"", ".gz"} {
+		os.Remove(backupName(w.path, w.opts.MaxBackups) + ext)
+		for i := w.opts.MaxBackups - 1; i >= 1; i-- {
+			os.Rename(backupName(w.path, i)+ext, backupName(w.path, i+1)+ext)
+		}
+	}
+	if err := os.Rename(w.path, backupName(w.path, 1)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.opts.Compress {
+		if err := compressFile(backupName(w.path, 1)); err != nil {
+			fmt.Fprintf(os.Stderr, "压缩日志失败: %v\n", err)
+		}
+	}
+	w.prune()
+	return nil
+}
+
+// prune 删除超过保留时长的历史文件
+func (w *Writer) prune() {
+	if w.opts.MaxAge <= 0 {
+		return
+	}
+	cutoff := time.Now().Add(-w.opts.MaxAge)
+	for _, name := range Backups(w.path) {
+		if fi, err := os.Stat(name); err == nil && fi.ModTime().Before(cutoff) {
+			os.Remove(name)
+		}
+	}
+}
+
+// Backups 返回 path 的历史文件（path.N 和 path.N.gz），按编号从新到旧排列
+func Backups(path string) []string {
+	var names []string
+	for i := 1; ; i++ {
+		found := false
+		for _, name := range []string{backupName(path, i), backupName(path, i) + ".gz"} {
+			if _, err := os.Stat(name); err == nil {
+				names = append(names, name)
+				found = true
+			}
+		}
+		if !found {
+			return names
+		}
+	}
+}
+
+// compressFile 将 name 压缩为 name.gz 并删除原文件，保留原修改时间
+func compressFile(name string) error {
+	src, err := os.Open(name)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	fi, err := src.Stat()
+	if err != nil {
+		return err
+	}
+
+	tmp := name + ".gz.tmp"
+	dst, err := os.OpenFile(tmp, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(dst)
+	zw.Name = filepath.Base(name)
+	zw.ModTime = fi.ModTime()
+	if _, err := io.Copy(zw, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	src.Close()
+	if err := os.Rename(tmp, name+".gz"); err != nil {
+		return err
+	}
+	os.Chtimes(name+".gz", fi.ModTime(), fi.ModTime())
+	return os.Remove(name)
+}
+
+// OpenBackup 打开历史文件，.gz 文件自动解压
+func OpenBackup(name string) (io.ReadCloser, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if !strings.HasSuffix(name, ".gz") {
+		return f, nil
+	}
+	zr, err := gzip.NewReader(f)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{zr, f}, nil
+}
+
+func backupName(path string, i int) string {
+	return fmt.Sprintf("%s.%d", path, i)
+}
@@ -0,0 +1,68 @@
+package metrics
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+// DefaultBuckets 请求延迟默认分桶（秒）
+var DefaultBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Histogram 并发安全的直方图
+type Histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // 非累计计数，最后一个为 +Inf
+	sum    float64
+	count  uint64
+}
+
+// NewHistogram 按分桶上界创建直方图，bounds 为空时使用 DefaultBuckets
+func NewHistogram(bounds ...float64) *Histogram {
+	if len(bounds) == 0 {
+		bounds = DefaultBuckets
+	}
+	b := append([]float64(nil), bounds...)
+	sort.Float64s(b)
+	return &Histogram{bounds: b, counts: make([]uint64, len(b)+1)}
+}
+
+// Observe 记录一次观测值
+func (h *Histogram) Observe(v float64) {
+	i := sort.SearchFloat64s(h.bounds, v)
+	h.mu.Lock()
+	h.counts[i]++
+	h.sum += v
+	h.count++
+	h.mu.Unlock()
+}
+
+// Samples 返回 _bucket / _sum / _count 样本
+func (h *Histogram) Samples(labels Labels) []Sample {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	samples := make([]Sample, 0, len(h.counts)+2)
+	var cum uint64
+	for i, c := range h.counts {
+		cum += c
+		le := math.Inf(1)
+		if i < len(h.bounds) {
+			le = h.bounds[i]
+		}
+		samples = append(samples, Sample{Suffix: "_bucket", Labels: labels.With("le", formatBound(le)), Value: float64(cum)})
+	}
+	samples = append(samples,
+		Sample{Suffix: "_sum", Labels: labels, Value: h.sum},
+		Sample{Suffix: "_count", Labels: labels, Value: float64(h.count)},
+	)
+	return samples
+}
+
+func formatBound(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,184 @@
+// Package metrics 以 Prometheus 文本格式输出指标，不依赖 client_golang
+package metrics
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 指标类型
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Labels 样本标签
+type Labels map[string]string
+
+// Sample 单个样本，Suffix 用于直方图的 _bucket / _sum / _count
+type Sample struct {
+	Suffix string
+	Labels Labels
+	Value  float64
+}
+
+// Family 同名指标族
+type Family struct {
+	Name    string
+	Help    string
+	Type    string
+	Samples []Sample
+}
+
+// Collector 在每次抓取时返回当前指标
+type Collector func() []Family
+
+// Registry 指标注册表，实现 http.Handler
+type Registry struct {
+	mu         sync.Mutex
+	collectors []Collector
+	raw        []func(w io.Writer)
+}
+
+// NewRegistry 创建空注册表
+func NewRegistry() *Registry {
+	return &Registry{}
+}
+
+// Register 注册指标收集函数
+func (r *Registry) Register(c Collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.collectors = append(r.collectors, c)
+}
+
+// RegisterRaw 注册直接输出文本格式的函数（如转发其他进程的指标）
+func (r *Registry) RegisterRaw(fn func(w io.Writer)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.raw = append(r.raw, fn)
+}
+
+// ServeHTTP 输出所有指标
+func (r *Registry) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.mu.Lock()
+	collectors := append([]Collector(nil), r.collectors...)
+	raw := append([]func(io.Writer){}, r.raw...)
+	r.mu.Unlock()
+
+	var fams []Family
+	for _, c := range collectors {
+		fams = append(fams, c()...)
+	}
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	Write(bw, fams)
+	for _, fn := range raw {
+		fn(bw)
+	}
+	bw.Flush()
+}
+
+// Write 按文本格式输出指标族，同名指标族合并输出
+func Write(w io.Writer, fams []Family) error {
+	var order []string
+	merged := map[string]*Family{}
+	for _, f := range fams {
+		if m, ok := merged[f.Name]; ok {
+			m.Samples = append(m.Samples, f.Samples...)
+			continue
+		}
+		f.Samples = append([]Sample(nil), f.Samples...)
+		merged[f.Name] = &f
+		order = append(order, f.Name)
+	}
+
+	var sb strings.Builder
+	for _, name := range order {
+		f := merged[name]
+		if len(f.Samples) == 0 {
+			continue
+		}
+		if f.Help != "" {
+			sb.WriteString("# HELP " + name + " " + escapeHelp(f.Help) + "\n")
+		}
+		if f.Type != "" {
+			sb.WriteString("# TYPE " + name + " " + f.Type + "\n")
+		}
+		for _, s := range f.Samples {
+			sb.WriteString(name + s.Suffix)
+			writeLabels(&sb, s.Labels)
+			sb.WriteString(" " + formatValue(s.Value) + "\n")
+		}
+	}
+	_, err := io.WriteString(w, sb.String())
+	return err
+}
+
+// writeLabels 按名称排序输出标签，保证输出稳定
+func writeLabels(sb *strings.Builder, labels Labels) {
+	if len(labels) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	sb.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(k + `="` + EscapeLabel(labels[k]) + `"`)
+	}
+	sb.WriteByte('}')
+}
+
+// EscapeLabel 转义标签值中的反斜杠、双引号和换行
+func EscapeLabel(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(v)
+}
+
+func escapeHelp(v string) string {
+	return strings.NewReplacer(`\`, `\\`, "\n", `\n`).Replace(v)
+}
+
+func formatValue(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// Bool 将布尔值转换为 0 / 1
+func Bool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// With 复制标签并追加键值对
+func (l Labels) With(kv ...string) Labels {
+	out := make(Labels, len(l)+len(kv)/2)
+	for k, v := range l {
+		out[k] = v
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		out[kv[i]] = kv[i+1]
+	}
+	return out
+}
@@ -0,0 +1,433 @@
+// Package qrcode 生成 QR 码并以 Unicode 半块字符输出到终端，用于分享 quick 模式的临时地址
+// 仅实现字节模式、纠错等级 M、版本 1-10（最多 213 字节），足以容纳常见 URL
+package qrcode
+
+import (
+	"fmt"
+	"strings"
+)
+
+// block 每个版本的纠错块结构（纠错等级 M）
+type block struct {
+	ecc     int // 每块纠错码字数
+	groups  [2][2]int
+	aligns  []int // 校正图形中心坐标
+	remBits int
+}
+
+// versions 版本 1-10：{纠错码字, {{块数, 数据码字}, {块数, 数据码字}}, 校正图形坐标}
+var versions = []block{
+	{10, [2][2]int{{1, 16}}, nil, 0},
+	{16, [2][2]int{{1, 28}}, []int{6, 18}, 7},
+	{26, [2][2]int{{1, 44}}, []int{6, 22}, 7},
+	{18, [2][2]int{{2, 32}}, []int{6, 26}, 7},
+	{24, [2][2]int{{2, 43}}, []int{6, 30}, 7},
+	{16, [2][2]int{{4, 27}}, []int{6, 34}, 7},
+	{18, [2][2]int{{4, 31}}, []int{6, 22, 38}, 0},
+	{22, [2][2]int{{2, 38}, {2, 39}}, []int{6, 24, 42}, 0},
+	{22, [2][2]int{{3, 36}, {2, 37}}, []int{6, 26, 46}, 0},
+	{26, [2][2]int{{4, 43}, {1, 44}}, []int{6, 28, 50}, 0},
+}
+
+func (b block) dataLen() int {
+	return b.groups[0][0]*b.groups[0][1] + b.groups[1][0]*b.groups[1][1]
+}
+
+// Code 已编码的 QR 码
+type Code struct {
+	size     int
+	modules  [][]bool // [y][x]，true 为深色
+	function [][]bool // 定位、时序、格式等功能区，不参与数据填充和掩码
+}
+
+// New 编码文本，超出版本 10 的容量时返回错误
+func New(text string) (*Code, error) {
+	data := []byte(text)
+	for v := 1; v <= len(versions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+len(data)*8 <= versions[v-1].dataLen()*8 {
+			return encode(v, data, countBits), nil
+		}
+	}
+	return nil, fmt.Errorf("内容过长（%d 字节），无法生成二维码", len(data))
+}
+
+func encode(version int, data []byte, countBits int) *Code {
+	b := versions[version-1]
+	size := version*4 + 17
+	c := &Code{size: size, modules: grid(size), function: grid(size)}
+
+	// 数据位流：模式指示 0100 + 长度 + 数据 + 终止符，补齐到字节后填充 0xEC / 0x11
+	var bits bitBuffer
+	bits.append(0x4, 4)
+	bits.append(len(data), countBits)
+	for _, d := range data {
+		bits.append(int(d), 8)
+	}
+	capacity := b.dataLen() * 8
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+
+	c.drawFunctionPatterns(version, b)
+	c.drawCodewords(interleave(bits.bytes(), b), b.remBits)
+	c.applyBestMask()
+	return c
+}
+
+func grid(size int) [][]bool {
+	g := make([][]bool, size)
+	for i := range g {
+		g[i] = make([]bool, size)
+	}
+	return g
+}
+
+func (c *Code) set(x, y int, dark bool) {
+	c.modules[y][x] = dark
+	c.function[y][x] = true
+}
+
+func (c *Code) drawFunctionPatterns(version int, b block) {
+	// 时序图形
+	for i := 0; i < c.size; i++ {
+		c.set(6, i, i%2 == 0)
+		c.set(i, 6, i%2 == 0)
+	}
+	// 三个定位图形（含分隔符）
+	for _, p := range [][2]int{{3, 3}, {c.size - 4, 3}, {3, c.size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := p[0]+dx, p[1]+dy
+				if x >= 0 && x < c.size && y >= 0 && y < c.size {
+					d := max(abs(dx), abs(dy))
+					c.set(x, y, d != 2 && d != 4)
+				}
+			}
+		}
+	}
+	// 校正图形，避开三个定位图形所在的角
+	last := len(b.aligns) - 1
+	for i, ay := range b.aligns {
+		for j, ax := range b.aligns {
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					c.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+	c.drawFormatBits(0) // 先占位，选定掩码后重写
+	// 版本信息（版本 7 及以上）
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		info := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			dark := info>>i&1 != 0
+			a, b := c.size-11+i%3, i/3
+			c.set(a, b, dark)
+			c.set(b, a, dark)
+		}
+	}
+}
+
+// drawFormatBits 写入纠错等级（M = 00）与掩码编号的格式信息，两处各一份
+func (c *Code) drawFormatBits(mask int) {
+	data := mask // 纠错等级 M 的指示位为 00
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		c.set(8, i, bit(i))
+	}
+	c.set(8, 7, bit(6))
+	c.set(8, 8, bit(7))
+	c.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		c.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		c.set(c.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		c.set(8, c.size-15+i, bit(i))
+	}
+	c.set(8, c.size-8, true) // 固定深色模块
+}
+
+// drawCodewords 从右下角起按两列一组的之字形填充数据
+func (c *Code) drawCodewords(codewords []byte, remBits int) {
+	total := len(codewords)*8 + remBits
+	i := 0
+	for right := c.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5 // 跳过纵向时序图形
+		}
+		for vert := 0; vert < c.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = c.size - 1 - vert
+				}
+				if c.function[y][x] || i >= total {
+					continue
+				}
+				if i < len(codewords)*8 {
+					c.modules[y][x] = codewords[i>>3]>>(7-i&7)&1 != 0
+				}
+				i++
+			}
+		}
+	}
+}
+
+var masks = []func(x, y int) bool{
+	func(x, y int) bool { return (x+y)%2 == 0 },
+	func(x, y int) bool { return y%2 == 0 },
+	func(x, y int) bool { return x%3 == 0 },
+	func(x, y int) bool { return (x+y)%3 == 0 },
+	func(x, y int) bool { return (x/3+y/2)%2 == 0 },
+	func(x, y int) bool { return x*y%2+x*y%3 == 0 },
+	func(x, y int) bool { return (x*y%2+x*y%3)%2 == 0 },
+	func(x, y int) bool { return ((x+y)%2+x*y%3)%2 == 0 },
+}
+
+func (c *Code) applyMask(mask int) {
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if !c.function[y][x] && masks[mask](x, y) {
+				c.modules[y][x] = !c.modules[y][x]
+			}
+		}
+	}
+}
+
+// applyBestMask 逐个尝试 8 种掩码，选用罚分最低的
+func (c *Code) applyBestMask() {
+	best, bestScore := 0, -1
+	for mask := range masks {
+		c.applyMask(mask)
+		c.drawFormatBits(mask)
+		if score := c.penalty(); bestScore < 0 || score < bestScore {
+			best, bestScore = mask, score
+		}
+		c.applyMask(mask) // 异或两次即还原
+	}
+	c.applyMask(best)
+	c.drawFormatBits(best)
+}
+
+// penalty 按规范的四条规则计算罚分：连续同色、2×2 同色块、类定位图形、深浅比例
+func (c *Code) penalty() int {
+	score := 0
+	at := func(x, y int, vertical bool) bool {
+		if vertical {
+			return c.modules[x][y]
+		}
+		return c.modules[y][x]
+	}
+	// 类定位图形 1:1:3:1:1，一侧有 4 个浅色模块
+	finder := []bool{true, false, true, true, true, false, true}
+	for _, vertical := range []bool{false, true} {
+		for y := 0; y < c.size; y++ {
+			run := 0
+			for x := 0; x < c.size; x++ {
+				if x > 0 && at(x, y, vertical) == at(x-1, y, vertical) {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					score += 3
+				} else if run > 5 {
+					score++
+				}
+			}
+			for x := 0; x+7 <= c.size; x++ {
+				match := true
+				for k, f := range finder {
+					if at(x+k, y, vertical) != f {
+						match = false
+						break
+					}
+				}
+				if match && (c.light(x-4, x, y, vertical) || c.light(x+7, x+11, y, vertical)) {
+					score += 40
+				}
+			}
+		}
+	}
+	dark := 0
+	for y := 0; y < c.size; y++ {
+		for x := 0; x < c.size; x++ {
+			if c.modules[y][x] {
+				dark++
+			}
+			if x+1 < c.size && y+1 < c.size {
+				v := c.modules[y][x]
+				if c.modules[y][x+1] == v && c.modules[y+1][x] == v && c.modules[y+1][x+1] == v {
+					score += 3
+				}
+			}
+		}
+	}
+	total := c.size * c.size
+	k := (abs(dark*20-total*10) + total - 1) / total
+	return score + max(k-1, 0)*10
+}
+
+// light 判断一行（或列）中 [from, to) 是否全为浅色，超出边界视为浅色（静区）
+func (c *Code) light(from, to, y int, vertical bool) bool {
+	for x := from; x < to; x++ {
+		if x < 0 || x >= c.size {
+			continue
+		}
+		if vertical && c.modules[x][y] || !vertical && c.modules[y][x] {
+			return false
+		}
+	}
+	return true
+}
+
+// Terminal 以半块字符渲染（每行字符表示两行模块），显式设置黑底白字，深浅色终端主题下都能扫描
+func (c *Code) Terminal() string {
+	const quiet = 2
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x >= 0 && x < c.size && y >= 0 && y < c.size && c.modules[y][x]
+	}
+	var sb strings.Builder
+	total := c.size + quiet*2
+	for y := 0; y < total; y += 2 {
+		sb.WriteString("\033[97;40m")
+		for x := 0; x < total; x++ {
+			top, bottom := dark(x, y), y+1 < total && dark(x, y+1)
+			switch {
+			case !top && !bottom:
+				sb.WriteString("█")
+			case !top:
+				sb.WriteString("▀")
+			case !bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\033[0m\n")
+	}
+	return sb.String()
+}
+
+// interleave 分块计算纠错码，按块交错排列数据码字和纠错码字
+func interleave(data []byte, b block) []byte {
+	divisor := rsDivisor(b.ecc)
+	var blocks, eccs [][]byte
+	for _, g := range b.groups {
+		for i := 0; i < g[0]; i++ {
+			blk := data[:g[1]]
+			data = data[g[1]:]
+			blocks = append(blocks, blk)
+			eccs = append(eccs, rsRemainder(blk, divisor))
+		}
+	}
+	var out []byte
+	for i := 0; ; i++ {
+		added := false
+		for _, blk := range blocks {
+			if i < len(blk) {
+				out = append(out, blk[i])
+				added = true
+			}
+		}
+		if !added {
+			break
+		}
+	}
+	for i := 0; i < b.ecc; i++ {
+		for _, e := range eccs {
+			out = append(out, e[i])
+		}
+	}
+	return out
+}
+
+// rsDivisor Reed-Solomon 生成多项式（GF(256)，本原多项式 0x11D）
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMul(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMul(root, 0x02)
+	}
+	return result
+}
+
+func rsRemainder(data, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, d := range data {
+		factor := d ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, v := range divisor {
+			result[i] ^= gfMul(v, factor)
+		}
+	}
+	return result
+}
+
+func gfMul(x, y byte) byte {
+	var z int
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+// bitBuffer 按位追加的缓冲区
+type bitBuffer []bool
+
+func (b *bitBuffer) append(v, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, v>>i&1 != 0)
+	}
+}
+
+func (b bitBuffer) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+func abs(x int) int {
+	if x < 0 {
+		return -x
+	}
+	return x
+}
@@ -0,0 +1,432 @@
+package qrcode
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// goldenCases 分别落在版本 1、7、10 的测试内容
+var goldenCases = []struct {
+	version int
+	text    string
+}{
+	{1, "https://a.cn"},
+	{7, "https://quiet-river-1234.trycloudflare.com/?" + strings.Repeat("x", 64)},
+	{10, "https://quiet-river-1234.trycloudflare.com/" + strings.Repeat("0123456789", 16)},
+}
+
+// TestRSRemainder 对照规范附录与公开教程中的纠错码字示例
+func TestRSRemainder(t *testing.T) {
+	tests := []struct {
+		name      string
+		data, ecc []byte
+	}{
+		// ISO/IEC 18004 附录 I："01234567"，1-M
+		{"01234567", []byte{16, 32, 12, 86, 97, 128, 236, 17, 236, 17, 236, 17, 236, 17, 236, 17},
+			[]byte{165, 36, 212, 193, 237, 54, 199, 135, 44, 85}},
+		// "HELLO WORLD"，1-M
+		{"HELLO WORLD", []byte{32, 91, 11, 120, 209, 114, 220, 77, 67, 64, 236, 17, 236, 17, 236, 17},
+			[]byte{196, 35, 39, 119, 235, 215, 231, 226, 93, 23}},
+	}
+	for _, tt := range tests {
+		if got := rsRemainder(tt.data, rsDivisor(len(tt.ecc))); !bytes.Equal(got, tt.ecc) {
+			t.Errorf("%s: ecc = %v, want %v", tt.name, got, tt.ecc)
+		}
+	}
+}
+
+// TestFormatBits 对照规范表 C.1 中纠错等级 M 的格式信息
+func TestFormatBits(t *testing.T) {
+	want := []string{
+		"101010000010010", "101000100100101", "101111001111100", "101101101001011",
+		"100010111111001", "100000011001110", "100111110010111", "100101010100000",
+	}
+	for mask, w := range want {
+		c := &Code{size: 21, modules: grid(21), function: grid(21)}
+		c.drawFormatBits(mask)
+		first, second := readFormat(c)
+		if first != w || second != w {
+			t.Errorf("mask %d: format %s / %s, want %s", mask, first, second, w)
+		}
+	}
+}
+
+// TestVersionInfo 对照规范表 D.1 中的版本信息
+func TestVersionInfo(t *testing.T) {
+	want := map[int]string{
+		7:  "000111110010010100",
+		8:  "001000010110111100",
+		9:  "001001101010011001",
+		10: "001010010011010011",
+	}
+	for v, w := range want {
+		size := v*4 + 17
+		c := &Code{size: size, modules: grid(size), function: grid(size)}
+		c.drawFunctionPatterns(v, versions[v-1])
+		var top, left strings.Builder
+		for i := 17; i >= 0; i-- {
+			top.WriteString(bit(c.modules[i/3][size-11+i%3]))
+			left.WriteString(bit(c.modules[size-11+i%3][i/3]))
+		}
+		if top.String() != w || left.String() != w {
+			t.Errorf("version %d: info %s / %s, want %s", v, top.String(), left.String(), w)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		n       int // 字节数
+		version int
+	}{
+		{14, 1}, {15, 2}, {106, 6}, {107, 7}, {180, 9}, {181, 10}, {213, 10},
+	}
+	for _, tt := range tests {
+		c, err := New(strings.Repeat("a", tt.n))
+		if err != nil {
+			t.Errorf("New(%d bytes): %v", tt.n, err)
+			continue
+		}
+		if got := (c.size - 17) / 4; got != tt.version {
+			t.Errorf("New(%d bytes): version %d, want %d", tt.n, got, tt.version)
+		}
+	}
+	if _, err := New(strings.Repeat("a", 214)); err == nil {
+		t.Error("New(214 bytes): want error")
+	}
+}
+
+// TestDecode 用独立实现的解码流程读取生成的矩阵：格式信息、去掩码、解交错、纠错校验和字节模式数据
+func TestDecode(t *testing.T) {
+	for _, tt := range goldenCases {
+		c, err := New(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := (c.size - 17) / 4; got != tt.version {
+			t.Fatalf("%q: version %d, want %d", tt.text, got, tt.version)
+		}
+		if got := decode(t, c.modules); got != tt.text {
+			t.Errorf("version %d: decoded %q, want %q", tt.version, got, tt.text)
+		}
+	}
+}
+
+// TestGolden 固定各版本的输出矩阵（已由 TestDecode 的独立解码流程验证），防止编码改动引入回归
+func TestGolden(t *testing.T) {
+	for _, tt := range goldenCases {
+		c, err := New(tt.text)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var sb strings.Builder
+		for _, row := range c.modules {
+			for _, m := range row {
+				if m {
+					sb.WriteByte('#')
+				} else {
+					sb.WriteByte('.')
+				}
+			}
+			sb.WriteByte('\n')
+		}
+		path := filepath.Join("testdata", fmt.Sprintf("v%d.txt", tt.version))
+		want, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if sb.String() != string(want) {
+			t.Errorf("version %d: matrix differs from %s:\n%s", tt.version, path, sb.String())
+		}
+	}
+}
+
+func TestTerminal(t *testing.T) {
+	c, err := New("https://a.cn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSuffix(c.Terminal(), "\n"), "\n")
+	// 21 个模块 + 两侧各 2 个静区，每行字符表示两行模块
+	if len(lines) != 13 {
+		t.Fatalf("lines = %d, want 13", len(lines))
+	}
+	for _, l := range lines {
+		l = strings.TrimSuffix(strings.TrimPrefix(l, "\033[97;40m"), "\033[0m")
+		if n := len([]rune(l)); n != 25 {
+			t.Fatalf("line width = %d, want 25", n)
+		}
+	}
+}
+
+func bit(b bool) string {
+	if b {
+		return "1"
+	}
+	return "0"
+}
+
+// readFormat 读取两份格式信息（高位在前）
+func readFormat(c *Code) (string, string) {
+	var first, second [15]string
+	for i := 0; i <= 5; i++ {
+		first[i] = bit(c.modules[i][8])
+	}
+	first[6] = bit(c.modules[7][8])
+	first[7] = bit(c.modules[8][8])
+	first[8] = bit(c.modules[8][7])
+	for i := 9; i < 15; i++ {
+		first[i] = bit(c.modules[8][14-i])
+	}
+	for i := 0; i < 8; i++ {
+		second[i] = bit(c.modules[8][c.size-1-i])
+	}
+	for i := 8; i < 15; i++ {
+		second[i] = bit(c.modules[c.size-15+i][8])
+	}
+	var a, b strings.Builder
+	for i := 14; i >= 0; i-- {
+		a.WriteString(first[i])
+		b.WriteString(second[i])
+	}
+	return a.String(), b.String()
+}
+
+// decodeBlocks 纠错等级 M 下各测试版本的分块：每块数据码字数与纠错码字数（规范表 9）
+var decodeBlocks = map[int]struct {
+	data []int
+	ecc  int
+}{
+	1:  {[]int{16}, 10},
+	7:  {[]int{31, 31, 31, 31}, 18},
+	10: {[]int{43, 43, 43, 43, 44}, 26},
+}
+
+// decodeAligns 校正图形中心坐标（规范表 E.1）
+var decodeAligns = map[int][]int{1: nil, 7: {6, 22, 38}, 10: {6, 28, 50}}
+
+// decode 按规范独立解码矩阵，不复用编码器中的功能区、掩码和纠错实现
+func decode(t *testing.T, m [][]bool) string {
+	t.Helper()
+	size := len(m)
+	version := (size - 17) / 4
+
+	// 格式信息：去除 0x5412 掩码后校验 BCH 并取掩码编号
+	format := 0
+	for i := 0; i <= 5; i++ {
+		format |= b2i(m[i][8]) << i
+	}
+	format |= b2i(m[7][8])<<6 | b2i(m[8][8])<<7 | b2i(m[8][7])<<8
+	for i := 9; i < 15; i++ {
+		format |= b2i(m[8][14-i]) << i
+	}
+	format ^= 0x5412
+	if format>>13 != 0 {
+		t.Fatalf("version %d: error correction level %02b, want M (00)", version, format>>13)
+	}
+	if rem := polyMod(format, 0x537, 10); rem != 0 {
+		t.Fatalf("version %d: format BCH remainder %d", version, rem)
+	}
+	mask := format >> 10 & 7
+
+	// 功能区
+	reserved := make([][]bool, size)
+	for i := range reserved {
+		reserved[i] = make([]bool, size)
+	}
+	fill := func(x0, y0, x1, y1 int) {
+		for y := max(y0, 0); y <= min(y1, size-1); y++ {
+			for x := max(x0, 0); x <= min(x1, size-1); x++ {
+				reserved[y][x] = true
+			}
+		}
+	}
+	fill(0, 0, 8, 8)           // 左上定位图形、分隔符与格式信息
+	fill(size-8, 0, size-1, 8) // 右上
+	fill(0, size-8, 8, size-1) // 左下
+	fill(6, 0, 6, size-1)      // 时序图形
+	fill(0, 6, size-1, 6)
+	if version >= 7 {
+		fill(size-11, 0, size-9, 5)
+		fill(0, size-11, 5, size-9)
+	}
+	aligns := decodeAligns[version]
+	for _, ay := range aligns {
+		for _, ax := range aligns {
+			if ax < 9 && ay < 9 || ax > size-9 && ay < 9 || ax < 9 && ay > size-9 {
+				continue // 与定位图形重叠
+			}
+			fill(ax-2, ay-2, ax+2, ay+2)
+		}
+	}
+
+	// 之字形读取并去掩码
+	var bits []int
+	upward := true
+	for right := size - 1; right > 0; right -= 2 {
+		if right == 6 {
+			right--
+		}
+		for k := 0; k < size; k++ {
+			y := k
+			if upward {
+				y = size - 1 - k
+			}
+			for _, x := range []int{right, right - 1} {
+				if reserved[y][x] {
+					continue
+				}
+				v := b2i(m[y][x])
+				if maskBit(mask, y, x) {
+					v ^= 1
+				}
+				bits = append(bits, v)
+			}
+		}
+		upward = !upward
+	}
+	codewords := make([]byte, len(bits)/8)
+	for i := range codewords {
+		for j := 0; j < 8; j++ {
+			codewords[i] = codewords[i]<<1 | byte(bits[i*8+j])
+		}
+	}
+
+	// 解交错并校验每块的纠错码（所有伴随式为 0）
+	layout := decodeBlocks[version]
+	blocks := make([][]byte, len(layout.data))
+	pos := 0
+	for i := 0; i < 44; i++ {
+		for b, n := range layout.data {
+			if i < n {
+				blocks[b] = append(blocks[b], codewords[pos])
+				pos++
+			}
+		}
+	}
+	for i := 0; i < layout.ecc; i++ {
+		for b := range blocks {
+			blocks[b] = append(blocks[b], codewords[pos])
+			pos++
+		}
+	}
+	var data []byte
+	for b, blk := range blocks {
+		for i := 0; i < layout.ecc; i++ {
+			if s := evalPoly(blk, gfExp(i)); s != 0 {
+				t.Fatalf("version %d block %d: syndrome %d = %d", version, b, i, s)
+			}
+		}
+		data = append(data, blk[:layout.data[b]]...)
+	}
+
+	// 字节模式：0100 + 长度（版本 10 起 16 位）+ 数据
+	if data[0]>>4 != 0x4 {
+		t.Fatalf("version %d: mode %04b, want byte mode", version, data[0]>>4)
+	}
+	r := bitReader{data: data, pos: 4}
+	countBits := 8
+	if version >= 10 {
+		countBits = 16
+	}
+	n := r.read(countBits)
+	out := make([]byte, n)
+	for i := range out {
+		out[i] = byte(r.read(8))
+	}
+	return string(out)
+}
+
+func b2i(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// maskBit 规范表 10 的掩码条件（i 为行，j 为列）
+func maskBit(mask, i, j int) bool {
+	switch mask {
+	case 0:
+		return (i+j)%2 == 0
+	case 1:
+		return i%2 == 0
+	case 2:
+		return j%3 == 0
+	case 3:
+		return (i+j)%3 == 0
+	case 4:
+		return (i/2+j/3)%2 == 0
+	case 5:
+		return (i*j)%2+(i*j)%3 == 0
+	case 6:
+		return ((i*j)%2+(i*j)%3)%2 == 0
+	default:
+		return ((i+j)%2+(i*j)%3)%2 == 0
+	}
+}
+
+// polyMod GF(2) 多项式取余
+func polyMod(v, gen, degree int) int {
+	for i := 14; i >= degree; i-- {
+		if v>>i&1 != 0 {
+			v ^= gen << (i - degree)
+		}
+	}
+	return v
+}
+
+// gfExp 返回 α^n（GF(256)，本原多项式 0x11D）
+func gfExp(n int) int {
+	x := 1
+	for ; n > 0; n-- {
+		x <<= 1
+		if x&0x100 != 0 {
+			x ^= 0x11D
+		}
+	}
+	return x
+}
+
+// gfMulSlow 逐位相乘，与编码器的 gfMul 独立
+func gfMulSlow(a, b int) int {
+	p := 0
+	for b > 0 {
+		if b&1 != 0 {
+			p ^= a
+		}
+		a <<= 1
+		if a&0x100 != 0 {
+			a ^= 0x11D
+		}
+		b >>= 1
+	}
+	return p
+}
+
+// evalPoly 以 Horner 法计算码字多项式在 x 处的值（首个码字为最高次项）
+func evalPoly(coeffs []byte, x int) int {
+	y := 0
+	for _, c := range coeffs {
+		y = gfMulSlow(y, x) ^ int(c)
+	}
+	return y
+}
+
+type bitReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *bitReader) read(n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		v = v<<1 | int(r.data[r.pos/8]>>(7-r.pos%8)&1)
+		r.pos++
+	}
+	return v
+}
@@ -1,8 +1,8 @@
 package relay
 
 import (
-	"fmt"
 	"net"
+	"strconv"
 	"sync"
 	"time"
 
@@ -107,7 +107,7 @@ func checkRule(r config.RelayRule, server string) RuleCheckResult {
 	}
 
 	// 检测本地服务
-	localAddr := fmt.Sprintf("%s:%d", localIP, r.LocalPort)
+	localAddr := net.JoinHostPort(localIP, strconv.Itoa(r.LocalPort))
 	conn, err := net.DialTimeout("tcp", localAddr, checkTimeout)
 	if err == nil {
 		conn.Close()
@@ -119,7 +119,7 @@ func checkRule(r config.RelayRule, server string) RuleCheckResult {
 	// 检测远程穿透端口
 	if r.RemotePort > 0 && server != "" {
 		host, _, _ := net.SplitHostPort(server)
-		remoteAddr := fmt.Sprintf("%s:%d", host, r.RemotePort)
+		remoteAddr := net.JoinHostPort(host, strconv.Itoa(r.RemotePort))
 		start := time.Now()
 		conn, err := net.DialTimeout("tcp", remoteAddr, checkTimeout)
 		if err == nil {
@@ -6,25 +6,19 @@ import (
 	"compress/gzip"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"path/filepath"
 	"runtime"
 	"strings"
-	"time"
 
+	"github.com/qingchencloud/cftunnel/internal/binver"
 	"github.com/qingchencloud/cftunnel/internal/config"
+	"github.com/qingchencloud/cftunnel/internal/download"
+	"github.com/qingchencloud/cftunnel/internal/verify"
 )
 
-const frpVersion = "0.66.0"
-
-// GitHub 镜像源列表（与 daemon/download.go 保持一致）
-var mirrors = []string{
-	"https://ghfast.top/",
-	"https://gh-proxy.com/",
-	"https://ghproxy.cn/",
-	"", // 原始 GitHub 地址
-}
+// DefaultFrpVersion 未锁定版本时首次安装的 frp 版本
+const DefaultFrpVersion = "0.66.0"
 
 // FrpcPath 返回 frpc 二进制路径
 func FrpcPath() string {
@@ -46,67 +40,126 @@ func FrpsPath() string {
 
 // EnsureFrpc 确保 frpc 已安装，未安装则自动下载
 func EnsureFrpc() (string, error) {
-	path := FrpcPath()
-	if _, err := os.Stat(path); err == nil {
-		return path, nil
-	}
-	return path, downloadFrp(path, "frpc")
+	return ensureFrp(FrpcPath(), "frpc")
 }
 
 // EnsureFrps 确保 frps 已安装，未安装则自动下载
 func EnsureFrps() (string, error) {
-	path := FrpsPath()
+	return ensureFrp(FrpsPath(), "frps")
+}
+
+func ensureFrp(path, binary string) (string, error) {
 	if _, err := os.Stat(path); err == nil {
 		return path, nil
 	}
-	return path, downloadFrp(path, "frps")
+	if err := downloadFrp(path, binary, pinnedFrpVersion()); err != nil {
+		return path, err
+	}
+	fmt.Printf("%s 已下载到 %s\n", binary, path)
+	return path, nil
 }
 
-func downloadFrp(dest, binary string) error {
-	filename, err := frpFilename()
+// pinnedFrpVersion 返回 config.yml 锁定的 frp 版本，未锁定时为内置默认版本
+func pinnedFrpVersion() string {
+	if cfg, err := config.Load(); err == nil && cfg.Relay.FrpVersion != "" {
+		return binver.Normalize(cfg.Relay.FrpVersion)
+	}
+	return DefaultFrpVersion
+}
+
+// FrpVersion 返回 frpc / frps 二进制的版本号，无法识别时返回空
+func FrpVersion(path string) string {
+	return binver.Version(path, "-v")
+}
+
+// LatestFrpVersion 查询 frp 最新发布版本
+func LatestFrpVersion() (string, error) {
+	rel, err := verify.FetchRelease(frpRepo, "")
 	if err != nil {
-		return err
+		return "", err
 	}
-	origin := fmt.Sprintf("https://github.com/fatedier/frp/releases/download/v%s/", frpVersion)
-	fmt.Printf("正在下载 %s (v%s)...\n", binary, frpVersion)
+	return binver.Normalize(rel.Tag), nil
+}
 
-	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+// InstallFrp 下载并校验指定版本的 frpc，替换 bin 目录中的 frpc
+// 原二进制保留为上一版本，可通过 RollbackFrp 恢复
+func InstallFrp(version string) error {
+	path := FrpcPath()
+	tmp := binver.NewPath(path)
+	if err := downloadFrp(tmp, "frpc", version); err != nil {
+		binver.Discard(tmp)
 		return err
 	}
+	return binver.Replace(path, tmp)
+}
 
-	client := &http.Client{Timeout: 120 * time.Second}
-	var lastErr error
-	for _, mirror := range mirrors {
-		url := mirror + origin + filename
-		src := "GitHub"
-		if mirror != "" {
-			src = strings.TrimRight(mirror, "/")
-		}
-		fmt.Printf("尝试下载: %s ...\n", src)
+// RollbackFrp 将 frpc 切换回上一版本（再次执行可撤销）
+func RollbackFrp() error {
+	return binver.Rollback(FrpcPath())
+}
 
-		resp, err := client.Get(url)
-		if err != nil {
-			fmt.Printf("  连接失败: %v\n", err)
-			lastErr = err
-			continue
-		}
-		if resp.StatusCode != 200 {
-			resp.Body.Close()
-			fmt.Printf("  HTTP %d\n", resp.StatusCode)
-			lastErr = fmt.Errorf("HTTP %d from %s", resp.StatusCode, src)
-			continue
-		}
+// frpRepo frp 官方仓库
+const frpRepo = "fatedier/frp"
 
-		err = extractFrpBinary(resp.Body, dest, filename, binary)
-		resp.Body.Close()
-		if err != nil {
-			lastErr = err
-			continue
+// frpChecksums 从 GitHub 官方（不经过镜像）获取 frp 发布包的 SHA-256：
+// 优先使用 API 的 digest 字段，其次是发布附带的 frp_sha256_checksums.txt
+func frpChecksums(tag string) (map[string]string, error) {
+	rel, err := verify.FetchRelease(frpRepo, tag)
+	if err == nil && len(rel.Digests) > 0 {
+		return rel.Digests, nil
+	}
+	sums, _, err2 := verify.FetchChecksums("https://github.com/" + frpRepo + "/releases/download/" + tag + "/frp_sha256_checksums.txt")
+	if err2 != nil {
+		if err == nil {
+			err = err2
 		}
-		fmt.Printf("%s 已下载到 %s\n", binary, dest)
-		return nil
+		return nil, err
+	}
+	return sums, nil
+}
+
+// downloadFrp 从 frp 指定版本的发布包中提取 binary 到 dest
+func downloadFrp(dest, binary, version string) error {
+	version = binver.Normalize(version)
+	filename, err := frpFilename(version)
+	if err != nil {
+		return err
+	}
+	tag := "v" + version
+	origin := fmt.Sprintf("https://github.com/%s/releases/download/%s/", frpRepo, tag)
+
+	want := ""
+	sums, err := frpChecksums(tag)
+	switch {
+	case err == nil && sums[filename] != "":
+		want = sums[filename]
+	case verify.Skip():
+		fmt.Printf("警告: 已设置 %s，跳过 frp 校验\n", verify.SkipEnv)
+	case err != nil:
+		return fmt.Errorf("获取 frp 官方校验值失败: %w（无法访问 GitHub 时可设置 %s=1 跳过校验）", err, verify.SkipEnv)
+	default:
+		return fmt.Errorf("frp %s 的校验列表中没有 %s", tag, filename)
+	}
+	fmt.Printf("正在下载 %s (v%s)...\n", binary, version)
+
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return err
+	}
+	tmp, sum, err := download.Fetch(download.File{Name: filename, URL: origin + filename, SHA256: want, Key: tag})
+	if err != nil {
+		return err
+	}
+	defer download.Remove(tmp)
+	if err := extractFrpBinary(tmp, dest, filename, binary); err != nil {
+		return err
+	}
+	if _, err := verify.Record(dest); err != nil {
+		fmt.Printf("警告: 记录校验值失败: %v\n", err)
 	}
-	return fmt.Errorf("所有下载源均失败，最后错误: %w", lastErr)
+	if want != "" {
+		fmt.Printf("✓ SHA-256 校验通过 (%s)\n", sum)
+	}
+	return nil
 }
 
 // extractFrpBinary 从压缩包中提取指定二进制文件
@@ -194,22 +247,86 @@ func extractFrpZip(r io.Reader, dest, binary string) error {
 	return fmt.Errorf("zip 中未找到 %s", target)
 }
 
-func frpFilename() (string, error) {
+func frpFilename(version string) (string, error) {
 	platform := runtime.GOOS + "/" + runtime.GOARCH
 	switch platform {
 	case "darwin/arm64":
-		return fmt.Sprintf("frp_%s_darwin_arm64.tar.gz", frpVersion), nil
+		return fmt.Sprintf("frp_%s_darwin_arm64.tar.gz", version), nil
 	case "darwin/amd64":
-		return fmt.Sprintf("frp_%s_darwin_amd64.tar.gz", frpVersion), nil
+		return fmt.Sprintf("frp_%s_darwin_amd64.tar.gz", version), nil
 	case "linux/amd64":
-		return fmt.Sprintf("frp_%s_linux_amd64.tar.gz", frpVersion), nil
+		return fmt.Sprintf("frp_%s_linux_amd64.tar.gz", version), nil
 	case "linux/arm64":
-		return fmt.Sprintf("frp_%s_linux_arm64.tar.gz", frpVersion), nil
+		return fmt.Sprintf("frp_%s_linux_arm64.tar.gz", version), nil
 	case "windows/amd64":
-		return fmt.Sprintf("frp_%s_windows_amd64.zip", frpVersion), nil
+		return fmt.Sprintf("frp_%s_windows_amd64.zip", version), nil
 	case "windows/arm64":
-		return fmt.Sprintf("frp_%s_windows_arm64.zip", frpVersion), nil
+		return fmt.Sprintf("frp_%s_windows_arm64.zip", version), nil
 	default:
 		return "", fmt.Errorf("不支持的平台: %s", platform)
 	}
 }
+
+// IsFrpArchive 文件名是否为当前平台的 frp 发布包
+func IsFrpArchive(name string) bool {
+	ext := ".tar.gz"
+	if runtime.GOOS == "windows" {
+		ext = ".zip"
+	}
+	version, ok := strings.CutPrefix(name, "frp_")
+	if !ok {
+		return false
+	}
+	version, ok = strings.CutSuffix(version, "_"+runtime.GOOS+"_"+runtime.GOARCH+ext)
+	return ok && binver.Normalize(version) != ""
+}
+
+// ImportFrp 从本地文件（官方发布包或解压出的二进制）离线安装 binary（frpc / frps），返回其版本
+// 原二进制保留为上一版本
+func ImportFrp(src, binary string) (string, error) {
+	f, err := os.Open(src)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	path := FrpcPath()
+	if binary == "frps" {
+		path = FrpsPath()
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+	tmp := binver.NewPath(path)
+	name := filepath.Base(src)
+	if IsFrpArchive(name) {
+		err = extractFrpBinary(f, tmp, name, binary)
+	} else {
+		err = copyBinary(f, tmp)
+	}
+	if err != nil {
+		binver.Discard(tmp)
+		return "", err
+	}
+	version := FrpVersion(tmp)
+	if version == "" {
+		binver.Discard(tmp)
+		return "", fmt.Errorf("%s 中的 %s 无法在当前平台运行", src, binary)
+	}
+	return version, binver.Replace(path, tmp)
+}
+
+func copyBinary(r io.Reader, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return err
+	}
+	if runtime.GOOS != "windows" {
+		os.Chmod(dest, 0755)
+	}
+	return nil
+}
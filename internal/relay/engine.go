@@ -54,19 +54,17 @@ func Start() error {
 		return err
 	}
 
-	// 日志重定向到文件
-	logPath := LogFilePath()
-	os.MkdirAll(filepath.Dir(logPath), 0755)
-	logFile, err := os.OpenFile(logPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	// 日志经 cftunnel logpipe 写入轮转日志文件
+	logPipe, err := startLogPipe(LogFilePath())
 	if err != nil {
 		return fmt.Errorf("打开日志文件失败: %w", err)
 	}
+	defer logPipe.Close()
 
 	cmd := exec.Command(binPath, "-c", FrpcConfigPath())
-	cmd.Stdout = logFile
-	cmd.Stderr = logFile
+	cmd.Stdout = logPipe
+	cmd.Stderr = logPipe
 	if err := cmd.Start(); err != nil {
-		logFile.Close()
 		return fmt.Errorf("启动 frpc 失败: %w", err)
 	}
 	os.WriteFile(pidFilePath(), []byte(strconv.Itoa(cmd.Process.Pid)), 0600)
@@ -74,6 +72,29 @@ func Start() error {
 	return nil
 }
 
+// startLogPipe 启动 cftunnel logpipe 子进程，返回其标准输入的写端
+// logpipe 负责按大小 / 时间轮转并压缩日志，frpc 退出（管道关闭）后随之退出
+func startLogPipe(logPath string) (*os.File, error) {
+	self, err := os.Executable()
+	if err != nil {
+		return nil, err
+	}
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	defer pr.Close()
+
+	cmd := exec.Command(self, "logpipe", logPath)
+	cmd.Stdin = pr
+	if err := cmd.Start(); err != nil {
+		pw.Close()
+		return nil, err
+	}
+	go cmd.Wait()
+	return pw, nil
+}
+
 // Stop 停止 frpc
 func Stop() error {
 	pid, err := readPID()
@@ -116,8 +137,8 @@ func readPID() (int, error) {
 	return strconv.Atoi(s)
 }
 
-// StartQuick 前台运行 frpc（quick --relay 模式，Ctrl+C 退出）
-func StartQuick(port, proto string) error {
+// StartQuick 前台运行 frpc（quick --relay 模式，Ctrl+C 退出），每个端口一条临时规则，远程端口与本地端口相同
+func StartQuick(ports []string, proto string) error {
 	binPath, err := EnsureFrpc()
 	if err != nil {
 		return err
@@ -132,25 +153,29 @@ func StartQuick(port, proto string) error {
 	}
 
 	// 创建临时规则
-	portNum, err := strconv.Atoi(port)
-	if err != nil {
-		return fmt.Errorf("端口格式错误: %w", err)
-	}
 	tmpRelay := config.RelayConfig{
 		Server: cfg.Relay.Server,
 		Token:  cfg.Relay.Token,
-		Rules: []config.RelayRule{{
-			Name:       "quick",
+	}
+	for _, port := range ports {
+		portNum, err := strconv.Atoi(port)
+		if err != nil {
+			return fmt.Errorf("端口格式错误: %w", err)
+		}
+		tmpRelay.Rules = append(tmpRelay.Rules, config.RelayRule{
+			Name:       "quick-" + port,
 			Proto:      proto,
 			LocalPort:  portNum,
 			RemotePort: portNum,
-		}},
+		})
 	}
 	if err := GenerateFrpcConfig(&tmpRelay); err != nil {
 		return err
 	}
 
-	fmt.Printf("中继穿透: %s://localhost:%s → 远程端口 %s (%s)\n", proto, port, port, cfg.Relay.Server)
+	for _, port := range ports {
+		fmt.Printf("中继穿透: %s://localhost:%s → 远程端口 %s (%s)\n", proto, port, port, cfg.Relay.Server)
+	}
 
 	cmd := exec.Command(binPath, "-c", FrpcConfigPath())
 	cmd.Stdout = os.Stdout
@@ -7,9 +7,12 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"runtime"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/download"
+	"github.com/qingchencloud/cftunnel/internal/verify"
 )
 
 const repo = "qingchencloud/cftunnel"
@@ -20,7 +23,7 @@ type release struct {
 
 // LatestVersion 查询 GitHub 最新版本
 func LatestVersion() (string, error) {
-	resp, err := http.Get("https://api.github.com/repos/" + repo + "/releases/latest")
+	resp, err := download.Client(30 * time.Second).Get("https://api.github.com/repos/" + repo + "/releases/latest")
 	if err != nil {
 		return "", err
 	}
@@ -35,24 +38,85 @@ func LatestVersion() (string, error) {
 	return r.TagName, nil
 }
 
-// Update 下载最新版本替换自身
+// PublicKey 发布签名公钥（base64 编码的 ed25519 公钥），由维护者用 tools/sign -keygen 生成密钥对后单独提交
+// 为空时无法校验签名，只校验 SHA-256
+var PublicKey = ""
+
+// releaseChecksums 下载 checksums.txt，内置公钥时同时校验其签名 checksums.txt.sig
+// 未签名的版本（签名文件 404）只校验 SHA-256；签名无效时一律拒绝
+func releaseChecksums(version string) (map[string]string, error) {
+	base := fmt.Sprintf("https://github.com/%s/releases/download/%s/", repo, version)
+	sums, data, err := verify.FetchChecksums(base + "checksums.txt")
+	if err != nil {
+		return nil, fmt.Errorf("获取校验文件失败: %w", err)
+	}
+	if PublicKey == "" {
+		fmt.Println("警告: 当前版本未内置发布公钥，仅校验 SHA-256")
+		return sums, nil
+	}
+	sig, err := fetchSignature(base + "checksums.txt.sig")
+	switch {
+	case err != nil && verify.Skip():
+		fmt.Printf("警告: 已设置 %s，跳过发布签名校验（%v），仅校验 SHA-256\n", verify.SkipEnv, err)
+		return sums, nil
+	case err != nil:
+		return nil, fmt.Errorf("%w（无法访问 GitHub 时可设置 %s=1 跳过签名校验，不推荐）", err, verify.SkipEnv)
+	case sig == nil:
+		fmt.Printf("警告: %s 未发布签名文件，仅校验 SHA-256\n", version)
+		return sums, nil
+	}
+	if err := verify.Signature(PublicKey, data, sig); err != nil {
+		return nil, err
+	}
+	return sums, nil
+}
+
+// fetchSignature 下载发布签名，版本未签名（404）时返回 nil
+func fetchSignature(url string) ([]byte, error) {
+	resp, err := download.Client(30 * time.Second).Get(url)
+	if err != nil {
+		return nil, fmt.Errorf("获取发布签名失败: %w", err)
+	}
+	defer resp.Body.Close()
+	switch resp.StatusCode {
+	case 200:
+	case 404:
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("获取发布签名失败: HTTP %d", resp.StatusCode)
+	}
+	sig, err := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	if err != nil {
+		return nil, fmt.Errorf("获取发布签名失败: %w", err)
+	}
+	return sig, nil
+}
+
+// Update 下载最新版本替换自身，校验签名和 SHA-256 后才会替换
 func Update(version string) error {
 	ext := "tar.gz"
 	if runtime.GOOS == "windows" {
 		ext = "zip"
 	}
-	url := fmt.Sprintf("https://github.com/%s/releases/download/%s/cftunnel_%s_%s.%s",
-		repo, version, runtime.GOOS, runtime.GOARCH, ext)
+	asset := fmt.Sprintf("cftunnel_%s_%s.%s", runtime.GOOS, runtime.GOARCH, ext)
+	url := fmt.Sprintf("https://github.com/%s/releases/download/%s/%s", repo, version, asset)
 
-	resp, err := http.Get(url)
+	sums, err := releaseChecksums(version)
 	if err != nil {
-		return fmt.Errorf("下载失败: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
-	if resp.StatusCode != 200 {
-		return fmt.Errorf("下载失败: HTTP %d", resp.StatusCode)
+	want := sums[asset]
+	if want == "" {
+		return fmt.Errorf("校验文件中没有 %s", asset)
 	}
 
+	// 经镜像下载（支持代理与断点续传），SHA-256 不符的镜像会被跳过
+	archive, _, err := download.Fetch(download.File{Name: asset, URL: url, SHA256: want, Key: version})
+	if err != nil {
+		return fmt.Errorf("下载失败: %w", err)
+	}
+	defer download.Remove(archive)
+
 	exe, err := os.Executable()
 	if err != nil {
 		return err
@@ -60,9 +124,9 @@ func Update(version string) error {
 
 	var binData io.Reader
 	if runtime.GOOS == "windows" {
-		binData, err = extractZip(resp.Body)
+		binData, err = extractZip(archive)
 	} else {
-		binData, err = extractTarGz(resp.Body)
+		binData, err = extractTarGz(archive)
 	}
 	if err != nil {
 		return err
@@ -88,7 +152,11 @@ func Update(version string) error {
 		os.Remove(old)
 		os.Rename(exe, old)
 	}
-	return os.Rename(tmp, exe)
+	if err := os.Rename(tmp, exe); err != nil {
+		return err
+	}
+	verify.Record(exe)
+	return nil
 }
 
 func extractTarGz(r io.Reader) (io.Reader, error) {
@@ -3,78 +3,210 @@
 package service
 
 import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"text/template"
+	"time"
 )
 
-type Launchd struct{}
+// launchd 服务注册为当前用户的 LaunchAgent（~/Library/LaunchAgents），无需 root
+type launchd struct{}
 
-const plistName = "com.cftunnel.cloudflared"
-
-func (l *Launchd) plistPath() string {
+func (launchd) plistPath(u unit) string {
 	home, _ := os.UserHomeDir()
-	return filepath.Join(home, "Library/LaunchAgents", plistName+".plist")
+	return filepath.Join(home, "Library/LaunchAgents", u.label+".plist")
 }
 
-const plistTmpl = `<?xml version="1.0" encoding="UTF-8"?>
+// plist 中的参数、环境变量等均经 XML 转义，含 & < > 的值不会破坏文件结构
+var plistTmpl = template.Must(template.New("plist").Funcs(template.FuncMap{"xml": xmlEscape}).Parse(`<?xml version="1.0" encoding="UTF-8"?>
 <!DOCTYPE plist PUBLIC "-//Apple//DTD PLIST 1.0//EN" "http://www.apple.com/DTDs/PropertyList-1.0.dtd">
 <plist version="1.0">
 <dict>
     <key>Label</key>
-    <string>{{.Label}}</string>
+    <string>{{xml .Label}}</string>
     <key>ProgramArguments</key>
     <array>
-        <string>{{.BinPath}}</string>
-        <string>tunnel</string>
-        <string>--protocol</string>
-        <string>http2</string>
-        <string>run</string>
-        <string>--token</string>
-        <string>{{.Token}}</string>
+        <string>{{xml .BinPath}}</string>
+{{- range .Args}}
+        <string>{{xml .}}</string>
+{{- end}}
     </array>
+{{- if .Env}}
+    <key>EnvironmentVariables</key>
+    <dict>
+{{- range $k, $v := .Env}}
+        <key>{{xml $k}}</key>
+        <string>{{xml $v}}</string>
+{{- end}}
+    </dict>
+{{- end}}
     <key>KeepAlive</key>
     <true/>
     <key>RunAtLoad</key>
     <true/>
     <key>StandardOutPath</key>
-    <string>{{.LogPath}}</string>
+    <string>{{xml .LogPath}}</string>
     <key>StandardErrorPath</key>
-    <string>{{.LogPath}}</string>
+    <string>{{xml .LogPath}}</string>
 </dict>
 </plist>
-`
+`))
+
+func xmlEscape(s string) string {
+	var b strings.Builder
+	xml.EscapeText(&b, []byte(s))
+	return b.String()
+}
 
-func (l *Launchd) Install(binPath, token string) error {
+func (l launchd) install(u unit) error {
 	home, _ := os.UserHomeDir()
-	data := map[string]string{
-		"Label":   plistName,
-		"BinPath": binPath,
-		"Token":   token,
-		"LogPath": filepath.Join(home, "Library/Logs/cftunnel.log"),
+	data := map[string]any{
+		"Label":   u.label,
+		"BinPath": u.binPath,
+		"Args":    u.args,
+		"Env":     u.env,
+		"LogPath": filepath.Join(home, "Library/Logs", u.logName),
 	}
-	f, err := os.Create(l.plistPath())
-	if err != nil {
+	var buf bytes.Buffer
+	if err := plistTmpl.Execute(&buf, data); err != nil {
 		return err
 	}
-	defer f.Close()
-	if err := template.Must(template.New("").Parse(plistTmpl)).Execute(f, data); err != nil {
+	path := l.plistPath(u)
+	// 已加载的旧定义先卸载，新定义才会生效
+	if _, err := os.Stat(path); err == nil {
+		exec.Command("launchctl", "unload", path).Run()
+	}
+	// plist 可能含令牌，仅当前用户可读
+	if err := writePrivate(path, buf.String()); err != nil {
 		return err
 	}
-	return exec.Command("launchctl", "load", l.plistPath()).Run()
+	return run("launchctl", "load", path)
 }
 
-func (l *Launchd) Uninstall() error {
-	exec.Command("launchctl", "unload", l.plistPath()).Run()
-	return os.Remove(l.plistPath())
+func (l launchd) uninstall(u unit) error {
+	exec.Command("launchctl", "unload", l.plistPath(u)).Run()
+	return os.Remove(l.plistPath(u))
+}
+
+func (l launchd) snapshot(u unit) (func() error, error) {
+	files, err := saveFiles(l.plistPath(u))
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := restoreFiles(files); err != nil {
+			return err
+		}
+		return run("launchctl", "load", l.plistPath(u))
+	}, nil
 }
 
-func (l *Launchd) Running() bool {
-	out, err := exec.Command("launchctl", "list", plistName).Output()
+func (launchd) running(u unit) bool {
+	out, err := exec.Command("launchctl", "list", u.label).Output()
 	return err == nil && len(out) > 0
 }
 
+// target launchctl 的服务标识 gui/<uid>/<label>
+func (launchd) target(u unit) string {
+	return fmt.Sprintf("gui/%d/%s", os.Getuid(), u.label)
+}
+
+func (l launchd) restart(u unit) error {
+	// kickstart -k 结束当前实例并立即重新拉起
+	return run("launchctl", "kickstart", "-k", l.target(u))
+}
+
+func (l launchd) definition(u unit) string {
+	data, _ := os.ReadFile(l.plistPath(u))
+	return string(data)
+}
+
+func (l launchd) status(u unit) (Status, error) {
+	st := Status{Backend: "launchd"}
+	if _, err := os.Stat(l.plistPath(u)); err != nil {
+		return st, nil
+	}
+	st.Installed = true
+	out, err := exec.Command("launchctl", "print", l.target(u)).Output()
+	if err != nil {
+		// plist 存在但未加载，登录时不会启动
+		st.Active = "not loaded"
+		return st, nil
+	}
+	st.Enabled = true
+	// 只取顶层属性（一个制表符缩进），嵌套字典中有同名键
+	props := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if !strings.HasPrefix(line, "\t") || strings.HasPrefix(line, "\t\t") {
+			continue
+		}
+		if k, v, ok := strings.Cut(strings.TrimSpace(line), " = "); ok {
+			props[k] = v
+		}
+	}
+	st.Active = props["state"]
+	st.Running = st.Active == "running"
+	st.PID, _ = strconv.Atoi(props["pid"])
+	if runs, err := strconv.Atoi(props["runs"]); err == nil && runs > 0 {
+		restarts := runs - 1
+		st.Restarts = &restarts
+	}
+	// 从未退出时为 "(never exited)"
+	if code, err := strconv.Atoi(props["last exit code"]); err == nil {
+		st.ExitCode = &code
+	}
+	if st.PID > 0 {
+		if out, err := exec.Command("ps", "-o", "etime=", "-p", strconv.Itoa(st.PID)).Output(); err == nil {
+			if elapsed, ok := parseEtime(strings.TrimSpace(string(out))); ok {
+				st.StartedAt = time.Now().Add(-elapsed)
+			}
+		}
+	}
+	return st, nil
+}
+
+// parseEtime 解析 ps 的 etime 格式 [[dd-]hh:]mm:ss
+func parseEtime(s string) (time.Duration, bool) {
+	var days int
+	if d, rest, ok := strings.Cut(s, "-"); ok {
+		n, err := strconv.Atoi(d)
+		if err != nil {
+			return 0, false
+		}
+		days, s = n, rest
+	}
+	var secs int
+	for _, part := range strings.Split(s, ":") {
+		n, err := strconv.Atoi(part)
+		if err != nil {
+			return 0, false
+		}
+		secs = secs*60 + n
+	}
+	return time.Duration(days*86400+secs) * time.Second, true
+}
+
+func (launchd) logs(u unit, n int) ([]string, error) {
+	home, _ := os.UserHomeDir()
+	return tailLog(filepath.Join(home, "Library/Logs", u.logName), n)
+}
+
 func New() Service {
-	return &Launchd{}
+	return &manager{b: launchd{}}
+}
+
+// NewUser LaunchAgent 本身即为用户级服务，与 New 相同
+func NewUser() Service {
+	return New()
+}
+
+// UserHint macOS 无需额外设置
+func UserHint() string {
+	return ""
 }
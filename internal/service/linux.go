@@ -0,0 +1,70 @@
+//go:build linux
+
+package service
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/user"
+	"strings"
+)
+
+// New 探测当前 init 系统（systemd → OpenRC → runit）并返回对应的服务管理，
+// systemd 下已用 --user 注册的服务会自动按用户级管理
+func New() Service {
+	return &manager{b: detect()}
+}
+
+// NewUser 返回当前用户级的服务管理（systemd --user），注册时无需 root
+func NewUser() Service {
+	if !systemdBooted() {
+		return &manager{b: unsupported{errors.New("--user 模式需要 systemd，当前 init 系统不支持用户级服务")}}
+	}
+	return &manager{b: &systemd{user: true}}
+}
+
+func detect() backend {
+	switch {
+	case systemdBooted():
+		return &systemd{}
+	case openrcBooted():
+		return openrc{}
+	}
+	if dir := runitServiceDir(); dir != "" && hasCommand("sv") {
+		return runit{svDir: dir}
+	}
+	return unsupported{errors.New("未检测到受支持的 init 系统（systemd / OpenRC / runit），请使用 cftunnel up 手动启动")}
+}
+
+// systemdBooted 与 sd_booted() 相同：以 systemd 启动的系统存在 /run/systemd/system
+func systemdBooted() bool {
+	_, err := os.Stat("/run/systemd/system")
+	return err == nil
+}
+
+func openrcBooted() bool {
+	if _, err := os.Stat("/run/openrc"); err == nil {
+		return true
+	}
+	return hasCommand("rc-service") && hasCommand("openrc-run")
+}
+
+func hasCommand(name string) bool {
+	_, err := exec.LookPath(name)
+	return err == nil
+}
+
+// UserHint 用户级服务的注意事项：未开启 linger 时提示执行 loginctl enable-linger，无需提示时为空
+func UserHint() string {
+	u, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	out, err := exec.Command("loginctl", "show-user", u.Username, "--property=Linger", "--value").Output()
+	if err == nil && strings.TrimSpace(string(out)) == "yes" {
+		return ""
+	}
+	return fmt.Sprintf("用户级服务默认在登录后才启动、注销后停止，如需开机自启并常驻请执行: sudo loginctl enable-linger %s", u.Username)
+}
@@ -0,0 +1,158 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+// openrc Alpine / Gentoo 等使用 OpenRC 的系统：/etc/init.d 脚本由 supervise-daemon 守护，
+// 令牌写入 /etc/conf.d/<服务名>（0600，由 openrc-run 自动加载）；
+// supervise-daemon 只能分别重定向 stdout / stderr，进程经 cftunnel logpipe 启动，输出写入按大小轮转的 /var/log/<日志名>
+type openrc struct{}
+
+func (openrc) scriptPath(u unit) string { return "/etc/init.d/" + u.name }
+func (openrc) confPath(u unit) string   { return "/etc/conf.d/" + u.name }
+
+func (o openrc) install(u unit) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("注册 OpenRC 服务需要 root 权限，请使用 sudo 执行")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	args := append([]string{"logpipe", "/var/log/" + u.logName, "--", u.binPath}, u.args...)
+	var b strings.Builder
+	b.WriteString("#!/sbin/openrc-run\n\n")
+	fmt.Fprintf(&b, "description=%s\n", shellQuote(u.description))
+	b.WriteString("supervisor=supervise-daemon\n")
+	fmt.Fprintf(&b, "command=%s\n", shellQuote(self))
+	fmt.Fprintf(&b, "command_args=\"%s\"\n", dquoteEscape(shellArgs(args)))
+	b.WriteString("respawn_delay=5\nrespawn_max=0\n\n")
+	b.WriteString("depend() {\n\tneed net\n")
+	if u.before != "" {
+		fmt.Fprintf(&b, "\tbefore %s\n", u.before)
+	}
+	b.WriteString("}\n")
+
+	if len(u.env) > 0 {
+		if err := os.MkdirAll("/etc/conf.d", 0755); err != nil {
+			return err
+		}
+		var conf strings.Builder
+		for _, kv := range envList(u.env) {
+			k, v, _ := strings.Cut(kv, "=")
+			fmt.Fprintf(&conf, "export %s=%s\n", k, shellQuote(v))
+		}
+		if err := writePrivate(o.confPath(u), conf.String()); err != nil {
+			return err
+		}
+	}
+	if err := writeFile(o.scriptPath(u), []byte(b.String()), 0755); err != nil {
+		return err
+	}
+	if err := run("rc-update", "add", u.name, "default"); err != nil {
+		return err
+	}
+	// 未运行时 restart 等同于 start
+	return run("rc-service", u.name, "restart")
+}
+
+func (o openrc) uninstall(u unit) error {
+	exec.Command("rc-service", u.name, "stop").Run()
+	exec.Command("rc-update", "del", u.name, "default").Run()
+	os.Remove(o.confPath(u))
+	return os.Remove(o.scriptPath(u))
+}
+
+func (o openrc) snapshot(u unit) (func() error, error) {
+	st, _ := o.status(u)
+	files, err := saveFiles(o.scriptPath(u), o.confPath(u))
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := restoreFiles(files); err != nil {
+			return err
+		}
+		if st.Enabled {
+			if err := run("rc-update", "add", u.name, "default"); err != nil {
+				return err
+			}
+		}
+		return run("rc-service", u.name, "restart")
+	}, nil
+}
+
+func (openrc) running(u unit) bool {
+	return exec.Command("rc-service", u.name, "status").Run() == nil
+}
+
+func (openrc) restart(u unit) error {
+	return run("rc-service", u.name, "restart")
+}
+
+func (o openrc) definition(u unit) string {
+	data, _ := os.ReadFile(o.scriptPath(u))
+	return string(data)
+}
+
+func (o openrc) status(u unit) (Status, error) {
+	st := Status{Backend: "openrc"}
+	if _, err := os.Stat(o.scriptPath(u)); err != nil {
+		return st, nil
+	}
+	st.Installed = true
+	if out, err := exec.Command("rc-update", "show", "default").Output(); err == nil {
+		for _, line := range strings.Split(string(out), "\n") {
+			if name, _, _ := strings.Cut(strings.TrimSpace(line), " "); name == u.name {
+				st.Enabled = true
+			}
+		}
+	}
+	// 输出形如 " * status: started"，已停止时退出码非 0
+	out, _ := exec.Command("rc-service", u.name, "status").CombinedOutput()
+	if _, state, ok := strings.Cut(string(out), "status:"); ok {
+		st.Active = strings.TrimSpace(state)
+	}
+	st.Running = st.Active == "started"
+	if !st.Running {
+		return st, nil
+	}
+	// supervise-daemon 记录的子进程 PID，服务启动时创建 started 下的链接
+	if data, err := os.ReadFile("/run/openrc/options/" + u.name + "/child_pid"); err == nil {
+		st.PID, _ = strconv.Atoi(strings.TrimSpace(string(data)))
+	}
+	if info, err := os.Lstat("/run/openrc/started/" + u.name); err == nil {
+		st.StartedAt = info.ModTime()
+	}
+	return st, nil
+}
+
+func (openrc) logs(u unit, n int) ([]string, error) {
+	return tailLog("/var/log/"+u.logName, n)
+}
+
+// shellQuote 用单引号包裹参数，供 sh 原样解析
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// dquoteEscape 转义 sh 双引号字符串中的特殊字符
+func dquoteEscape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "$", `\$`, "`", "\\`").Replace(s)
+}
+
+// shellArgs 将参数逐个加单引号后拼接（openrc-run 会 eval command_args）
+func shellArgs(args []string) string {
+	quoted := make([]string, len(args))
+	for i, a := range args {
+		quoted[i] = shellQuote(a)
+	}
+	return strings.Join(quoted, " ")
+}
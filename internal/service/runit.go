@@ -0,0 +1,185 @@
+//go:build linux
+
+package service
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// runit Void / Artix 等使用 runit 的系统：服务目录写入 /etc/sv/<服务名>，链接到 runsvdir 监视的目录后自动启动，
+// 令牌写入 chpst 环境目录 env/（0700），输出由 log/run 中的 svlogd 写入 /var/log/<服务名>/current 并按大小轮转
+type runit struct {
+	svDir string // runsvdir 监视的目录，如 /var/service
+}
+
+// runitServiceDirs runsvdir 常见的监视目录（Void / Debian / Artix）
+var runitServiceDirs = []string{"/var/service", "/etc/service", "/run/runit/service", "/service"}
+
+// runitServiceDir 返回存在的 runsvdir 监视目录，未找到时为空
+func runitServiceDir() string {
+	for _, dir := range runitServiceDirs {
+		if info, err := os.Stat(dir); err == nil && info.IsDir() {
+			return dir
+		}
+	}
+	return ""
+}
+
+func (runit) defPath(u unit) string    { return "/etc/sv/" + u.name }
+func (runit) logDir(u unit) string     { return "/var/log/" + u.name }
+func (r runit) linkPath(u unit) string { return filepath.Join(r.svDir, u.name) }
+
+func (r runit) install(u unit) error {
+	if os.Geteuid() != 0 {
+		return fmt.Errorf("注册 runit 服务需要 root 权限，请使用 sudo 执行")
+	}
+	dir := r.defPath(u)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	command := shellArgs(append([]string{u.binPath}, u.args...))
+	envDir := filepath.Join(dir, "env")
+	os.RemoveAll(envDir)
+	if len(u.env) > 0 {
+		if err := os.Mkdir(envDir, 0700); err != nil {
+			return err
+		}
+		for _, kv := range envList(u.env) {
+			k, v, _ := strings.Cut(kv, "=")
+			if err := writePrivate(filepath.Join(envDir, k), v); err != nil {
+				return err
+			}
+		}
+		command = "chpst -e ./env " + command
+	}
+	script := fmt.Sprintf("#!/bin/sh\n# %s\nexec 2>&1\nexec %s\n", u.description, command)
+	if err := writeFile(filepath.Join(dir, "run"), []byte(script), 0755); err != nil {
+		return err
+	}
+	// svlogd 默认单文件 1MB、保留 10 个
+	if err := os.MkdirAll(filepath.Join(dir, "log"), 0755); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(r.logDir(u), 0750); err != nil {
+		return err
+	}
+	logScript := fmt.Sprintf("#!/bin/sh\nexec svlogd -tt %s\n", r.logDir(u))
+	if err := writeFile(filepath.Join(dir, "log", "run"), []byte(logScript), 0755); err != nil {
+		return err
+	}
+
+	link := r.linkPath(u)
+	if _, err := os.Lstat(link); err == nil {
+		if _, err := os.Stat(filepath.Join(dir, "log", "supervise")); err != nil {
+			// 旧版定义没有 log/，runsv 只在启动时检查日志服务：令其退出，由 runsvdir 下次扫描时重新拉起
+			return run("sv", "exit", link)
+		}
+		return run("sv", "restart", link)
+	}
+	if err := os.Symlink(dir, link); err != nil {
+		return err
+	}
+	// runsvdir 约 5 秒扫描一次，等待 runsv 接管后服务即已启动
+	for i := 0; i < 20; i++ {
+		if _, err := os.Stat(filepath.Join(link, "supervise/ok")); err == nil {
+			return nil
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+	return fmt.Errorf("等待 runsv 接管 %s 超时，请检查 runsvdir 是否在监视 %s", u.name, r.svDir)
+}
+
+func (r runit) uninstall(u unit) error {
+	link := r.linkPath(u)
+	exec.Command("sv", "down", link).Run()
+	// 删除链接后 runsvdir 会结束对应的 runsv
+	os.Remove(link)
+	if _, err := os.Stat(r.defPath(u)); err != nil {
+		return err
+	}
+	return os.RemoveAll(r.defPath(u))
+}
+
+func (r runit) snapshot(u unit) (func() error, error) {
+	dir := r.defPath(u)
+	envFiles, _ := filepath.Glob(filepath.Join(dir, "env", "*"))
+	files, err := saveFiles(append([]string{filepath.Join(dir, "run"), filepath.Join(dir, "log", "run")}, envFiles...)...)
+	if err != nil {
+		return nil, err
+	}
+	_, linkErr := os.Lstat(r.linkPath(u))
+	linked := linkErr == nil
+	return func() error {
+		os.RemoveAll(filepath.Join(dir, "env"))
+		if len(envFiles) > 0 {
+			if err := os.MkdirAll(filepath.Join(dir, "env"), 0700); err != nil {
+				return err
+			}
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "log"), 0755); err != nil {
+			return err
+		}
+		if err := restoreFiles(files); err != nil {
+			return err
+		}
+		if !linked {
+			return nil
+		}
+		// 重新链接后由 runsvdir 自动启动
+		return os.Symlink(dir, r.linkPath(u))
+	}, nil
+}
+
+func (r runit) running(u unit) bool {
+	out, err := exec.Command("sv", "status", r.linkPath(u)).Output()
+	return err == nil && strings.HasPrefix(string(out), "run:")
+}
+
+func (r runit) restart(u unit) error {
+	return run("sv", "restart", r.linkPath(u))
+}
+
+func (r runit) definition(u unit) string {
+	data, _ := os.ReadFile(filepath.Join(r.defPath(u), "run"))
+	return string(data)
+}
+
+// svStatusRe 解析 sv status 输出，如 "run: /var/service/cftunnel: (pid 123) 45s" 或 "down: /var/service/cftunnel: 3s, normally up"
+var svStatusRe = regexp.MustCompile(`^(\w+): [^:]+: (?:\(pid (\d+)\) )?(\d+)s`)
+
+func (r runit) status(u unit) (Status, error) {
+	st := Status{Backend: "runit"}
+	if _, err := os.Stat(r.defPath(u)); err != nil {
+		return st, nil
+	}
+	st.Installed = true
+	// 链接到监视目录即开机启动，服务目录中的 down 文件表示默认不启动
+	if _, err := os.Lstat(r.linkPath(u)); err == nil {
+		_, err := os.Stat(filepath.Join(r.defPath(u), "down"))
+		st.Enabled = err != nil
+	}
+	out, _ := exec.Command("sv", "status", r.linkPath(u)).Output()
+	m := svStatusRe.FindStringSubmatch(string(out))
+	if m == nil {
+		return st, nil
+	}
+	st.Active = m[1]
+	st.Running = m[1] == "run"
+	st.PID, _ = strconv.Atoi(m[2])
+	if secs, err := strconv.Atoi(m[3]); err == nil && st.Running {
+		st.StartedAt = time.Now().Add(-time.Duration(secs) * time.Second)
+	}
+	return st, nil
+}
+
+func (r runit) logs(u unit, n int) ([]string, error) {
+	return tailLog(filepath.Join(r.logDir(u), "current"), n)
+}
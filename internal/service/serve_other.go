@@ -0,0 +1,22 @@
+//go:build !windows
+
+package service
+
+import (
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// Serve 运行常驻进程，收到 SIGINT / SIGTERM 时关闭 stop
+// run 在 stop 关闭后应尽快返回
+func Serve(_ string, run func(stop <-chan struct{}) error) error {
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt, syscall.SIGTERM)
+	go func() {
+		<-sig
+		close(stop)
+	}()
+	return run(stop)
+}
@@ -0,0 +1,64 @@
+//go:build windows
+
+package service
+
+import (
+	"os"
+	"os/signal"
+
+	"golang.org/x/sys/windows/svc"
+)
+
+// Serve 运行常驻进程：由服务管理器（SCM）启动时响应停止请求，否则等待 Ctrl+C
+// run 在 stop 关闭后应尽快返回
+func Serve(name string, run func(stop <-chan struct{}) error) error {
+	if ok, _ := svc.IsWindowsService(); ok {
+		h := &handler{run: run}
+		if err := svc.Run(name, h); err != nil {
+			return err
+		}
+		return h.err
+	}
+	stop := make(chan struct{})
+	sig := make(chan os.Signal, 1)
+	signal.Notify(sig, os.Interrupt)
+	go func() {
+		<-sig
+		close(stop)
+	}()
+	return run(stop)
+}
+
+type handler struct {
+	run func(stop <-chan struct{}) error
+	err error
+}
+
+func (h *handler) Execute(_ []string, req <-chan svc.ChangeRequest, status chan<- svc.Status) (bool, uint32) {
+	status <- svc.Status{State: svc.StartPending}
+	stop := make(chan struct{})
+	done := make(chan error, 1)
+	go func() { done <- h.run(stop) }()
+	status <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+
+	for {
+		select {
+		case c := <-req:
+			switch c.Cmd {
+			case svc.Interrogate:
+				status <- c.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				status <- svc.Status{State: svc.StopPending}
+				close(stop)
+				h.err = <-done
+				return false, 0
+			}
+		case h.err = <-done:
+			// run 自行退出（如启动失败），返回非零退出码让 SCM 记录
+			if h.err != nil {
+				return false, 1
+			}
+			return false, 0
+		}
+	}
+}
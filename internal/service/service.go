@@ -1,8 +1,306 @@
 package service
 
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/logfile"
+)
+
 // Service 系统服务管理接口
 type Service interface {
-	Install(binPath, token string) error
+	// Install 注册 cloudflared 服务，args 为 daemon.TunnelArgs 生成的运行参数，
+	// token 以 TUNNEL_TOKEN 环境变量注入（仅 root / 当前用户可读），不写入命令行
+	Install(binPath string, args []string, token string) error
+	// Reinstall 先卸载已注册的服务（清除残留定义）再重新注册，参数同 Install；
+	// 新服务注册失败时恢复原服务定义
+	Reinstall(binPath string, args []string, token string) error
 	Uninstall() error
 	Running() bool
+	Restart() error // 重启 cloudflared 服务（如升级二进制后）
+	Status() (Status, error)
+	// Logs 返回服务自身日志（journal 或 init 系统写入的日志文件）的最后 n 行
+	Logs(n int) ([]string, error)
+	// Legacy 已注册的服务是否为旧版定义（令牌写在命令行参数中），需重新 install 迁移
+	Legacy() bool
+
+	// 鉴权代理 sidecar（cftunnel authproxy serve），与 cloudflared 一同注册
+	InstallAuthProxy(selfPath, configDir string) error
+	UninstallAuthProxy() error
+	AuthProxyRunning() bool
+	AuthProxyStatus() (Status, error)
+
+	// 中继客户端（frpc -c <configPath>）
+	InstallRelay(binPath, configPath string) error
+	UninstallRelay() error
+	RelayRunning() bool
+	RestartRelay() error
+	RelayStatus() (Status, error)
+}
+
+// Status 系统服务状态，init 系统无法提供的字段为零值或空
+type Status struct {
+	Backend   string    `json:"backend"`        // systemd / openrc / runit / launchd / windows
+	User      bool      `json:"user,omitempty"` // systemd 用户级服务（install --user）
+	Installed bool      `json:"installed"`
+	Enabled   bool      `json:"enabled"` // 开机自启
+	Running   bool      `json:"running"`
+	Active    string    `json:"active,omitempty"` // init 系统报告的原始状态，如 active (running)、started、STOPPED
+	PID       int       `json:"pid,omitempty"`
+	StartedAt time.Time `json:"started_at,omitzero"`
+	Uptime    int64     `json:"uptime_seconds,omitempty"`
+	Restarts  *int      `json:"restarts,omitempty"`       // 服务管理器自动重启的次数
+	ExitCode  *int      `json:"last_exit_code,omitempty"` // 主进程上次退出码
+}
+
+// unit 一个系统服务的定义，由各 init 系统的 backend 转换为对应格式
+type unit struct {
+	name        string // systemd / OpenRC / runit / Windows 服务名
+	label       string // launchd 标签
+	description string
+	logName     string // 无 journal 的 init 系统（launchd / OpenRC / runit）写入的日志文件名
+	before      string // 需先于该服务启动（鉴权代理先于隧道）
+
+	binPath string
+	args    []string
+	env     map[string]string // 含令牌，写入仅 root / 当前用户可读的文件
+}
+
+var (
+	tunnelUnit = unit{
+		name:        "cftunnel",
+		label:       "com.cftunnel.cloudflared",
+		description: "Cloudflare Tunnel (cftunnel)",
+		logName:     "cftunnel.log",
+	}
+	authProxyUnit = unit{
+		name:        "cftunnel-authproxy",
+		label:       "com.cftunnel.authproxy",
+		description: "cftunnel auth proxy",
+		logName:     "cftunnel-authproxy.log",
+		before:      "cftunnel",
+	}
+	relayUnit = unit{
+		name:        "cftunnel-relay",
+		label:       "com.cftunnel.frpc",
+		description: "cftunnel relay (frpc)",
+		logName:     "cftunnel-relay.log",
+	}
+)
+
+// backend 各 init 系统（systemd / OpenRC / runit / launchd / Windows SCM）的服务操作
+type backend interface {
+	// install 写入服务定义并启动，已注册时覆盖定义并重启
+	install(u unit) error
+	uninstall(u unit) error
+	running(u unit) bool
+	restart(u unit) error
+	// definition 返回已注册的服务定义（单元文件、脚本或 sc qc 输出），未注册时为空
+	definition(u unit) string
+	status(u unit) (Status, error)
+	logs(u unit, n int) ([]string, error)
+	// snapshot 保存已注册的服务定义，restore 在其被卸载后重新注册并启动（Reinstall 失败时回滚）
+	snapshot(u unit) (restore func() error, err error)
+}
+
+// manager 在 backend 之上实现 Service
+type manager struct {
+	b backend
+}
+
+// install 注册服务，首次注册失败时清理已写入的定义，避免留下无法启动的半成品服务
+func (m *manager) install(u unit) error {
+	existed := m.b.definition(u) != ""
+	if err := m.b.install(u); err != nil {
+		if !existed {
+			m.b.uninstall(u)
+		}
+		return err
+	}
+	return nil
+}
+
+// status 查询服务状态并计算运行时长
+func (m *manager) status(u unit) (Status, error) {
+	st, err := m.b.status(u)
+	if err == nil && st.Running && !st.StartedAt.IsZero() {
+		st.Uptime = int64(time.Since(st.StartedAt).Seconds())
+	}
+	return st, err
+}
+
+func (m *manager) Install(binPath string, args []string, token string) error {
+	u := tunnelUnit
+	u.binPath, u.args = binPath, args
+	u.env = map[string]string{"TUNNEL_TOKEN": token}
+	return m.install(u)
+}
+
+func (m *manager) Reinstall(binPath string, args []string, token string) error {
+	if m.b.definition(tunnelUnit) == "" {
+		return m.Install(binPath, args, token)
+	}
+	restore, err := m.b.snapshot(tunnelUnit)
+	if err != nil {
+		return fmt.Errorf("备份原服务定义失败: %w", err)
+	}
+	if err := m.b.uninstall(tunnelUnit); err != nil {
+		return fmt.Errorf("卸载旧服务失败: %w", err)
+	}
+	// 此时已无定义，Install 失败会清理新写入的部分，随后恢复原服务
+	if err := m.Install(binPath, args, token); err != nil {
+		if rerr := restore(); rerr != nil {
+			return fmt.Errorf("%w（恢复原服务失败: %v）", err, rerr)
+		}
+		return fmt.Errorf("%w（已恢复原服务）", err)
+	}
+	return nil
+}
+
+func (m *manager) Uninstall() error             { return m.b.uninstall(tunnelUnit) }
+func (m *manager) Running() bool                { return m.b.running(tunnelUnit) }
+func (m *manager) Restart() error               { return m.b.restart(tunnelUnit) }
+func (m *manager) Status() (Status, error)      { return m.status(tunnelUnit) }
+func (m *manager) Logs(n int) ([]string, error) { return m.b.logs(tunnelUnit, n) }
+
+func (m *manager) Legacy() bool {
+	return strings.Contains(m.b.definition(tunnelUnit), "--token")
+}
+
+func (m *manager) InstallAuthProxy(selfPath, configDir string) error {
+	u := authProxyUnit
+	u.binPath, u.args = selfPath, []string{"authproxy", "serve", "--config-dir", configDir}
+	return m.install(u)
+}
+
+func (m *manager) UninstallAuthProxy() error        { return m.b.uninstall(authProxyUnit) }
+func (m *manager) AuthProxyRunning() bool           { return m.b.running(authProxyUnit) }
+func (m *manager) AuthProxyStatus() (Status, error) { return m.status(authProxyUnit) }
+
+func (m *manager) InstallRelay(binPath, configPath string) error {
+	u := relayUnit
+	u.binPath, u.args = binPath, []string{"-c", configPath}
+	return m.install(u)
+}
+
+func (m *manager) UninstallRelay() error        { return m.b.uninstall(relayUnit) }
+func (m *manager) RelayRunning() bool           { return m.b.running(relayUnit) }
+func (m *manager) RestartRelay() error          { return m.b.restart(relayUnit) }
+func (m *manager) RelayStatus() (Status, error) { return m.status(relayUnit) }
+
+// unsupported 当前系统没有可用的 init 系统时，所有操作返回 err
+type unsupported struct {
+	err error
+}
+
+func (s unsupported) install(unit) error               { return s.err }
+func (s unsupported) uninstall(unit) error             { return s.err }
+func (s unsupported) running(unit) bool                { return false }
+func (s unsupported) restart(unit) error               { return s.err }
+func (s unsupported) definition(unit) string           { return "" }
+func (s unsupported) status(unit) (Status, error)      { return Status{}, s.err }
+func (s unsupported) logs(unit, int) ([]string, error) { return nil, s.err }
+func (s unsupported) snapshot(unit) (func() error, error) {
+	return nil, s.err
+}
+
+// envList 按变量名排序返回 KEY=VALUE 列表
+func envList(env map[string]string) []string {
+	list := make([]string, 0, len(env))
+	for k, v := range env {
+		list = append(list, k+"="+v)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// run 执行服务管理命令，失败时附带命令输出便于排查
+func run(name string, args ...string) error {
+	out, err := exec.Command(name, args...).CombinedOutput()
+	if err != nil {
+		if msg := strings.TrimSpace(string(out)); msg != "" {
+			return fmt.Errorf("%s %s: %s", name, strings.Join(args, " "), msg)
+		}
+		return fmt.Errorf("%s %s: %w", name, strings.Join(args, " "), err)
+	}
+	return nil
+}
+
+// tailLog 返回服务日志文件的最后 n 行
+func tailLog(path string, n int) ([]string, error) {
+	raw := func(line string) (logfile.Entry, bool) { return logfile.Entry{Line: line}, true }
+	entries, err := logfile.Tail(path, n, raw, nil, time.Time{})
+	if err != nil {
+		return nil, err
+	}
+	lines := make([]string, len(entries))
+	for i, e := range entries {
+		lines[i] = e.Line
+	}
+	return lines, nil
+}
+
+// writeFile 先写临时文件再重命名，中途失败不会留下写了一半的服务定义
+func writeFile(path string, data []byte, perm os.FileMode) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	// 临时文件可能是上次遗留的，重新设置权限
+	if err := os.Chmod(tmp, perm); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// savedFile 服务定义文件的快照，data 为 nil 表示原先不存在
+type savedFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+// saveFiles 读取服务定义文件，供回滚时写回
+func saveFiles(paths ...string) ([]savedFile, error) {
+	files := make([]savedFile, 0, len(paths))
+	for _, path := range paths {
+		info, err := os.Stat(path)
+		if os.IsNotExist(err) {
+			files = append(files, savedFile{path: path})
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		files = append(files, savedFile{path: path, data: data, perm: info.Mode().Perm()})
+	}
+	return files, nil
+}
+
+// restoreFiles 写回快照中的文件，原先不存在的删除
+func restoreFiles(files []savedFile) error {
+	for _, f := range files {
+		if f.data == nil {
+			os.Remove(f.path)
+			continue
+		}
+		if err := writeFile(f.path, f.data, f.perm); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writePrivate 以 0600 权限写入含令牌的文件
+func writePrivate(path, content string) error {
+	return writeFile(path, []byte(content), 0600)
 }
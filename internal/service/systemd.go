@@ -6,48 +6,238 @@ import (
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
 )
 
-type Systemd struct{}
+// systemd 系统级单元写入 /etc/systemd/system（需要 root），
+// 用户级单元写入 ~/.config/systemd/user，通过 systemctl --user 管理
+type systemd struct {
+	user bool // 新注册的服务使用用户级单元（install --user）
+}
 
-const unitName = "cftunnel"
+// userScope 服务是否为用户级：注册时按 --user，已注册的按单元文件所在位置判断
+func (s *systemd) userScope(u unit) bool {
+	if s.user {
+		return true
+	}
+	if _, err := os.Stat(s.unitPath(u, false)); err == nil {
+		return false
+	}
+	_, err := os.Stat(s.unitPath(u, true))
+	return err == nil
+}
 
-func (s *Systemd) unitPath() string {
-	return "/etc/systemd/system/" + unitName + ".service"
+func userConfigDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		home, _ := os.UserHomeDir()
+		dir = filepath.Join(home, ".config")
+	}
+	return dir
 }
 
-func (s *Systemd) Install(binPath, token string) error {
-	unit := fmt.Sprintf(`[Unit]
-Description=Cloudflare Tunnel (cftunnel)
-After=network.target
+func (s *systemd) unitPath(u unit, user bool) string {
+	if user {
+		return filepath.Join(userConfigDir(), "systemd/user", u.name+".service")
+	}
+	return "/etc/systemd/system/" + u.name + ".service"
+}
 
-[Service]
-ExecStart=%s tunnel --protocol http2 run --token %s
-Restart=always
-RestartSec=5
+// envPath 令牌环境文件（0600），单元文件本身所有用户可读
+func (s *systemd) envPath(u unit, user bool) string {
+	if user {
+		return filepath.Join(userConfigDir(), "cftunnel", u.name+".env")
+	}
+	return "/etc/cftunnel/" + u.name + ".env"
+}
 
-[Install]
-WantedBy=multi-user.target
-`, binPath, token)
+// systemctl 按服务级别执行 systemctl（用户级加 --user）
+func systemctl(user bool, args ...string) error {
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	return run("systemctl", args...)
+}
 
-	if err := os.WriteFile(s.unitPath(), []byte(unit), 0644); err != nil {
+func (s *systemd) install(u unit) error {
+	user := s.user
+	if !user && os.Geteuid() != 0 {
+		return fmt.Errorf("注册系统级服务需要 root 权限，请使用 sudo 执行，或加 --user 注册为当前用户的服务")
+	}
+	path := s.unitPath(u, user)
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
 		return err
 	}
-	if err := exec.Command("systemctl", "daemon-reload").Run(); err != nil {
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "[Unit]\nDescription=%s\nAfter=network.target\n", u.description)
+	if u.before != "" {
+		fmt.Fprintf(&b, "Before=%s.service\n", u.before)
+	}
+	b.WriteString("\n[Service]\n")
+	if len(u.env) > 0 {
+		env := s.envPath(u, user)
+		if err := os.MkdirAll(filepath.Dir(env), 0700); err != nil {
+			return err
+		}
+		if err := writePrivate(env, strings.Join(envList(u.env), "\n")+"\n"); err != nil {
+			return err
+		}
+		fmt.Fprintf(&b, "EnvironmentFile=%s\n", env)
+	}
+	target := "multi-user.target"
+	if user {
+		target = "default.target"
+	}
+	fmt.Fprintf(&b, "ExecStart=%s\nRestart=always\nRestartSec=5\n\n[Install]\nWantedBy=%s\n", execLine(u.binPath, u.args), target)
+
+	if err := writeFile(path, []byte(b.String()), 0644); err != nil {
+		return err
+	}
+	if err := systemctl(user, "daemon-reload"); err != nil {
+		return err
+	}
+	if err := systemctl(user, "enable", u.name); err != nil {
 		return err
 	}
-	return exec.Command("systemctl", "enable", "--now", unitName).Run()
+	// restart 同时覆盖首次安装与迁移旧定义（运行中的进程仍带旧参数）
+	return systemctl(user, "restart", u.name)
 }
 
-func (s *Systemd) Uninstall() error {
-	exec.Command("systemctl", "disable", "--now", unitName).Run()
-	return os.Remove(s.unitPath())
+// execLine 生成 ExecStart 命令行，含空白或引号的参数按 systemd 规则加引号，
+// $ 与 % 转义，避免被展开为环境变量或单元说明符
+func execLine(binPath string, args []string) string {
+	parts := make([]string, 0, len(args)+1)
+	for _, a := range append([]string{binPath}, args...) {
+		a = strings.NewReplacer("$", "$$", "%", "%%").Replace(a)
+		if strings.ContainsAny(a, " \t\"'\\") {
+			a = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(a) + `"`
+		}
+		parts = append(parts, a)
+	}
+	return strings.Join(parts, " ")
 }
 
-func (s *Systemd) Running() bool {
-	return exec.Command("systemctl", "is-active", "--quiet", unitName).Run() == nil
+func (s *systemd) uninstall(u unit) error {
+	user := s.userScope(u)
+	systemctl(user, "disable", "--now", u.name)
+	os.Remove(s.envPath(u, user))
+	if err := os.Remove(s.unitPath(u, user)); err != nil {
+		return err
+	}
+	return systemctl(user, "daemon-reload")
 }
 
-func New() Service {
-	return &Systemd{}
+func (s *systemd) snapshot(u unit) (func() error, error) {
+	user := s.userScope(u)
+	args := []string{"is-enabled", "--quiet", u.name}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	enabled := exec.Command("systemctl", args...).Run() == nil
+	files, err := saveFiles(s.unitPath(u, user), s.envPath(u, user))
+	if err != nil {
+		return nil, err
+	}
+	return func() error {
+		if err := os.MkdirAll(filepath.Dir(s.envPath(u, user)), 0700); err != nil {
+			return err
+		}
+		if err := restoreFiles(files); err != nil {
+			return err
+		}
+		if err := systemctl(user, "daemon-reload"); err != nil {
+			return err
+		}
+		if enabled {
+			if err := systemctl(user, "enable", u.name); err != nil {
+				return err
+			}
+		}
+		return systemctl(user, "restart", u.name)
+	}, nil
+}
+
+func (s *systemd) running(u unit) bool {
+	args := []string{"is-active", "--quiet", u.name}
+	if s.userScope(u) {
+		args = append([]string{"--user"}, args...)
+	}
+	return exec.Command("systemctl", args...).Run() == nil
+}
+
+func (s *systemd) restart(u unit) error {
+	return systemctl(s.userScope(u), "restart", u.name)
+}
+
+func (s *systemd) definition(u unit) string {
+	data, _ := os.ReadFile(s.unitPath(u, s.userScope(u)))
+	return string(data)
+}
+
+// showProps systemctl show 查询的属性，时间使用单调时钟（与时区和 systemd 版本无关）
+const showProps = "LoadState,UnitFileState,ActiveState,SubState,MainPID,NRestarts," +
+	"ExecMainStartTimestampMonotonic,ExecMainExitTimestampMonotonic,ExecMainStatus"
+
+func (s *systemd) status(u unit) (Status, error) {
+	user := s.userScope(u)
+	st := Status{Backend: "systemd", User: user}
+	args := []string{"show", u.name, "--property=" + showProps}
+	if user {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := exec.Command("systemctl", args...).Output()
+	if err != nil {
+		return st, fmt.Errorf("systemctl show 失败: %w", err)
+	}
+	props := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if k, v, ok := strings.Cut(line, "="); ok {
+			props[k] = v
+		}
+	}
+	if props["LoadState"] == "not-found" {
+		return st, nil
+	}
+	st.Installed = true
+	st.Enabled = props["UnitFileState"] == "enabled"
+	st.Running = props["ActiveState"] == "active"
+	st.Active = props["ActiveState"] + " (" + props["SubState"] + ")"
+	st.PID, _ = strconv.Atoi(props["MainPID"])
+	if n, err := strconv.Atoi(props["NRestarts"]); err == nil {
+		st.Restarts = &n
+	}
+	if start, _ := strconv.ParseInt(props["ExecMainStartTimestampMonotonic"], 10, 64); start > 0 && st.Running {
+		var now unix.Timespec
+		if unix.ClockGettime(unix.CLOCK_MONOTONIC, &now) == nil {
+			st.StartedAt = time.Now().Add(-time.Duration(now.Nano() - start*1000))
+		}
+	}
+	if exited, _ := strconv.ParseInt(props["ExecMainExitTimestampMonotonic"], 10, 64); exited > 0 {
+		if code, err := strconv.Atoi(props["ExecMainStatus"]); err == nil {
+			st.ExitCode = &code
+		}
+	}
+	return st, nil
+}
+
+func (s *systemd) logs(u unit, n int) ([]string, error) {
+	args := []string{"-u", u.name, "-n", strconv.Itoa(n), "-o", "short-iso", "--no-pager", "-q"}
+	if s.userScope(u) {
+		args = append([]string{"--user"}, args...)
+	}
+	out, err := exec.Command("journalctl", args...).Output()
+	if err != nil {
+		return nil, fmt.Errorf("读取 journal 失败: %w", err)
+	}
+	text := strings.TrimRight(string(out), "\n")
+	if text == "" {
+		return nil, nil
+	}
+	return strings.Split(text, "\n"), nil
 }
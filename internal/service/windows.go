@@ -3,36 +3,221 @@
 package service
 
 import (
+	"errors"
 	"fmt"
 	"os/exec"
+	"strconv"
 	"strings"
-)
+	"syscall"
+	"time"
 
-type Windows struct{}
+	"golang.org/x/sys/windows"
+	"golang.org/x/sys/windows/registry"
+)
 
-const svcName = "cftunnel"
+// winsvc 通过 sc 管理 Windows 服务（需要管理员权限）
+type winsvc struct{}
 
-func (w *Windows) Install(binPath, token string) error {
-	binArg := fmt.Sprintf(`%s tunnel --protocol http2 run --token %s`, binPath, token)
-	if err := exec.Command("sc", "create", svcName, "binPath=", binArg, "start=", "auto").Run(); err != nil {
+func (winsvc) install(u unit) error {
+	parts := []string{syscall.EscapeArg(u.binPath)}
+	for _, a := range u.args {
+		parts = append(parts, syscall.EscapeArg(a))
+	}
+	binArg := strings.Join(parts, " ")
+	if exec.Command("sc", "query", u.name).Run() == nil {
+		// 已注册（如旧版定义）：停止后原地更新命令行
+		exec.Command("sc", "stop", u.name).Run()
+		waitStopped(u.name)
+		if err := run("sc", "config", u.name, "binPath=", binArg); err != nil {
+			return fmt.Errorf("更新服务失败: %w", err)
+		}
+	} else if err := run("sc", "create", u.name, "binPath=", binArg, "start=", "auto"); err != nil {
 		return fmt.Errorf("创建服务失败: %w", err)
 	}
-	return exec.Command("sc", "start", svcName).Run()
+	if len(u.env) > 0 {
+		if err := setServiceEnv(u.name, envList(u.env)); err != nil {
+			return fmt.Errorf("写入服务环境变量失败: %w", err)
+		}
+	}
+	return run("sc", "start", u.name)
 }
 
-func (w *Windows) Uninstall() error {
-	exec.Command("sc", "stop", svcName).Run()
-	return exec.Command("sc", "delete", svcName).Run()
+// serviceKeySDDL 服务注册表项的访问控制：仅 SYSTEM（服务控制管理器）与 Administrators 可读写，
+// 不继承上级权限（默认 BUILTIN\Users 可读），子项同样适用
+const serviceKeySDDL = "D:P(A;CI;KA;;;SY)(A;CI;KA;;;BA)"
+
+// setServiceEnv 写入服务专属环境变量（注册表 Services\<服务名>\Environment），仅对该服务进程可见
+// 环境变量含令牌，写入前先收紧服务注册表项的权限，普通用户无法读取
+func setServiceEnv(name string, env []string) error {
+	if err := restrictServiceKey(name); err != nil {
+		return fmt.Errorf("设置服务注册表项权限失败: %w", err)
+	}
+	k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+name, registry.SET_VALUE)
+	if err != nil {
+		return err
+	}
+	defer k.Close()
+	return k.SetStringsValue("Environment", env)
 }
 
-func (w *Windows) Running() bool {
-	out, err := exec.Command("sc", "query", svcName).Output()
+// restrictServiceKey 为服务注册表项设置 serviceKeySDDL 权限
+func restrictServiceKey(name string) error {
+	sd, err := windows.SecurityDescriptorFromString(serviceKeySDDL)
+	if err != nil {
+		return err
+	}
+	dacl, _, err := sd.DACL()
+	if err != nil {
+		return err
+	}
+	return windows.SetNamedSecurityInfo(`MACHINE\SYSTEM\CurrentControlSet\Services\`+name, windows.SE_REGISTRY_KEY,
+		windows.DACL_SECURITY_INFORMATION|windows.PROTECTED_DACL_SECURITY_INFORMATION, nil, nil, dacl, nil)
+}
+
+func (winsvc) uninstall(u unit) error {
+	exec.Command("sc", "stop", u.name).Run()
+	waitStopped(u.name)
+	if err := exec.Command("sc", "delete", u.name).Run(); err != nil {
+		return err
+	}
+	// 服务在所有句柄关闭后才真正删除，期间同名服务无法重新创建
+	for i := 0; i < 20 && exec.Command("sc", "query", u.name).Run() == nil; i++ {
+		time.Sleep(500 * time.Millisecond)
+	}
+	return nil
+}
+
+// snapshot 记录服务命令行、启动类型和环境变量，恢复时按原样重新创建
+func (winsvc) snapshot(u unit) (func() error, error) {
+	out, err := exec.Command("sc", "qc", u.name).Output()
+	if err != nil {
+		return nil, err
+	}
+	qc := scFields(out)
+	binPath := qc["BINARY_PATH_NAME"]
+	start := "demand"
+	if strings.Contains(qc["START_TYPE"], "AUTO_START") {
+		start = "auto"
+	}
+	var env []string
+	if k, err := registry.OpenKey(registry.LOCAL_MACHINE, `SYSTEM\CurrentControlSet\Services\`+u.name, registry.QUERY_VALUE); err == nil {
+		env, _, _ = k.GetStringsValue("Environment")
+		k.Close()
+	}
+	return func() error {
+		if err := run("sc", "create", u.name, "binPath=", binPath, "start=", start); err != nil {
+			return err
+		}
+		if len(env) > 0 {
+			if err := setServiceEnv(u.name, env); err != nil {
+				return err
+			}
+		}
+		return run("sc", "start", u.name)
+	}, nil
+}
+
+func (winsvc) running(u unit) bool {
+	out, err := exec.Command("sc", "query", u.name).Output()
 	if err != nil {
 		return false
 	}
 	return strings.Contains(string(out), "RUNNING")
 }
 
+func (winsvc) restart(u unit) error {
+	exec.Command("sc", "stop", u.name).Run()
+	waitStopped(u.name)
+	return run("sc", "start", u.name)
+}
+
+func (winsvc) definition(u unit) string {
+	out, _ := exec.Command("sc", "qc", u.name).Output()
+	return string(out)
+}
+
+// scFields 解析 sc queryex / sc qc 输出中 "名称 : 值" 形式的字段
+func scFields(out []byte) map[string]string {
+	fields := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		if k, v, ok := strings.Cut(line, ":"); ok {
+			fields[strings.TrimSpace(k)] = strings.TrimSpace(v)
+		}
+	}
+	return fields
+}
+
+func (winsvc) status(u unit) (Status, error) {
+	st := Status{Backend: "windows"}
+	out, err := exec.Command("sc", "queryex", u.name).Output()
+	if err != nil {
+		// 1060: 服务不存在
+		return st, nil
+	}
+	st.Installed = true
+	q := scFields(out)
+	// STATE : 4  RUNNING
+	if f := strings.Fields(q["STATE"]); len(f) >= 2 {
+		st.Active = f[1]
+	}
+	st.Running = st.Active == "RUNNING"
+	st.PID, _ = strconv.Atoi(q["PID"])
+	// WIN32_EXIT_CODE : 0  (0x0)
+	if !st.Running {
+		if f := strings.Fields(q["WIN32_EXIT_CODE"]); len(f) > 0 {
+			if code, err := strconv.Atoi(f[0]); err == nil {
+				st.ExitCode = &code
+			}
+		}
+	}
+	if out, err := exec.Command("sc", "qc", u.name).Output(); err == nil {
+		st.Enabled = strings.Contains(scFields(out)["START_TYPE"], "AUTO_START")
+	}
+	if st.PID > 0 {
+		st.StartedAt = processStart(st.PID)
+	}
+	return st, nil
+}
+
+// processStart 返回进程的创建时间，无法获取时为零值
+func processStart(pid int) time.Time {
+	h, err := windows.OpenProcess(windows.PROCESS_QUERY_LIMITED_INFORMATION, false, uint32(pid))
+	if err != nil {
+		return time.Time{}
+	}
+	defer windows.CloseHandle(h)
+	var created, exited, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(h, &created, &exited, &kernel, &user); err != nil {
+		return time.Time{}
+	}
+	return time.Unix(0, created.Nanoseconds())
+}
+
+func (winsvc) logs(u unit, n int) ([]string, error) {
+	return nil, errors.New("Windows 服务不单独记录日志，请在事件查看器（Windows 日志 → 系统）中查看服务启停记录")
+}
+
+// waitStopped 等待服务完全停止（sc stop 是异步的）
+func waitStopped(name string) {
+	for i := 0; i < 30; i++ {
+		out, _ := exec.Command("sc", "query", name).Output()
+		if strings.Contains(string(out), "STOPPED") {
+			return
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+}
+
 func New() Service {
-	return &Windows{}
+	return &manager{b: winsvc{}}
+}
+
+// NewUser Windows 服务均为系统级
+func NewUser() Service {
+	return &manager{b: unsupported{errors.New("Windows 不支持 --user，请以管理员身份注册系统服务")}}
+}
+
+// UserHint Windows 无用户级服务
+func UserHint() string {
+	return ""
 }
@@ -0,0 +1,189 @@
+package supervisor
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"time"
+)
+
+// 默认退避参数
+const (
+	defaultMinBackoff = time.Second
+	defaultMaxBackoff = time.Minute
+	defaultResetAfter = time.Minute
+	stopGracePeriod   = 10 * time.Second
+)
+
+// Options 守护选项
+type Options struct {
+	Name      string              // 子进程名称，用于日志和状态
+	Command   func() *exec.Cmd    // 每次（重新）启动时构造新的命令
+	Interrupt func(cmd *exec.Cmd) // 优雅终止子进程，超时后强制 Kill
+	StatePath string              // 状态文件（JSON），为空时不记录
+
+	MinBackoff time.Duration // 首次重启等待，默认 1 秒
+	MaxBackoff time.Duration // 最大重启等待，默认 60 秒
+	ResetAfter time.Duration // 子进程稳定运行超过该时长后重置退避，默认 60 秒
+
+	OnStart func(pid int) // 子进程启动后回调（如写 PID 文件）
+	OnExit  func()        // 子进程退出后回调
+}
+
+// State 守护状态，写入状态文件供 status 读取
+type State struct {
+	PID        int       `json:"pid"` // 守护进程 PID
+	Child      string    `json:"child"`
+	ChildPID   int       `json:"child_pid,omitempty"`
+	StartedAt  time.Time `json:"started_at"`
+	Restarts   int       `json:"restarts"`
+	LastExit   string    `json:"last_exit,omitempty"` // 最近一次退出原因
+	LastExitAt time.Time `json:"last_exit_at"`
+}
+
+// LoadState 读取状态文件
+func LoadState(path string) (*State, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var st State
+	if err := json.Unmarshal(data, &st); err != nil {
+		return nil, err
+	}
+	return &st, nil
+}
+
+func (s *State) save(path string) {
+	if path == "" {
+		return
+	}
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return
+	}
+	os.MkdirAll(filepath.Dir(path), 0700)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0600); err != nil {
+		return
+	}
+	os.Rename(tmp, path)
+}
+
+// Run 启动子进程并在其退出后按指数退避重启，直到 stop 关闭
+// 返回时子进程已退出，状态文件已删除
+func Run(opts Options, stop <-chan struct{}) error {
+	if opts.MinBackoff <= 0 {
+		opts.MinBackoff = defaultMinBackoff
+	}
+	if opts.MaxBackoff <= 0 {
+		opts.MaxBackoff = defaultMaxBackoff
+	}
+	if opts.ResetAfter <= 0 {
+		opts.ResetAfter = defaultResetAfter
+	}
+
+	st := &State{PID: os.Getpid(), Child: opts.Name, StartedAt: time.Now()}
+	if opts.StatePath != "" {
+		defer os.Remove(opts.StatePath)
+	}
+	backoff := opts.MinBackoff
+
+	for {
+		cmd := opts.Command()
+		if err := cmd.Start(); err != nil {
+			// 启动失败（如二进制被删除）同样按退避重试
+			st.LastExit = "启动失败: " + err.Error()
+			st.LastExitAt = time.Now()
+			st.ChildPID = 0
+			st.save(opts.StatePath)
+			log.Printf("[supervisor] %s 启动失败: %v", opts.Name, err)
+		} else {
+			st.ChildPID = cmd.Process.Pid
+			st.save(opts.StatePath)
+			if opts.OnStart != nil {
+				opts.OnStart(cmd.Process.Pid)
+			}
+			log.Printf("[supervisor] %s 已启动 (PID: %d)", opts.Name, cmd.Process.Pid)
+
+			started := time.Now()
+			done := make(chan error, 1)
+			go func() { done <- cmd.Wait() }()
+
+			var err error
+			select {
+			case err = <-done:
+			case <-stop:
+				terminate(cmd, opts.Interrupt, done)
+				if opts.OnExit != nil {
+					opts.OnExit()
+				}
+				log.Printf("[supervisor] %s 已停止", opts.Name)
+				return nil
+			}
+			if opts.OnExit != nil {
+				opts.OnExit()
+			}
+			// Ctrl+C 会同时发给子进程，子进程可能先于 stop 退出
+			select {
+			case <-stop:
+				log.Printf("[supervisor] %s 已停止", opts.Name)
+				return nil
+			case <-time.After(100 * time.Millisecond):
+			}
+
+			st.LastExit = exitReason(err)
+			st.LastExitAt = time.Now()
+			st.ChildPID = 0
+			if time.Since(started) > opts.ResetAfter {
+				backoff = opts.MinBackoff
+			}
+		}
+
+		st.Restarts++
+		st.save(opts.StatePath)
+		log.Printf("[supervisor] %s 退出（%s），%s 后重启（第 %d 次）", opts.Name, st.LastExit, backoff, st.Restarts)
+
+		select {
+		case <-time.After(backoff):
+		case <-stop:
+			return nil
+		}
+		backoff *= 2
+		if backoff > opts.MaxBackoff {
+			backoff = opts.MaxBackoff
+		}
+	}
+}
+
+// terminate 优雅终止子进程，超时后强制结束
+func terminate(cmd *exec.Cmd, interrupt func(*exec.Cmd), done <-chan error) {
+	if interrupt != nil {
+		interrupt(cmd)
+	} else {
+		cmd.Process.Kill()
+	}
+	select {
+	case <-done:
+	case <-time.After(stopGracePeriod):
+		cmd.Process.Kill()
+		<-done
+	}
+}
+
+// exitReason 描述子进程退出原因
+func exitReason(err error) string {
+	if err == nil {
+		return "正常退出 (exit 0)"
+	}
+	if ee, ok := err.(*exec.ExitError); ok {
+		if code := ee.ExitCode(); code >= 0 {
+			return fmt.Sprintf("exit %d", code)
+		}
+		return ee.String()
+	}
+	return err.Error()
+}
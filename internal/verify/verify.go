@@ -0,0 +1,175 @@
+// Package verify 校验下载文件的 SHA-256 与 cftunnel 发布签名
+package verify
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/qingchencloud/cftunnel/internal/download"
+)
+
+// SkipEnv 设置为 1 时跳过校验（无法访问 GitHub 时的最后手段，不推荐）
+const SkipEnv = "CFTUNNEL_INSECURE_SKIP_VERIFY"
+
+// Skip 是否已通过环境变量关闭校验
+func Skip() bool {
+	return os.Getenv(SkipEnv) == "1"
+}
+
+// apiTimeout 查询发布信息与校验文件的超时
+const apiTimeout = 30 * time.Second
+
+// Release GitHub 官方发布信息与各文件的 SHA-256
+type Release struct {
+	Tag     string
+	Digests map[string]string // 文件名 → 小写十六进制 SHA-256
+}
+
+// FetchRelease 从 api.github.com（而非镜像）获取发布信息，tag 为空时取最新版本
+// 优先使用资源的 digest 字段，缺失时从发布说明中解析 "文件名: 哈希" 或 "哈希  文件名"
+func FetchRelease(repo, tag string) (*Release, error) {
+	url := "https://api.github.com/repos/" + repo + "/releases/latest"
+	if tag != "" {
+		url = "https://api.github.com/repos/" + repo + "/releases/tags/" + tag
+	}
+	resp, err := download.Client(apiTimeout).Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("查询 %s 发布信息失败: HTTP %d", repo, resp.StatusCode)
+	}
+	var r struct {
+		TagName string `json:"tag_name"`
+		Body    string `json:"body"`
+		Assets  []struct {
+			Name   string `json:"name"`
+			Digest string `json:"digest"`
+		} `json:"assets"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&r); err != nil {
+		return nil, err
+	}
+
+	rel := &Release{Tag: r.TagName, Digests: ParseChecksums([]byte(r.Body))}
+	for _, a := range r.Assets {
+		if sum, ok := strings.CutPrefix(a.Digest, "sha256:"); ok {
+			rel.Digests[a.Name] = strings.ToLower(sum)
+		}
+	}
+	return rel, nil
+}
+
+// FetchChecksums 下载并解析校验文件（sha256sum 格式）
+func FetchChecksums(url string) (map[string]string, []byte, error) {
+	resp, err := download.Client(apiTimeout).Get(url)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("下载校验文件失败: HTTP %d", resp.StatusCode)
+	}
+	data, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, nil, err
+	}
+	return ParseChecksums(data), data, nil
+}
+
+var (
+	sumNameRe = regexp.MustCompile(`^([0-9a-fA-F]{64})\s+\*?(\S+)$`) // sha256sum 格式
+	nameSumRe = regexp.MustCompile(`^(\S+?):?\s+([0-9a-fA-F]{64})$`) // cloudflared 发布说明格式
+)
+
+// ParseChecksums 解析 "哈希  文件名" 或 "文件名: 哈希" 格式的校验列表
+func ParseChecksums(data []byte) map[string]string {
+	sums := map[string]string{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for sc.Scan() {
+		line := strings.Trim(strings.TrimSpace(sc.Text()), "`")
+		line = strings.TrimLeft(line, "-* ")
+		if m := sumNameRe.FindStringSubmatch(line); m != nil {
+			sums[m[2]] = strings.ToLower(m[1])
+		} else if m := nameSumRe.FindStringSubmatch(line); m != nil {
+			sums[m[1]] = strings.ToLower(m[2])
+		}
+	}
+	return sums
+}
+
+// Check 比较实际与期望的 SHA-256
+func Check(name, got, want string) error {
+	if !strings.EqualFold(got, want) {
+		return fmt.Errorf("%s 校验失败: SHA-256 为 %s，官方发布为 %s", name, got, want)
+	}
+	return nil
+}
+
+// FileSum 计算文件的 SHA-256
+func FileSum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// SumPath 返回二进制旁记录哈希的文件路径
+func SumPath(binPath string) string {
+	return binPath + ".sha256"
+}
+
+// Record 计算已安装二进制的 SHA-256 并写入 <二进制>.sha256（sha256sum 格式）
+func Record(binPath string) (string, error) {
+	sum, err := FileSum(binPath)
+	if err != nil {
+		return "", err
+	}
+	line := sum + "  " + filepath.Base(binPath) + "\n"
+	return sum, os.WriteFile(SumPath(binPath), []byte(line), 0644)
+}
+
+// Recorded 读取 <二进制>.sha256 中记录的哈希，不存在时返回空
+func Recorded(binPath string) string {
+	data, err := os.ReadFile(SumPath(binPath))
+	if err != nil {
+		return ""
+	}
+	sum, _, _ := strings.Cut(strings.TrimSpace(string(data)), " ")
+	return sum
+}
+
+// Signature 使用 base64 编码的 ed25519 公钥校验签名
+func Signature(publicKey string, msg, sig []byte) error {
+	key, err := base64.StdEncoding.DecodeString(publicKey)
+	if err != nil || len(key) != ed25519.PublicKeySize {
+		return fmt.Errorf("内置发布公钥无效")
+	}
+	if s, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(sig))); err == nil {
+		sig = s
+	}
+	if !ed25519.Verify(ed25519.PublicKey(key), msg, sig) {
+		return fmt.Errorf("发布签名校验失败")
+	}
+	return nil
+}
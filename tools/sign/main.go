@@ -0,0 +1,64 @@
+// sign 为发布的 checksums.txt 生成 ed25519 签名（供 goreleaser 调用）
+//
+//	go run ./tools/sign -keygen                 # 生成密钥对
+//	go run ./tools/sign -out checksums.txt.sig checksums.txt
+//
+// 私钥通过环境变量 CFTUNNEL_SIGNING_KEY 传入（base64 编码的 32 字节种子），
+// 公钥写在 internal/selfupdate.PublicKey 中，cftunnel update 据此校验签名；
+// 该变量非空时，私钥与其不匹配则拒绝签名。
+package main
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/qingchencloud/cftunnel/internal/selfupdate"
+)
+
+func main() {
+	keygen := flag.Bool("keygen", false, "生成新的签名密钥对")
+	out := flag.String("out", "", "签名输出文件，默认为 <文件>.sig")
+	flag.Parse()
+
+	if *keygen {
+		pub, priv, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			fatal(err)
+		}
+		fmt.Println("CFTUNNEL_SIGNING_KEY=" + base64.StdEncoding.EncodeToString(priv.Seed()))
+		fmt.Println("CFTUNNEL_PUBLIC_KEY=" + base64.StdEncoding.EncodeToString(pub))
+		return
+	}
+
+	if flag.NArg() != 1 {
+		fatal(fmt.Errorf("用法: sign [-out 签名文件] <文件>"))
+	}
+	seed, err := base64.StdEncoding.DecodeString(os.Getenv("CFTUNNEL_SIGNING_KEY"))
+	if err != nil || len(seed) != ed25519.SeedSize {
+		fatal(fmt.Errorf("CFTUNNEL_SIGNING_KEY 未设置或格式无效"))
+	}
+	key := ed25519.NewKeyFromSeed(seed)
+	if pub := base64.StdEncoding.EncodeToString(key.Public().(ed25519.PublicKey)); selfupdate.PublicKey != "" && pub != selfupdate.PublicKey {
+		fatal(fmt.Errorf("CFTUNNEL_SIGNING_KEY 与 internal/selfupdate.PublicKey 不匹配"))
+	}
+	data, err := os.ReadFile(flag.Arg(0))
+	if err != nil {
+		fatal(err)
+	}
+	sig := ed25519.Sign(key, data)
+	if *out == "" {
+		*out = flag.Arg(0) + ".sig"
+	}
+	if err := os.WriteFile(*out, []byte(base64.StdEncoding.EncodeToString(sig)+"\n"), 0644); err != nil {
+		fatal(err)
+	}
+}
+
+func fatal(err error) {
+	fmt.Fprintln(os.Stderr, "sign:", err)
+	os.Exit(1)
+}